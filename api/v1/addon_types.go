@@ -38,6 +38,11 @@ type AddonSpec struct {
 	// +kubebuilder:validation:Enum=product;service;support;onetime
 	// AddonType represents the type of the addon.
 	AddonType string `json:"addonType"`
+
+	// +kubebuilder:validation:Optional
+	// Repositories represents the registry repositories the addon grants pull access to.
+	// Entries may contain shell patterns, e.g. harikube/*.
+	Repositories []string `json:"repositories,omitempty"`
 }
 
 // AddonStatus defines the observed state of Addon.
@@ -0,0 +1,87 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditActor represents the identity performing an audited action.
+// +kubebuilder:validation:XValidation:rule="has(self.username) || has(self.email)",message="actor needs a username or an email"
+type AuditActor struct {
+	// +kubebuilder:validation:Optional
+	// Username represents the Kubernetes username of the actor, controllers record themselves as system:webshop:<name>.
+	Username string `json:"username,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Email represents the email address of the User behind the actor, actions taken for a User
+	// by the controllers carry the email only.
+	Email string `json:"email,omitempty"`
+}
+
+// AuditEventSpec defines the recorded business event.
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="AuditEvents are immutable"
+type AuditEventSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Login;PasswordChange;PasswordReset;OrderPlaced;PaymentSucceeded;PaymentFailed;LicenceIssued;RegistryTokenCreated
+	// Action represents the kind of the business event.
+	Action string `json:"action"`
+
+	// +kubebuilder:validation:Required
+	// Actor represents who performed the action.
+	Actor AuditActor `json:"actor"`
+
+	// +kubebuilder:validation:Required
+	// Timestamp represents the date when the action happened.
+	Timestamp metav1.Time `json:"timestamp"`
+
+	// +kubebuilder:validation:Optional
+	// ObjectRefs references the objects the action was performed on.
+	ObjectRefs []corev1.ObjectReference `json:"objectRefs,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=1024
+	// Message represents a human readable description of the action.
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.action"
+// +kubebuilder:printcolumn:name="Actor",type="string",JSONPath=".spec.actor.username"
+// +kubebuilder:printcolumn:name="Date",type="date",JSONPath=".spec.timestamp"
+
+// AuditEvent is the Schema for the auditevents API, an immutable record of a business event inside a tenant.
+type AuditEvent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AuditEventSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AuditEventList contains a list of AuditEvent.
+type AuditEventList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuditEvent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AuditEvent{}, &AuditEventList{})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// InvitationSpec defines the desired state of Invitation.
+type InvitationSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Format=email
+	// +kubebuilder:validation:MinLength=5
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`
+	// Email represents the email address the invitation is sent to.
+	Email string `json:"email"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Owner;Admin;Billing;Member
+	// +kubebuilder:default=Member
+	// Role represents the role the invited user gets in the tenant.
+	Role string `json:"role,omitempty"`
+}
+
+// InvitationStatus defines the observed state of Invitation.
+type InvitationStatus struct {
+	LastGeneration int64 `json:"lastGeneration,omitempty"`
+	// +kubebuilder:validation:Enum=Pending;Accepted;Expired
+	Phase string `json:"phase,omitempty"`
+	// ExpireTimestamp is the time the invitation can be accepted until.
+	ExpireTimestamp   metav1.Time                  `json:"expireTimestamp,omitempty"`
+	EmailRef          *corev1.LocalObjectReference `json:"emailRef,omitempty"`
+	UserRef           *corev1.LocalObjectReference `json:"userRef,omitempty"`
+	AcceptedTimestamp metav1.Time                  `json:"acceptedTimestamp,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Email",type=string,JSONPath=`.spec.email`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Expire",type="date",JSONPath=".status.expireTimestamp"
+// +kubebuilder:selectablefield:JSONPath=".spec.email"
+
+// Invitation is the Schema for the invitations API.
+type Invitation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InvitationSpec   `json:"spec,omitempty"`
+	Status InvitationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// InvitationList contains a list of Invitation.
+type InvitationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Invitation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Invitation{}, &InvitationList{})
+}
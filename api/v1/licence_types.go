@@ -35,23 +35,63 @@ type LicenceSpec struct {
 	// Addons represents the list of addons associated with this licence.
 	Addons []Addon `json:"addons,omitempty"`
 
+	// +kubebuilder:validation:Required
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000
+	// Seats represents the number of installations allowed to activate the licence.
+	Seats int32 `json:"seats"`
+
 	// +kubebuilder:validation:Required
 	// ExpireTimestamp represents the expiration date of the licence.
 	ExpireTimestamp metav1.Time `json:"expireTimestamp,omitempty"`
 }
 
+// LicenceActivation represents an installation registered against a licence.
+type LicenceActivation struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// Fingerprint represents the unique identifier of the machine or cluster.
+	Fingerprint string `json:"fingerprint"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Machine;Cluster
+	// Kind represents the type of the activated installation.
+	Kind string `json:"kind"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=256
+	// DisplayName represents the human friendly name of the installation.
+	DisplayName string `json:"displayName,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// ActivationTimestamp represents the date when the installation was activated.
+	ActivationTimestamp metav1.Time `json:"activationTimestamp"`
+
+	// +kubebuilder:validation:Optional
+	// LastSeenTimestamp represents the date when the installation last activated.
+	LastSeenTimestamp metav1.Time `json:"lastSeenTimestamp,omitempty"`
+}
+
 // LicenceStatus defines the observed state of Licence.
 type LicenceStatus struct {
 	LastGeneration int64                        `json:"lastGeneration,omitempty"`
 	ErrorMessage   string                       `json:"errorMessage,omitempty"`
 	ErrorTimestamp metav1.Time                  `json:"errorTimestamp,omitempty"`
 	LicenceRef     *corev1.LocalObjectReference `json:"licenceKey,omitempty"`
+	Activations    []LicenceActivation          `json:"activations,omitempty"`
+	// Suspended represents whether the licence is suspended together with its tenant.
+	Suspended          bool        `json:"suspended,omitempty"`
+	SuspendedTimestamp metav1.Time `json:"suspendedTimestamp,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="DisplayName",type="string",JSONPath=".spec.displayName"
+// +kubebuilder:printcolumn:name="Seats",type="integer",JSONPath=".spec.seats"
 // +kubebuilder:printcolumn:name="Expire",type="date",JSONPath=".spec.expireTimestamp"
+// +kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".status.suspended"
 // +kubebuilder:selectablefield:JSONPath=".spec.displayName"
 
 // Licence is the Schema for the licences API.
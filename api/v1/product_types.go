@@ -37,6 +37,16 @@ type ProductSpec struct {
 	// +kubebuilder:validation:Optional
 	// Addons represents a list of addons associated with the product.
 	Addons []Addon `json:"addons,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Enum=Free;Pro;Enterprise
+	// Plans represents the plans of the tenants the product can be ordered by, all plans if empty.
+	Plans []string `json:"plans,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Free;Pro;Enterprise
+	// UpgradePlan represents the plan the tenant is upgraded to once an order of the product has been paid.
+	UpgradePlan string `json:"upgradePlan,omitempty"`
 }
 
 // ProductStatus defines the observed state of Product.
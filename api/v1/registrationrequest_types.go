@@ -26,8 +26,8 @@ type RegistrationRequestSpec struct {
 	// User contains the user information for the registration request.
 	User UserSpec `json:"user"`
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:MinLength=8
-	// Password represents the password for the user.
+	// Password represents the password for the user, the webhook replaces it with its hash and the
+	// controller clears it once the hash has been handed over to the User.
 	Password string `json:"password"`
 	// +kubebuilder:validation:Required
 	// Tenant contains the tenant information for the registration request.
@@ -41,20 +41,36 @@ type RegistryTokenSpec struct {
 	// +kubebuilder:validation:Required
 	// ExpireTimestamp represents the expiration time of the registry token.
 	ExpireTimestamp metav1.Time `json:"expireTimestamp"`
+
+	// +kubebuilder:validation:Optional
+	// AutoRotate represents whether the credential is replaced before it expires. The replacement gets the lifetime
+	// of the previous credential, capped at the maximum lifetime of the tenant, and the expiration moves along.
+	AutoRotate bool `json:"autoRotate,omitempty"`
 }
 
 // RegistryTokenStatus defines the observed state of RegistryToken.
 type RegistryTokenStatus struct {
-	LastGeneration int64                        `json:"lastGeneration,omitempty"`
-	ErrorMessage   string                       `json:"errorMessage,omitempty"`
-	ErrorTimestamp metav1.Time                  `json:"errorTimestamp,omitempty"`
-	TokenRef       *corev1.LocalObjectReference `json:"tokenRef,omitempty"`
+	LastGeneration int64 `json:"lastGeneration,omitempty"`
+	// +kubebuilder:validation:Enum=Active;Suspended;Expired
+	Phase                   string                       `json:"phase,omitempty"`
+	ErrorMessage            string                       `json:"errorMessage,omitempty"`
+	ErrorTimestamp          metav1.Time                  `json:"errorTimestamp,omitempty"`
+	TokenRef                *corev1.LocalObjectReference `json:"tokenRef,omitempty"`
+	IssueTimestamp          metav1.Time                  `json:"issueTimestamp,omitempty"`
+	PreviousTokenRef        *corev1.LocalObjectReference `json:"previousTokenRef,omitempty"`
+	PreviousExpireTimestamp metav1.Time                  `json:"previousExpireTimestamp,omitempty"`
+	Rotations               int32                        `json:"rotations,omitempty"`
+	ExpiredTimestamp        metav1.Time                  `json:"expiredTimestamp,omitempty"`
+	// TokenExpireTimestamp is the time the credential referenced by TokenRef expires, the credential
+	// referenced by PreviousTokenRef is revoked at PreviousExpireTimestamp.
+	TokenExpireTimestamp metav1.Time `json:"tokenExpireTimestamp,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="DisplayName",type="string",JSONPath=".spec.displayName"
 // +kubebuilder:printcolumn:name="Expire",type="date",JSONPath=".spec.expireTimestamp"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.errorMessage"
 // +kubebuilder:selectablefield:JSONPath=".spec.displayName"
 
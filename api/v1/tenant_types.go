@@ -58,18 +58,71 @@ type TenantSpec struct {
 	// +kubebuilder:validation:MaxLength=256
 	// TaxNumber represents the tax number of the user.
 	TaxNumber string `json:"taxNumber,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Free;Pro;Enterprise
+	// +kubebuilder:default=Free
+	// Plan represents the commercial tier of the tenant, it is upgraded by ordering a plan product.
+	Plan string `json:"plan,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=253
+	// Profile represents the name of the TenantProfile guarding the tenant namespace, the profile named after
+	// the plan or the default profile is used if empty.
+	Profile string `json:"profile,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Suspended represents whether the tenant is suspended, users of a suspended tenant keep read access only.
+	Suspended bool `json:"suspended,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=512
+	// SuspensionReason represents the reason of the suspension, like non-payment or abuse.
+	SuspensionReason string `json:"suspensionReason,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DeletionRequested represents whether the tenant is to be deleted after the grace period,
+	// clearing it within the grace period cancels the deletion.
+	DeletionRequested bool `json:"deletionRequested,omitempty"`
 }
 
 // TenantStatus defines the observed state of Tenant.
 type TenantStatus struct {
-	LastGeneration int64                         `json:"lastGeneration,omitempty"`
-	TenantRefs     []corev1.LocalObjectReference `json:"tenantRefs,omitempty"`
+	LastGeneration int64 `json:"lastGeneration,omitempty"`
+	// Namespace is the name of the tenant namespace, chosen by the naming strategy when the tenant was created.
+	Namespace string `json:"namespace,omitempty"`
+	// Profile is the name of the TenantProfile applied to the tenant namespace.
+	Profile string `json:"profile,omitempty"`
+	// TenantRefs references the Namespace of the tenant and the Users inside it.
+	TenantRefs []corev1.ObjectReference `json:"tenantRefs,omitempty"`
+	// Users counts the Users of the tenant.
+	Users int32 `json:"users,omitempty"`
+	// ActiveLicences counts the Licences of the tenant which have not expired.
+	ActiveLicences int32 `json:"activeLicences,omitempty"`
+	// OpenOrders counts the Orders of the tenant which have not been paid yet.
+	OpenOrders int32 `json:"openOrders,omitempty"`
+	// SuspendedTimestamp is the time the tenant has been suspended at.
+	SuspendedTimestamp metav1.Time `json:"suspendedTimestamp,omitempty"`
+	// DeletionScheduledTimestamp is the time the tenant is deleted at, unless the deletion is cancelled.
+	DeletionScheduledTimestamp metav1.Time `json:"deletionScheduledTimestamp,omitempty"`
+	// ExportRef references the Secret holding the data export taken before the deletion.
+	ExportRef *corev1.ObjectReference `json:"exportRef,omitempty"`
+	// Conditions represent the Ready, Degraded, Suspended and PendingDeletion state of the tenant.
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:printcolumn:name="Company",type=string,JSONPath=`.spec.companyName`
+// +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.status.namespace`
+// +kubebuilder:printcolumn:name="Plan",type=string,JSONPath=`.spec.plan`
+// +kubebuilder:printcolumn:name="Users",type=integer,JSONPath=`.status.users`
+// +kubebuilder:printcolumn:name="Licences",type=integer,JSONPath=`.status.activeLicences`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspended`
 // +kubebuilder:selectablefield:JSONPath=".spec.companyName"
 // +kubebuilder:selectablefield:JSONPath=".spec.country"
 // +kubebuilder:selectablefield:JSONPath=".spec.city"
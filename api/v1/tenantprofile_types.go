@@ -0,0 +1,71 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TenantProfileSpec defines the desired state of TenantProfile.
+type TenantProfileSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=250
+	// DisplayName represents the human friendly name of the profile.
+	DisplayName string `json:"displayName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Hard represents the ResourceQuota of the tenant namespaces, object counts
+	// like count/orders.product.webshop.harikube.info included.
+	Hard corev1.ResourceList `json:"hard,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Limits represents the LimitRange of the tenant namespaces.
+	Limits []corev1.LimitRangeItem `json:"limits,omitempty"`
+}
+
+// TenantProfileStatus defines the observed state of TenantProfile.
+type TenantProfileStatus struct {
+	LastGeneration int64 `json:"lastGeneration,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="DisplayName",type="string",JSONPath=".spec.displayName"
+
+// TenantProfile is the Schema for the tenantprofiles API.
+type TenantProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantProfileSpec   `json:"spec,omitempty"`
+	Status TenantProfileStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// TenantProfileList contains a list of TenantProfile.
+type TenantProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantProfile{}, &TenantProfileList{})
+}
@@ -51,6 +51,12 @@ type UserSpec struct {
 	// +kubebuilder:validation:Pattern=`^\+?[1-9]\d{1,14}$`
 	// PhoneNumber represents the phone number of the user.
 	PhoneNumber *string `json:"phoneNumber,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Owner;Admin;Billing;Member
+	// +kubebuilder:default=Member
+	// Role represents the role of the user in the tenant, users created without one are members.
+	Role string `json:"role,omitempty"`
 }
 
 // UserStatus defines the observed state of User.
@@ -60,6 +66,17 @@ type UserStatus struct {
 	// +kubebuilder:default=Pending
 	Phase       string                       `json:"phase,omitempty"`
 	PasswordRef *corev1.LocalObjectReference `json:"passwordRef,omitempty"`
+	MFAEnabled  bool                         `json:"mfaEnabled,omitempty"`
+	MFARef      *corev1.LocalObjectReference `json:"mfaRef,omitempty"`
+	// FailedLoginAttempts counts the consecutive failed logins since the last success or lockout.
+	FailedLoginAttempts      int32       `json:"failedLoginAttempts,omitempty"`
+	LastFailedLoginTimestamp metav1.Time `json:"lastFailedLoginTimestamp,omitempty"`
+	// LockedUntilTimestamp is the time logins are rejected until after too many failures.
+	LockedUntilTimestamp metav1.Time `json:"lockedUntilTimestamp,omitempty"`
+	// VerifiedPhoneNumber is the phone number a text message code was confirmed for.
+	VerifiedPhoneNumber string `json:"verifiedPhoneNumber,omitempty"`
+	// PhoneNumberVerifiedTimestamp is the time the phone number was confirmed, it is cleared when the number changes.
+	PhoneNumberVerifiedTimestamp metav1.Time `json:"phoneNumberVerifiedTimestamp,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -67,6 +84,7 @@ type UserStatus struct {
 // +kubebuilder:printcolumn:name="FirstName",type=string,JSONPath=`.spec.firstName`
 // +kubebuilder:printcolumn:name="LastName",type=string,JSONPath=`.spec.lastName`
 // +kubebuilder:printcolumn:name="Email",type=string,JSONPath=`.spec.email`
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
 // +kubebuilder:selectablefield:JSONPath=".spec.firstName"
 // +kubebuilder:selectablefield:JSONPath=".spec.lastName"
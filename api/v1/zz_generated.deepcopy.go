@@ -22,6 +22,7 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -30,7 +31,7 @@ func (in *Addon) DeepCopyInto(out *Addon) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
@@ -87,6 +88,11 @@ func (in *AddonList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AddonSpec) DeepCopyInto(out *AddonSpec) {
 	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonSpec.
@@ -114,6 +120,101 @@ func (in *AddonStatus) DeepCopy() *AddonStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditActor) DeepCopyInto(out *AuditActor) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditActor.
+func (in *AuditActor) DeepCopy() *AuditActor {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditActor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditEvent) DeepCopyInto(out *AuditEvent) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditEvent.
+func (in *AuditEvent) DeepCopy() *AuditEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AuditEvent) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditEventList) DeepCopyInto(out *AuditEventList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AuditEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditEventList.
+func (in *AuditEventList) DeepCopy() *AuditEventList {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditEventList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AuditEventList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuditEventSpec) DeepCopyInto(out *AuditEventSpec) {
+	*out = *in
+	out.Actor = in.Actor
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	if in.ObjectRefs != nil {
+		in, out := &in.ObjectRefs, &out.ObjectRefs
+		*out = make([]corev1.ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuditEventSpec.
+func (in *AuditEventSpec) DeepCopy() *AuditEventSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AuditEventSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Coupon) DeepCopyInto(out *Coupon) {
 	*out = *in
@@ -384,6 +485,107 @@ func (in *EmailTemplateStatus) DeepCopy() *EmailTemplateStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Invitation) DeepCopyInto(out *Invitation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Invitation.
+func (in *Invitation) DeepCopy() *Invitation {
+	if in == nil {
+		return nil
+	}
+	out := new(Invitation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Invitation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationList) DeepCopyInto(out *InvitationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Invitation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationList.
+func (in *InvitationList) DeepCopy() *InvitationList {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InvitationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationSpec) DeepCopyInto(out *InvitationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationSpec.
+func (in *InvitationSpec) DeepCopy() *InvitationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationStatus) DeepCopyInto(out *InvitationStatus) {
+	*out = *in
+	in.ExpireTimestamp.DeepCopyInto(&out.ExpireTimestamp)
+	if in.EmailRef != nil {
+		in, out := &in.EmailRef, &out.EmailRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.UserRef != nil {
+		in, out := &in.UserRef, &out.UserRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	in.AcceptedTimestamp.DeepCopyInto(&out.AcceptedTimestamp)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationStatus.
+func (in *InvitationStatus) DeepCopy() *InvitationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Licence) DeepCopyInto(out *Licence) {
 	*out = *in
@@ -411,6 +613,23 @@ func (in *Licence) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicenceActivation) DeepCopyInto(out *LicenceActivation) {
+	*out = *in
+	in.ActivationTimestamp.DeepCopyInto(&out.ActivationTimestamp)
+	in.LastSeenTimestamp.DeepCopyInto(&out.LastSeenTimestamp)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenceActivation.
+func (in *LicenceActivation) DeepCopy() *LicenceActivation {
+	if in == nil {
+		return nil
+	}
+	out := new(LicenceActivation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LicenceList) DeepCopyInto(out *LicenceList) {
 	*out = *in
@@ -475,6 +694,14 @@ func (in *LicenceStatus) DeepCopyInto(out *LicenceStatus) {
 		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
+	if in.Activations != nil {
+		in, out := &in.Activations, &out.Activations
+		*out = make([]LicenceActivation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.SuspendedTimestamp.DeepCopyInto(&out.SuspendedTimestamp)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenceStatus.
@@ -791,6 +1018,11 @@ func (in *ProductSpec) DeepCopyInto(out *ProductSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Plans != nil {
+		in, out := &in.Plans, &out.Plans
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProductSpec.
@@ -994,6 +1226,15 @@ func (in *RegistryTokenStatus) DeepCopyInto(out *RegistryTokenStatus) {
 		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
+	in.IssueTimestamp.DeepCopyInto(&out.IssueTimestamp)
+	if in.PreviousTokenRef != nil {
+		in, out := &in.PreviousTokenRef, &out.PreviousTokenRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	in.PreviousExpireTimestamp.DeepCopyInto(&out.PreviousExpireTimestamp)
+	in.ExpiredTimestamp.DeepCopyInto(&out.ExpiredTimestamp)
+	in.TokenExpireTimestamp.DeepCopyInto(&out.TokenExpireTimestamp)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryTokenStatus.
@@ -1081,6 +1322,109 @@ func (in *TenantList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantProfile) DeepCopyInto(out *TenantProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantProfile.
+func (in *TenantProfile) DeepCopy() *TenantProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TenantProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantProfileList) DeepCopyInto(out *TenantProfileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TenantProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantProfileList.
+func (in *TenantProfileList) DeepCopy() *TenantProfileList {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantProfileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TenantProfileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantProfileSpec) DeepCopyInto(out *TenantProfileSpec) {
+	*out = *in
+	if in.Hard != nil {
+		in, out := &in.Hard, &out.Hard
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = make([]corev1.LimitRangeItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantProfileSpec.
+func (in *TenantProfileSpec) DeepCopy() *TenantProfileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantProfileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TenantProfileStatus) DeepCopyInto(out *TenantProfileStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantProfileStatus.
+func (in *TenantProfileStatus) DeepCopy() *TenantProfileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TenantProfileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TenantSpec) DeepCopyInto(out *TenantSpec) {
 	*out = *in
@@ -1101,9 +1445,23 @@ func (in *TenantStatus) DeepCopyInto(out *TenantStatus) {
 	*out = *in
 	if in.TenantRefs != nil {
 		in, out := &in.TenantRefs, &out.TenantRefs
-		*out = make([]corev1.LocalObjectReference, len(*in))
+		*out = make([]corev1.ObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	in.SuspendedTimestamp.DeepCopyInto(&out.SuspendedTimestamp)
+	in.DeletionScheduledTimestamp.DeepCopyInto(&out.DeletionScheduledTimestamp)
+	if in.ExportRef != nil {
+		in, out := &in.ExportRef, &out.ExportRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TenantStatus.
@@ -1203,6 +1561,14 @@ func (in *UserStatus) DeepCopyInto(out *UserStatus) {
 		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
+	if in.MFARef != nil {
+		in, out := &in.MFARef, &out.MFARef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	in.LastFailedLoginTimestamp.DeepCopyInto(&out.LastFailedLoginTimestamp)
+	in.LockedUntilTimestamp.DeepCopyInto(&out.LockedUntilTimestamp)
+	in.PhoneNumberVerifiedTimestamp.DeepCopyInto(&out.PhoneNumberVerifiedTimestamp)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserStatus.
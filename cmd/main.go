@@ -21,6 +21,7 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -43,6 +44,10 @@ import (
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
 	apiservicev1 "github.com/HariKube/example-webshop-service/internal/api/v1"
 	"github.com/HariKube/example-webshop-service/internal/controller"
+	"github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/sms"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+	"github.com/HariKube/example-webshop-service/internal/vat"
 	webhookv1 "github.com/HariKube/example-webshop-service/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
@@ -65,6 +70,19 @@ func main() {
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var apiServiceCertPath, apiServiceCertName, apiServiceCertKey string
+	var registryHost string
+	var registryTokenCertPath, registryTokenCertName, registryTokenCertKey, registryTokenIssuer, registryTokenBindAddress string
+	var loginTokenTTL, loginLockoutDuration, verificationTTL, passwordResetTTL time.Duration
+	var loginMaxAttempts, loginMaxAttemptsPerIP int
+	var passwordHashMemory, passwordHashIterations, passwordHashParallelism uint
+	var verificationURL, passwordResetURL, invitationURL string
+	var invitationTTL time.Duration
+	var defaultTenantProfile string
+	var tenantDeletionGracePeriod, tenantRetention time.Duration
+	var tenantNamespaceNaming, tenantNamespacePrefix string
+	var smsSender, smsFile string
+	var vatVerifier string
+	var registryTokenTTL, registryTokenMaxLifetime, registryTokenRetention, registryTokenRotateBefore, registryTokenRotationOverlap time.Duration
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
@@ -84,6 +102,61 @@ func main() {
 	flag.StringVar(&apiServiceCertPath, "api-service-cert-path", "", "The directory that contains the api-service certificate.")
 	flag.StringVar(&apiServiceCertName, "api-service-cert-name", "tls.crt", "The name of the api-service certificate file.")
 	flag.StringVar(&apiServiceCertKey, "api-service-cert-key", "tls.key", "The name of the api-service key file.")
+	flag.DurationVar(&loginTokenTTL, "login-token-ttl", time.Hour,
+		"The lifetime of the ServiceAccount tokens issued on login, the TokenRequest API requires at least 10m.")
+	flag.IntVar(&loginMaxAttempts, "login-max-attempts", 5, "The number of consecutive failed logins locking a user.")
+	flag.IntVar(&loginMaxAttemptsPerIP, "login-max-attempts-per-ip", 20, "The number of failed logins blocking a source IP.")
+	flag.DurationVar(&loginLockoutDuration, "login-lockout-duration", 15*time.Minute,
+		"The time users and source IPs stay blocked after too many failed logins.")
+	flag.UintVar(&passwordHashMemory, "password-hash-memory", 64*1024,
+		"The argon2id memory cost of new password hashes in KiB, weaker hashes are replaced on login.")
+	flag.UintVar(&passwordHashIterations, "password-hash-iterations", 3, "The argon2id time cost of new password hashes.")
+	flag.UintVar(&passwordHashParallelism, "password-hash-parallelism", 2, "The argon2id parallelism of new password hashes.")
+	flag.StringVar(&invitationURL, "invitation-url", "https://harikube.info/accept-invitation",
+		"The address of the page accepting tenant invitations, the token is appended as query parameter.")
+	flag.DurationVar(&invitationTTL, "invitation-ttl", 7*24*time.Hour, "The time tenant invitations can be accepted in.")
+	flag.StringVar(&defaultTenantProfile, "default-tenant-profile", "default",
+		"The TenantProfile of tenants without their own or one named after their plan, built-in guardrails apply if it does not exist.")
+	flag.DurationVar(&tenantDeletionGracePeriod, "tenant-deletion-grace-period", 72*time.Hour,
+		"The time the requested deletion of a tenant can be cancelled within.")
+	flag.DurationVar(&tenantRetention, "tenant-retention", 90*24*time.Hour,
+		"The time the data exports and deletion confirmations of deleted tenants are kept, zero keeps them forever.")
+	flag.StringVar(&tenantNamespaceNaming, "tenant-namespace-naming", "name",
+		"The naming of new tenant namespaces, \"name\" names them after the tenant, \"slug\" after its company name "+
+			"and \"prefix\" joins --tenant-namespace-prefix and a short ID of the tenant.")
+	flag.StringVar(&tenantNamespacePrefix, "tenant-namespace-prefix", "tenant-",
+		"The prefix of the tenant namespaces named by the \"slug\" and \"prefix\" namings.")
+	flag.StringVar(&vatVerifier, "vat-verifier", "offline",
+		"The service confirming the VAT numbers of tenants, \"offline\" accepts every number passing the format and check digits.")
+	flag.StringVar(&smsSender, "sms-sender", "log",
+		"The sender of the phone number verification codes, \"log\" writes them to the log, \"file\" appends them to --sms-file.")
+	flag.StringVar(&smsFile, "sms-file", "", "The file the file sms sender appends the messages to.")
+	flag.StringVar(&verificationURL, "verification-url", "https://harikube.info/verify",
+		"The address of the page verifying email addresses, the token is appended as query parameter.")
+	flag.DurationVar(&verificationTTL, "verification-ttl", 48*time.Hour, "The lifetime of the email verification tokens.")
+	flag.StringVar(&passwordResetURL, "password-reset-url", "https://harikube.info/reset-password",
+		"The address of the page completing password resets, the token is appended as query parameter.")
+	flag.DurationVar(&passwordResetTTL, "password-reset-ttl", time.Hour, "The lifetime of the password reset tokens.")
+	flag.StringVar(&registryHost, "registry-host", "registry.harikube.info", "The host of the container registry the registry tokens are issued for.")
+	flag.StringVar(&registryTokenCertPath, "registry-token-cert-path", "",
+		"The directory that contains the certificate signing the registry bearer tokens. Leave empty to disable the token endpoint.")
+	flag.StringVar(&registryTokenCertName, "registry-token-cert-name", "tls.crt", "The name of the registry token certificate file.")
+	flag.StringVar(&registryTokenCertKey, "registry-token-cert-key", "tls.key", "The name of the registry token key file.")
+	flag.StringVar(&registryTokenBindAddress, "registry-token-bind-address", ":7445",
+		"The address the registry token endpoint binds to at /token. Registry clients connect to it directly, "+
+			"the kube-apiserver does not forward their basic auth credentials to aggregated APIs.")
+	flag.StringVar(&registryTokenIssuer, "registry-token-issuer", "example-webshop-service",
+		"The issuer of the registry bearer tokens, it must match the issuer configured in the registry.")
+	flag.DurationVar(&registryTokenTTL, "registry-token-ttl", 5*time.Minute, "The lifetime of the registry bearer tokens.")
+	flag.DurationVar(&registryTokenMaxLifetime, "registry-token-max-lifetime", 365*24*time.Hour,
+		"The maximum lifetime of RegistryTokens in tenants without the "+
+			"product.webshop.harikube.info/registry-token-max-lifetime Namespace annotation, 0 disables the limit.")
+	flag.DurationVar(&registryTokenRetention, "registry-token-retention", 30*24*time.Hour,
+		"The time expired RegistryTokens are kept before deletion.")
+	flag.DurationVar(&registryTokenRotateBefore, "registry-token-rotate-before", 7*24*time.Hour,
+		"The time before expiry auto-rotating RegistryTokens are rotated.")
+	flag.DurationVar(&registryTokenRotationOverlap, "registry-token-rotation-overlap", 24*time.Hour,
+		"The time the previous credential of a rotated RegistryToken stays valid.")
 	flag.StringVar(&metricsCertPath, "metrics-cert-path", "",
 		"The directory that contains the metrics server certificate.")
 	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
@@ -98,6 +171,22 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if passwordHashIterations == 0 || passwordHashParallelism == 0 || passwordHashParallelism > 255 ||
+		passwordHashMemory < 8*passwordHashParallelism || passwordHashMemory > 1<<32-1 {
+		setupLog.Error(nil, "Invalid password hash parameters", "memory", passwordHashMemory,
+			"iterations", passwordHashIterations, "parallelism", passwordHashParallelism)
+		os.Exit(1)
+	}
+	password.DefaultParams.Memory = uint32(passwordHashMemory)
+	password.DefaultParams.Iterations = uint32(passwordHashIterations)
+	password.DefaultParams.Parallelism = uint8(passwordHashParallelism)
+
+	if loginTokenTTL <= 0 || loginLockoutDuration <= 0 {
+		setupLog.Error(nil, "Invalid login durations, they must be positive", "loginTokenTTL", loginTokenTTL,
+			"loginLockoutDuration", loginLockoutDuration)
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -230,6 +319,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &productv1.User{}, "metadata.uid", func(rawObj client.Object) []string {
+		user := rawObj.(*productv1.User)
+		return []string{string(user.UID)}
+	}); err != nil {
+		setupLog.Error(err, "unable to serup indexer")
+		os.Exit(1)
+	}
+
 	if err := (&controller.OrderReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -245,8 +342,14 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&controller.RegistryTokenReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Namespace:       os.Getenv("POD_NAMESPACE"),
+		Registry:        registryHost,
+		Retention:       registryTokenRetention,
+		RotateBefore:    registryTokenRotateBefore,
+		RotationOverlap: registryTokenRotationOverlap,
+		MaxLifetime:     registryTokenMaxLifetime,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "RegistryToken")
 		os.Exit(1)
@@ -258,9 +361,19 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	naming, err := tenancy.NewNaming(tenantNamespaceNaming, tenantNamespacePrefix)
+	if err != nil {
+		setupLog.Error(err, "unable to create tenant namespace naming")
+		os.Exit(1)
+	}
 	if err := (&controller.TenantReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		DefaultProfile:      defaultTenantProfile,
+		Namespace:           os.Getenv("POD_NAMESPACE"),
+		DeletionGracePeriod: tenantDeletionGracePeriod,
+		Retention:           tenantRetention,
+		Naming:              naming,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tenant")
 		os.Exit(1)
@@ -280,13 +393,31 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&controller.RegistrationRequestReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		Namespace:       os.Getenv("POD_NAMESPACE"),
+		VerificationURL: verificationURL,
+		VerificationTTL: verificationTTL,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RegistrationRequest")
+		os.Exit(1)
+	}
+	if err := (&controller.InvitationReconciler{
 		Client:    mgr.GetClient(),
 		Scheme:    mgr.GetScheme(),
 		Namespace: os.Getenv("POD_NAMESPACE"),
+		AcceptURL: invitationURL,
+		TTL:       invitationTTL,
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "RegistrationRequest")
+		setupLog.Error(err, "unable to create controller", "controller", "Invitation")
 		os.Exit(1)
 	}
+	verifier, err := vat.New(vatVerifier)
+	if err != nil {
+		setupLog.Error(err, "unable to create vat verifier")
+		os.Exit(1)
+	}
+
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err := webhookv1.SetupOrderWebhookWithManager(mgr); err != nil {
@@ -303,14 +434,21 @@ func main() {
 	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err := webhookv1.SetupRegistrationRequestWebhookWithManager(mgr); err != nil {
+		if err := webhookv1.SetupRegistrationRequestWebhookWithManager(mgr, verifier); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "RegistrationRequest")
 			os.Exit(1)
 		}
 	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err := webhookv1.SetupTenantWebhookWithManager(mgr); err != nil {
+		if err := webhookv1.SetupRegistryTokenWebhookWithManager(mgr, registryTokenMaxLifetime); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RegistryToken")
+			os.Exit(1)
+		}
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1.SetupTenantWebhookWithManager(mgr, verifier); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Tenant")
 			os.Exit(1)
 		}
@@ -322,6 +460,13 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhookv1.SetupInvitationWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Invitation")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -340,7 +485,50 @@ func main() {
 		}
 	}
 
-	if err := mgr.Add(apiservicev1.New(dynamicKubeClient, mgr.GetScheme(), ":7443", apiServiceCertPath, apiServiceCertName, apiServiceCertKey, os.Getenv("POD_NAMESPACE"))); err != nil {
+	apiService := apiservicev1.New(mgr.GetClient(), dynamicKubeClient, mgr.GetScheme(), ":7443", apiServiceCertPath, apiServiceCertName, apiServiceCertKey, os.Getenv("POD_NAMESPACE"))
+	apiService.Reader = mgr.GetAPIReader()
+	apiService.LoginTTL = loginTokenTTL
+	apiService.LoginProtection.MaxAttempts = int32(loginMaxAttempts)
+	apiService.LoginProtection.MaxAttemptsPerIP = int32(loginMaxAttemptsPerIP)
+	apiService.LoginProtection.LockoutDuration = loginLockoutDuration
+	apiService.PasswordReset = apiservicev1.PasswordResetConfig{
+		URL: passwordResetURL,
+		TTL: passwordResetTTL,
+	}
+
+	apiService.SMS, err = sms.New(smsSender, smsFile, ctrl.Log.WithName("sms"))
+	if err != nil {
+		setupLog.Error(err, "unable to create sms sender")
+		os.Exit(1)
+	}
+
+	if len(registryTokenCertPath) > 0 {
+		setupLog.Info("Initializing registry token certificate watcher using provided certificates",
+			"registry-token-cert-path", registryTokenCertPath, "registry-token-cert-name", registryTokenCertName, "registry-token-cert-key", registryTokenCertKey)
+
+		registryTokenCertWatcher, err := certwatcher.New(
+			filepath.Join(registryTokenCertPath, registryTokenCertName),
+			filepath.Join(registryTokenCertPath, registryTokenCertKey),
+		)
+		if err != nil {
+			setupLog.Error(err, "Failed to initialize registry token certificate watcher")
+			os.Exit(1)
+		}
+
+		if err := mgr.Add(registryTokenCertWatcher); err != nil {
+			setupLog.Error(err, "unable to add registry token certificate watcher to manager")
+			os.Exit(1)
+		}
+
+		apiService.RegistryToken = &apiservicev1.RegistryTokenConfig{
+			Addr:           registryTokenBindAddress,
+			Issuer:         registryTokenIssuer,
+			TTL:            registryTokenTTL,
+			GetCertificate: registryTokenCertWatcher.GetCertificate,
+		}
+	}
+
+	if err := mgr.Add(apiService); err != nil {
 		setupLog.Error(err, "unable to add API service to manager")
 		os.Exit(1)
 	}
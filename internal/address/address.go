@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package address validates the postal addresses of tenants.
+package address
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// postalCodes holds the postal code formats of the countries we invoice most, other countries accept any code.
+var postalCodes = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"BG": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJ-NPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CY": regexp.MustCompile(`^\d{4}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"EE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"GR": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"HR": regexp.MustCompile(`^\d{5}$`),
+	"HU": regexp.MustCompile(`^\d{4}$`),
+	"IE": regexp.MustCompile(`^([AC-FHKNPRTV-Y]\d{2}|D6W) ?[\dAC-FHKNPRTV-Y]{4}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"LT": regexp.MustCompile(`^(LT-)?\d{5}$`),
+	"LU": regexp.MustCompile(`^(L-)?\d{4}$`),
+	"LV": regexp.MustCompile(`^(LV-)?\d{4}$`),
+	"MT": regexp.MustCompile(`^[A-Z]{3} ?\d{2,4}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"RO": regexp.MustCompile(`^\d{6}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SI": regexp.MustCompile(`^(SI-)?\d{4}$`),
+	"SK": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country given by its alpha-2 or alpha-3 code.
+func Country(code string) (string, error) {
+	code = strings.ToUpper(code)
+	switch len(code) {
+	case 2:
+		if _, ok := countries[code]; ok {
+			return code, nil
+		}
+	case 3:
+		for alpha2, alpha3 := range countries {
+			if alpha3 == code {
+				return alpha2, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%q is not an ISO 3166-1 country code", code)
+}
+
+// ValidatePostalCode checks the postal code against the format of the country given by its alpha-2 code.
+func ValidatePostalCode(country, postalCode string) error {
+	format, ok := postalCodes[country]
+	if !ok {
+		return nil
+	}
+
+	if !format.MatchString(strings.ToUpper(strings.TrimSpace(postalCode))) {
+		return fmt.Errorf("%q is not a valid postal code in %s", postalCode, country)
+	}
+
+	return nil
+}
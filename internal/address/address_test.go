@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package address
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Address", func() {
+	DescribeTable("should accept postal codes in the format of the country",
+		func(country, postalCode string) {
+			Expect(ValidatePostalCode(country, postalCode)).To(Succeed())
+		},
+		Entry("Austria", "AT", "1010"),
+		Entry("Brazil with the dash", "BR", "01310-100"),
+		Entry("Brazil without the dash", "BR", "01310100"),
+		Entry("Canada", "CA", "K1A 0B1"),
+		Entry("Canada in lower case", "CA", "k1a0b1"),
+		Entry("Czechia with the space", "CZ", "110 00"),
+		Entry("Germany", "DE", "10115"),
+		Entry("United Kingdom", "GB", "SW1A 1AA"),
+		Entry("United Kingdom short outward code", "GB", "M1 1AE"),
+		Entry("Hungary", "HU", "1051"),
+		Entry("Hungary with surrounding spaces", "HU", " 1051 "),
+		Entry("Ireland", "IE", "D02 X285"),
+		Entry("Ireland Dublin 6W", "IE", "D6W XY12"),
+		Entry("Japan", "JP", "100-0001"),
+		Entry("Lithuania with the prefix", "LT", "LT-01100"),
+		Entry("Luxembourg without the prefix", "LU", "1009"),
+		Entry("Malta", "MT", "VLT 1117"),
+		Entry("Netherlands", "NL", "1012 AB"),
+		Entry("Poland", "PL", "00-950"),
+		Entry("Portugal", "PT", "1000-001"),
+		Entry("Sweden", "SE", "114 55"),
+		Entry("United States", "US", "94105"),
+		Entry("United States ZIP+4", "US", "94105-1804"),
+		Entry("a country without a known format", "NZ", "anything"),
+	)
+
+	DescribeTable("should reject postal codes not in the format of the country",
+		func(country, postalCode string) {
+			Expect(ValidatePostalCode(country, postalCode)).To(MatchError(ContainSubstring("is not a valid postal code in " + country)))
+		},
+		Entry("Austria too long", "AT", "10101"),
+		Entry("Canada with a D", "CA", "D1A 0B1"),
+		Entry("Germany too short", "DE", "1011"),
+		Entry("United Kingdom without the inward code", "GB", "SW1A"),
+		Entry("Hungary with letters", "HU", "10A1"),
+		Entry("Ireland with an invalid routing key", "IE", "B02 X285"),
+		Entry("Netherlands without the letters", "NL", "1012"),
+		Entry("Poland without the dash", "PL", "00950"),
+		Entry("Portugal without the dash", "PT", "1000001"),
+		Entry("United States ZIP+3", "US", "94105-180"),
+		Entry("empty code", "HU", ""),
+	)
+
+	DescribeTable("should resolve country codes to alpha-2",
+		func(code, country string) {
+			Expect(Country(code)).To(Equal(country))
+		},
+		Entry("alpha-2", "HU", "HU"),
+		Entry("alpha-2 in lower case", "de", "DE"),
+		Entry("alpha-3", "HUN", "HU"),
+		Entry("alpha-3 in lower case", "usa", "US"),
+	)
+
+	DescribeTable("should reject unknown country codes",
+		func(code string) {
+			_, err := Country(code)
+			Expect(err).To(MatchError(ContainSubstring("is not an ISO 3166-1 country code")))
+		},
+		Entry("unassigned alpha-2", "XX"),
+		Entry("unassigned alpha-3", "XXX"),
+		Entry("empty", ""),
+		Entry("too long", "HUNG"),
+	)
+})
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=auditevents,verbs=get;list;watch;create
+
+// recordUserEvent records an action the User performed on itself. The audit trail must not fail the action,
+// a failed recording is logged only.
+func (s *ApiService) recordUserEvent(r *http.Request, user *productv1.User, action, message string) {
+	if err := audit.Record(r.Context(), s.Client, user.Namespace, "", productv1.AuditEventSpec{
+		Action:     action,
+		Actor:      audit.UserActor(user),
+		ObjectRefs: []corev1.ObjectReference{audit.Ref("User", user)},
+		Message:    message,
+	}); err != nil {
+		apiServiceLog.Error(err, "AuditEvent creation failed", "namespace", user.Namespace, "action", action)
+	}
+}
+
+func (s *ApiService) tenantAudit(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "tenants/audit", "method", r.Method, "path", r.URL.Path)
+	log.Info("Tenant audit endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	tenantName := query.Get("tenant")
+	if tenantName == "" {
+		http.Error(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("tenant", tenantName)
+
+	since, until := time.Time{}, time.Time{}
+	for param, value := range map[string]*time.Time{"since": &since, "until": &until} {
+		if raw := query.Get(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				http.Error(w, param+" must be an RFC3339 timestamp: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*value = parsed
+		}
+	}
+	action := query.Get("action")
+
+	// The tenant is only looked up for users who may read it, so a 404 does not tell others which tenants exist.
+	authorized := func(attributes *authorizationv1.ResourceAttributes) bool {
+		err := s.authorize(r, attributes)
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Tenant audit forbidden", "user", r.Header.Get("X-Remote-User"), "resource", attributes.Resource)
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+
+	if !authorized(&authorizationv1.ResourceAttributes{
+		Verb:     "get",
+		Group:    productv1.GroupVersion.Group,
+		Resource: "tenants",
+		Name:     tenantName,
+	}) {
+		return
+	}
+
+	tenant := productv1.Tenant{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: tenantName}, &tenant); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+
+		log.Error(err, "Tenant fetch failed")
+		http.Error(w, "failed to get tenant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	namespace, err := tenancy.NamespaceOf(r.Context(), s.Client, &tenant)
+	if err != nil {
+		log.Error(err, "Namespace lookup failed")
+		http.Error(w, "failed to find tenant namespace: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if namespace == "" {
+		http.Error(w, "tenant has no namespace yet", http.StatusNotFound)
+		return
+	}
+
+	if !authorized(&authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "list",
+		Group:     productv1.GroupVersion.Group,
+		Resource:  "auditevents",
+	}) {
+		return
+	}
+
+	events := productv1.AuditEventList{}
+	if err := s.Client.List(r.Context(), &events, client.InNamespace(namespace)); err != nil {
+		log.Error(err, "AuditEvent fetch failed")
+		http.Error(w, "failed to list audit events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	events.Items = slices.DeleteFunc(events.Items, func(event productv1.AuditEvent) bool {
+		return (!since.IsZero() && event.Spec.Timestamp.Time.Before(since)) ||
+			(!until.IsZero() && !event.Spec.Timestamp.Time.Before(until)) ||
+			(action != "" && event.Spec.Action != action)
+	})
+	slices.SortStableFunc(events.Items, func(a, b productv1.AuditEvent) int {
+		return a.Spec.Timestamp.Time.Compare(b.Spec.Timestamp.Time)
+	})
+	events.SetGroupVersionKind(productv1.GroupVersion.WithKind("AuditEventList"))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&events)
+	log.Info("Tenant audit served", "events", len(events.Items))
+}
@@ -0,0 +1,111 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Tenant audit endpoint", func() {
+	var (
+		server     *httptest.Server
+		proxied    *http.Client
+		url        string
+		recordedAt time.Time
+	)
+
+	event := func(name, action string, at time.Time) *productv1.AuditEvent {
+		return &productv1.AuditEvent{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name},
+			Spec: productv1.AuditEventSpec{
+				Action:    action,
+				Actor:     productv1.AuditActor{Username: "alice"},
+				Timestamp: metav1.NewTime(at),
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		recordedAt = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+		tenant := &productv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "acme"}}
+		tenant.Status.Namespace = "tenant-acme"
+
+		// carol may read the tenant, but not its audit events.
+		allowed := allowUsers("alice")
+		service := newTestService(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok && review.Spec.User == "carol" {
+					review.Status.Allowed = review.Spec.ResourceAttributes.Resource == "tenants"
+					return nil
+				}
+				return allowed.Create(ctx, c, obj, opts...)
+			},
+		}, tenant,
+			event("login", "Login", recordedAt),
+			event("order", "OrderPlaced", recordedAt.Add(time.Hour)),
+			event("earlier-login", "Login", recordedAt.Add(-time.Hour)))
+		proxyCert := trustFrontProxy(service)
+
+		server = serveTLS(service, service.tenantAudit)
+		proxied = clientOf(server, proxyCert)
+		url = server.URL + "/audit?tenant=acme"
+	})
+
+	It("should reject forged identity headers without the front proxy certificate", func() {
+		status, _ := do(clientOf(server), remoteRequest(http.MethodGet, url, "alice", "system:masters"))
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should forbid users who may not list the audit events of the tenant", func() {
+		status, _ := do(proxied, remoteRequest(http.MethodGet, url, "bob"))
+		Expect(status).To(Equal(http.StatusForbidden))
+
+		status, _ = do(proxied, remoteRequest(http.MethodGet, url, "carol"))
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should not reveal unknown tenants to users who may not read them", func() {
+		status, _ := do(proxied, remoteRequest(http.MethodGet, server.URL+"/audit?tenant=globex", "bob"))
+		Expect(status).To(Equal(http.StatusForbidden))
+
+		status, _ = do(proxied, remoteRequest(http.MethodGet, server.URL+"/audit?tenant=globex", "alice"))
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should serve the events of the action in the time range", func() {
+		status, body := do(proxied, remoteRequest(http.MethodGet,
+			url+"&action=Login&since="+recordedAt.Add(-time.Minute).Format(time.RFC3339), "alice"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		events := productv1.AuditEventList{}
+		Expect(json.Unmarshal(body, &events)).To(Succeed())
+		Expect(events.Items).To(HaveLen(1))
+		Expect(events.Items[0].Name).To(Equal("login"))
+	})
+
+	It("should serve all events in chronological order", func() {
+		status, body := do(proxied, remoteRequest(http.MethodGet, url, "alice"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		events := productv1.AuditEventList{}
+		Expect(json.Unmarshal(body, &events)).To(Succeed())
+		names := []string{}
+		for _, item := range events.Items {
+			names = append(names, item.Name)
+		}
+		Expect(names).To(Equal([]string{"earlier-login", "login", "order"}))
+	})
+})
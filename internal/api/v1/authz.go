@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// +kubebuilder:rbac:groups="authorization.k8s.io",resources=subjectaccessreviews,verbs=create
+
+var (
+	errForbidden = errors.New("forbidden")
+)
+
+// authorize checks with a SubjectAccessReview whether the caller proxied by the kube-apiserver may perform the action.
+// The identity is taken from the request headers the kube-apiserver sets on aggregated requests, frontProxyHandler
+// drops them from requests without the front proxy client certificate.
+func (s *ApiService) authorize(r *http.Request, attributes *authorizationv1.ResourceAttributes) error {
+	username := r.Header.Get("X-Remote-User")
+	if username == "" {
+		return errUnauthenticated
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range remoteExtra(r) {
+		extra[key] = values
+	}
+
+	review := authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               username,
+			Groups:             r.Header.Values("X-Remote-Group"),
+			Extra:              extra,
+			ResourceAttributes: attributes,
+		},
+	}
+	if err := s.Client.Create(r.Context(), &review); err != nil {
+		return err
+	} else if !review.Status.Allowed {
+		return errForbidden
+	}
+
+	return nil
+}
+
+// remoteExtra returns the user info extras of the caller, the kube-apiserver sends them lowercased and
+// path escaped in X-Remote-Extra-* headers.
+func remoteExtra(r *http.Request) map[string][]string {
+	extra := map[string][]string{}
+	for name, values := range r.Header {
+		if key, ok := strings.CutPrefix(name, "X-Remote-Extra-"); ok {
+			key = strings.ToLower(key)
+			if unescaped, err := url.PathUnescape(key); err == nil {
+				key = unescaped
+			}
+			extra[key] = values
+		}
+	}
+
+	return extra
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/export"
+)
+
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants;users;invitations;orders;payments;licences;emails;registrytokens,verbs=get;list;watch
+
+func (s *ApiService) exportTenant(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "tenants/export", "method", r.Method, "path", r.URL.Path)
+	log.Info("Tenant export endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	tenantName := query.Get("tenant")
+	if tenantName == "" {
+		http.Error(w, "tenant is required", http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	} else if format != "json" && format != "zip" {
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("tenant", tenantName, "format", format)
+
+	// The owners of the tenant are granted the export subresource of their Tenant by the User controller.
+	if err := s.authorize(r, &authorizationv1.ResourceAttributes{
+		Verb:        "get",
+		Group:       productv1.GroupVersion.Group,
+		Resource:    "tenants",
+		Subresource: "export",
+		Name:        tenantName,
+	}); err != nil {
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Tenant export forbidden", "user", r.Header.Get("X-Remote-User"))
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tenant := productv1.Tenant{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Name: tenantName}, &tenant); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+
+		log.Error(err, "Tenant fetch failed")
+		http.Error(w, "failed to get tenant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	doc, err := export.Collect(r.Context(), s.Client, &tenant)
+	if err != nil {
+		log.Error(err, "Tenant export failed")
+		http.Error(w, "failed to export tenant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filename := tenant.Name + "-export-" + doc.ExportTimestamp.UTC().Format("20060102T150405Z")
+	if format == "zip" {
+		archive, err := export.Zip(doc)
+		if err != nil {
+			log.Error(err, "Tenant export encoding failed")
+			http.Error(w, "failed to encode export: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(archive)
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+	log.Info("Tenant export served", "users", len(doc.Users), "orders", len(doc.Orders))
+}
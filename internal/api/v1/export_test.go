@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/export"
+)
+
+var _ = Describe("Tenant export endpoint", func() {
+	var (
+		server  *httptest.Server
+		proxied *http.Client
+		url     string
+	)
+
+	BeforeEach(func() {
+		tenant := &productv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "acme"}}
+		tenant.Status.Namespace = "tenant-acme"
+		user := &productv1.User{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "alice"},
+			Spec:       productv1.UserSpec{Email: "alice@example.com"},
+		}
+
+		service := newTestService(allowUsers("alice"), tenant, user)
+		proxyCert := trustFrontProxy(service)
+
+		server = serveTLS(service, service.exportTenant)
+		proxied = clientOf(server, proxyCert)
+		url = server.URL + "/export?tenant=acme"
+	})
+
+	It("should reject forged identity headers without the front proxy certificate", func() {
+		status, _ := do(clientOf(server), remoteRequest(http.MethodGet, url, "alice", "system:masters"))
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should forbid users who are not granted the export of the tenant", func() {
+		status, _ := do(proxied, remoteRequest(http.MethodGet, url, "bob"))
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should serve the export as JSON", func() {
+		status, body := do(proxied, remoteRequest(http.MethodGet, url, "alice"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		doc := export.Document{}
+		Expect(json.Unmarshal(body, &doc)).To(Succeed())
+		Expect(doc.Name).To(Equal("acme"))
+		Expect(doc.Users).To(HaveLen(1))
+		Expect(doc.Users[0].Spec.Email).To(Equal("alice@example.com"))
+	})
+
+	It("should serve the export as ZIP", func() {
+		status, body := do(proxied, remoteRequest(http.MethodGet, url+"&format=zip", "alice"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archive.File).NotTo(BeEmpty())
+	})
+
+	It("should reject unknown formats", func() {
+		status, _ := do(proxied, remoteRequest(http.MethodGet, url+"&format=xml", "alice"))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,213 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+const (
+	// authenticationConfigMapNamespace and authenticationConfigMapName locate the front proxy trust
+	// the kube-apiserver publishes for aggregated API servers.
+	authenticationConfigMapNamespace = "kube-system"
+	authenticationConfigMapName      = "extension-apiserver-authentication"
+	requestHeaderClientCAKey         = "requestheader-client-ca-file"
+	requestHeaderAllowedNamesKey     = "requestheader-allowed-names"
+
+	// requestHeaderRefreshInterval is how often the front proxy trust is reloaded to follow CA rotation.
+	requestHeaderRefreshInterval = time.Minute
+
+	// backendAddr is the loopback address of the aggregation server, only the front proxy listener is exposed.
+	backendAddr = "127.0.0.1:7444"
+)
+
+var (
+	errUntrustedProxy = errors.New("client certificate is not a trusted front proxy")
+)
+
+// requestHeaderAuthentication is the trust placed in the kube-apiserver front proxy, the X-Remote-* headers
+// of a request are only honoured when it presents a client certificate signed by ClientCAs.
+type requestHeaderAuthentication struct {
+	ClientCAs *x509.CertPool
+	// AllowedNames are the accepted common names of the front proxy certificate, any name is accepted when empty.
+	AllowedNames []string
+}
+
+// loadRequestHeaderAuthentication reads the front proxy client CA and allowed names from the
+// extension-apiserver-authentication ConfigMap.
+func loadRequestHeaderAuthentication(ctx context.Context, reader client.Reader) (*requestHeaderAuthentication, error) {
+	configMap := corev1.ConfigMap{}
+	if err := reader.Get(ctx, types.NamespacedName{
+		Namespace: authenticationConfigMapNamespace,
+		Name:      authenticationConfigMapName,
+	}, &configMap); err != nil {
+		return nil, err
+	}
+
+	caBundle := configMap.Data[requestHeaderClientCAKey]
+	if caBundle == "" {
+		return nil, fmt.Errorf("%s/%s has no %s", authenticationConfigMapNamespace, authenticationConfigMapName, requestHeaderClientCAKey)
+	}
+
+	authn := requestHeaderAuthentication{
+		ClientCAs: x509.NewCertPool(),
+	}
+	if !authn.ClientCAs.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, fmt.Errorf("failed to parse %s", requestHeaderClientCAKey)
+	}
+	if names := configMap.Data[requestHeaderAllowedNamesKey]; names != "" {
+		if err := json.Unmarshal([]byte(names), &authn.AllowedNames); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", requestHeaderAllowedNamesKey, err)
+		}
+	}
+
+	return &authn, nil
+}
+
+// verify checks that the request was sent with a client certificate of the front proxy.
+func (a *requestHeaderAuthentication) verify(r *http.Request) error {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return errUntrustedProxy
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, certificate := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.ClientCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return fmt.Errorf("%w: %w", errUntrustedProxy, err)
+	}
+
+	if len(a.AllowedNames) != 0 && !slices.Contains(a.AllowedNames, leaf.Subject.CommonName) {
+		return fmt.Errorf("%w: common name %q is not allowed", errUntrustedProxy, leaf.Subject.CommonName)
+	}
+
+	return nil
+}
+
+// isRemoteIdentityHeader reports whether the header carries the identity the front proxy authenticated.
+func isRemoteIdentityHeader(name string) bool {
+	return name == "X-Remote-User" || name == "X-Remote-Group" || strings.HasPrefix(name, "X-Remote-Extra-")
+}
+
+// frontProxyHandler drops the identity and forwarding headers of requests not sent by the front proxy,
+// so the handlers behind it can trust them.
+func (s *ApiService) frontProxyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := errUntrustedProxy
+		if authn := s.requestHeader.Load(); authn != nil {
+			err = authn.verify(r)
+		}
+
+		if err != nil {
+			dropped := false
+			for name := range r.Header {
+				if isRemoteIdentityHeader(name) {
+					r.Header.Del(name)
+					dropped = true
+				}
+			}
+			if dropped {
+				apiServiceLog.Info("Dropped identity headers of a request not sent by the front proxy",
+					"path", r.URL.Path, "remoteAddr", r.RemoteAddr, "reason", err.Error())
+			}
+
+			// The client is connected directly, its own address is the only one to trust.
+			r.Header.Del("X-Forwarded-For")
+		}
+
+		if r.Header.Get("X-Forwarded-For") == "" {
+			host, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+			if splitErr != nil {
+				host = r.RemoteAddr
+			}
+			r.Header.Set("X-Forwarded-For", host)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// refreshRequestHeaderAuthentication reloads the front proxy trust, the previous one stays in use on failure.
+func (s *ApiService) refreshRequestHeaderAuthentication(ctx context.Context) {
+	authn, err := loadRequestHeaderAuthentication(ctx, s.Reader)
+	if err != nil {
+		apiServiceLog.Error(err, "Front proxy authentication refresh failed")
+		return
+	}
+
+	s.requestHeader.Store(authn)
+}
+
+// serveFrontProxy serves the exposed listener, it verifies the front proxy and forwards every request
+// to the aggregation server listening on the loopback address.
+func (s *ApiService) serveFrontProxy(ctx context.Context) error {
+	certificate, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+
+	backend, err := url.Parse("https://" + backendAddr)
+	if err != nil {
+		return err
+	}
+
+	proxy := httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(backend)
+			pr.Out.Host = pr.In.Host
+			pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+		},
+		FlushInterval: -1,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				// The aggregation server presents the serving certificate loaded above, it is pinned
+				// instead of being verified against a CA.
+				InsecureSkipVerify: true,
+				VerifyConnection: func(state tls.ConnectionState) error {
+					if len(state.PeerCertificates) == 0 || !bytes.Equal(state.PeerCertificates[0].Raw, certificate.Certificate[0]) {
+						return errors.New("aggregation server presented an unexpected certificate")
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	srv := http.Server{
+		Addr:    s.addr,
+		Handler: s.frontProxyHandler(&proxy),
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+			// Direct clients present no certificate, the front proxy one is verified per request
+			// against the current client CA.
+			ClientAuth: tls.RequestClientCert,
+		},
+	}
+
+	return listenAndServeTLS(ctx, &srv)
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Front proxy authentication", func() {
+	var (
+		service     *ApiService
+		proxyCert   tls.Certificate
+		auditOfAcme func(certificates ...tls.Certificate) int
+	)
+
+	BeforeEach(func() {
+		tenant := &productv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "acme"}}
+		tenant.Status.Namespace = "tenant-acme"
+
+		service = newTestService(allowUsers("alice"), tenant)
+		proxyCert = trustFrontProxy(service)
+
+		auditOfAcme = func(certificates ...tls.Certificate) int {
+			server := serveTLS(service, service.tenantAudit)
+			status, _ := do(clientOf(server, certificates...), remoteRequest(http.MethodGet, server.URL+"/audit?tenant=acme", "alice", "system:masters"))
+			return status
+		}
+	})
+
+	It("should reject forged identity headers without a client certificate", func() {
+		Expect(auditOfAcme()).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject identity headers with a certificate of another CA", func() {
+		Expect(auditOfAcme(newTestCA("rogue-ca").issue("front-proxy-client"))).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject identity headers with a certificate of a name not allowed", func() {
+		proxyCA := newTestCA("front-proxy-ca")
+		service.requestHeader.Store(&requestHeaderAuthentication{
+			ClientCAs:    proxyCA.pool(),
+			AllowedNames: []string{"front-proxy-client"},
+		})
+
+		Expect(auditOfAcme(proxyCA.issue("someone-else"))).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should authorize the identity sent by the front proxy", func() {
+		Expect(auditOfAcme(proxyCert)).To(Equal(http.StatusOK))
+	})
+
+	It("should replace the forwarded client address of direct clients", func() {
+		forwardedFor := ""
+		server := serveTLS(service, func(w http.ResponseWriter, r *http.Request) {
+			forwardedFor = r.Header.Get("X-Forwarded-For")
+		})
+
+		req := remoteRequest(http.MethodGet, server.URL, "alice")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		status, _ := do(clientOf(server), req)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(forwardedFor).To(Equal("127.0.0.1"))
+	})
+
+	It("should load the front proxy trust from the extension-apiserver-authentication ConfigMap", func() {
+		proxyCA := newTestCA("front-proxy-ca")
+		service = newTestService(interceptor.Funcs{}, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: authenticationConfigMapNamespace, Name: authenticationConfigMapName},
+			Data: map[string]string{
+				requestHeaderClientCAKey:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: proxyCA.certificate.Raw})),
+				requestHeaderAllowedNamesKey: `["front-proxy-client"]`,
+			},
+		})
+
+		authn, err := loadRequestHeaderAuthentication(context.Background(), service.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authn.AllowedNames).To(ConsistOf("front-proxy-client"))
+		Expect(authn.ClientCAs.Equal(proxyCA.pool())).To(BeTrue())
+	})
+})
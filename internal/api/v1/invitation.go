@@ -0,0 +1,226 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users/status,verbs=get;update;patch
+
+var (
+	errInvitationNotPending = errors.New("invitation is no longer pending")
+	errInvitationEmailTaken = errors.New("a user with the invited email already exists")
+)
+
+// InvitationAcceptRequest represents an invited user creating the account.
+type InvitationAcceptRequest struct {
+	Token       string  `json:"token"`
+	FirstName   string  `json:"firstName"`
+	LastName    string  `json:"lastName"`
+	PhoneNumber *string `json:"phoneNumber,omitempty"`
+	Password    string  `json:"password"`
+}
+
+// InvitationAcceptResponse represents the User created for an accepted invitation.
+type InvitationAcceptResponse struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+}
+
+func (s *ApiService) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "invitations/accept", "method", r.Method, "path", r.URL.Path)
+	log.Info("Invitation accept endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := InvitationAcceptRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.FirstName == "" || req.LastName == "" || req.Password == "" {
+		http.Error(w, "token, firstName, lastName and password are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := passwords.Validate(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := signing.LoadKey(r.Context(), s.Client, s.Namespace)
+	if err != nil {
+		log.Error(err, "Signing key fetch failed")
+		http.Error(w, "failed to load signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subject, err := signing.Verify(key, signing.PurposeInvitation, req.Token)
+	if err != nil {
+		log.Info("Invitation token rejected", "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(subject, "/")
+	if len(parts) != 3 {
+		http.Error(w, signing.ErrInvalidToken.Error(), http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("namespace", parts[0], "name", parts[1])
+
+	invitation := productv1.Invitation{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, &invitation); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, errInvitationNotPending.Error(), http.StatusGone)
+			return
+		}
+
+		log.Error(err, "Invitation fetch failed")
+		http.Error(w, "failed to get invitation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// A token of a deleted invitation must not accept a new one reusing its name.
+	if string(invitation.UID) != parts[2] || invitation.Status.Phase != "Pending" {
+		http.Error(w, errInvitationNotPending.Error(), http.StatusGone)
+		return
+	}
+
+	hash, err := passwords.Hash(req.Password)
+	if err != nil {
+		log.Error(err, "Password hashing failed")
+		http.Error(w, "failed to hash password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := s.createInvitedUser(r.Context(), &invitation, &req, hash)
+	if err != nil {
+		if errors.Is(err, errInvitationEmailTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		log.Error(err, "User creation failed")
+		http.Error(w, "failed to create user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := productv1.Invitation{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: invitation.Namespace, Name: invitation.Name}, &current); err != nil {
+			return err
+		}
+
+		current.Status.Phase = "Accepted"
+		current.Status.AcceptedTimestamp = metav1.Now()
+		current.Status.UserRef = &corev1.LocalObjectReference{
+			Name: user.Name,
+		}
+		return s.Client.Status().Update(r.Context(), &current)
+	}); err != nil {
+		log.Error(err, "Invitation status update failed")
+		http.Error(w, "failed to accept invitation: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(&InvitationAcceptResponse{
+		Namespace: user.Namespace,
+		Name:      user.Name,
+		Role:      user.Spec.Role,
+	})
+	log.Info("Invitation accepted", "userName", user.Name, "status", http.StatusCreated)
+}
+
+// createInvitedUser creates the User of the invitation in the tenant namespace and hands the password hash over to
+// the User controller. The email address is verified by the invitation link, so the User starts validated.
+func (s *ApiService) createInvitedUser(ctx context.Context, invitation *productv1.Invitation, req *InvitationAcceptRequest, hash string) (*productv1.User, error) {
+	user := productv1.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      string(invitation.UID),
+			Namespace: invitation.Namespace,
+		},
+		Spec: productv1.UserSpec{
+			FirstName:   req.FirstName,
+			LastName:    req.LastName,
+			Email:       invitation.Spec.Email,
+			PhoneNumber: req.PhoneNumber,
+			Role:        invitation.Spec.Role,
+		},
+	}
+	if err := s.Client.Create(ctx, &user); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		// The User of an earlier attempt is reused, another User with the email is a conflict.
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &user); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, errInvitationEmailTaken
+			}
+			return nil, err
+		} else if user.Spec.Email != invitation.Spec.Email {
+			return nil, errInvitationEmailTaken
+		}
+	} else {
+		apiServiceLog.Info("User has been created", "namespace", user.Namespace, "name", user.Name)
+	}
+
+	handover := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      passwords.HandoverSecretName(user.Name),
+			Namespace: user.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+					Controller: ptr.To(true),
+				},
+			},
+		},
+		StringData: map[string]string{
+			"hash": hash,
+		},
+	}
+	if err := s.Client.Create(ctx, &handover); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := productv1.User{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+			return err
+		}
+
+		current.Status.Phase = "Validated"
+		return s.Client.Status().Update(ctx, &current)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+var _ = Describe("Invitation accept endpoint", func() {
+	var (
+		service    *ApiService
+		invitation *productv1.Invitation
+		httpClient *http.Client
+		serverURL  string
+	)
+
+	invitationToken := func(subject string) string {
+		key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := signing.Sign(key, signing.PurposeInvitation, subject, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		return token
+	}
+
+	accept := func(token string) (int, []byte) {
+		return do(httpClient, anonymousRequest(http.MethodPost, serverURL, InvitationAcceptRequest{
+			Token:     token,
+			FirstName: "Bob",
+			LastName:  "Builder",
+			Password:  "N3w-Passphrase",
+		}))
+	}
+
+	validToken := func() string {
+		return invitationToken(invitation.Namespace + "/" + invitation.Name + "/" + string(invitation.UID))
+	}
+
+	BeforeEach(func() {
+		invitation = &productv1.Invitation{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "invite-bob", UID: "invitation-uid"},
+			Spec:       productv1.InvitationSpec{Email: "bob@example.com", Role: "Billing"},
+			Status:     productv1.InvitationStatus{Phase: "Pending"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		service = newTestService(interceptor.Funcs{}, invitation)
+
+		server := serveTLS(service, service.acceptInvitation)
+		httpClient = clientOf(server)
+		serverURL = server.URL
+	})
+
+	It("should require the token, the name and the password", func() {
+		status, _ := do(httpClient, anonymousRequest(http.MethodPost, serverURL, InvitationAcceptRequest{
+			Token:    validToken(),
+			Password: "N3w-Passphrase",
+		}))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject weak passwords", func() {
+		status, _ := do(httpClient, anonymousRequest(http.MethodPost, serverURL, InvitationAcceptRequest{
+			Token:     validToken(),
+			FirstName: "Bob",
+			LastName:  "Builder",
+			Password:  "password",
+		}))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject malformed tokens", func() {
+		status, _ := accept(invitationToken(invitation.Namespace + "/" + invitation.Name))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject the token of a deleted invitation reusing the name", func() {
+		status, _ := accept(invitationToken(invitation.Namespace + "/" + invitation.Name + "/other-uid"))
+		Expect(status).To(Equal(http.StatusGone))
+	})
+
+	It("should create a validated user with the invited role", func() {
+		status, body := accept(validToken())
+		Expect(status).To(Equal(http.StatusCreated))
+
+		response := InvitationAcceptResponse{}
+		Expect(json.Unmarshal(body, &response)).To(Succeed())
+		Expect(response).To(Equal(InvitationAcceptResponse{Namespace: "tenant-acme", Name: "invitation-uid", Role: "Billing"}))
+
+		user := productv1.User{}
+		Expect(service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: "tenant-acme", Name: "invitation-uid"}, &user)).To(Succeed())
+		Expect(user.Spec.Email).To(Equal("bob@example.com"))
+		Expect(user.Status.Phase).To(Equal("Validated"))
+
+		handover := corev1.Secret{}
+		Expect(service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: "tenant-acme", Name: passwords.HandoverSecretName(user.Name)}, &handover)).To(Succeed())
+		Expect(passwords.Compare("N3w-Passphrase", handover.StringData["hash"])).To(BeTrue())
+
+		current := productv1.Invitation{}
+		Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(invitation), &current)).To(Succeed())
+		Expect(current.Status.Phase).To(Equal("Accepted"))
+		Expect(current.Status.UserRef).To(Equal(&corev1.LocalObjectReference{Name: user.Name}))
+
+		By("Accepting the invitation again")
+		status, _ = accept(validToken())
+		Expect(status).To(Equal(http.StatusGone))
+	})
+
+	Context("when the invitation has expired", func() {
+		BeforeEach(func() {
+			invitation.Status.Phase = "Expired"
+		})
+
+		It("should reject the token", func() {
+			status, _ := accept(validToken())
+			Expect(status).To(Equal(http.StatusGone))
+		})
+	})
+})
@@ -0,0 +1,231 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+var (
+	errLicenceExpired       = errors.New("licence expired")
+	errLicenceSuspended     = errors.New("licence suspended")
+	errLicenceSeatsExceeded = errors.New("all licence seats are in use")
+	errActivationNotFound   = errors.New("activation not found")
+)
+
+// LicenceActivationRequest represents an installation asking to activate or deactivate a licence.
+type LicenceActivationRequest struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	Kind        string `json:"kind,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+func (s *ApiService) activateLicence(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "licences/activate", "method", r.Method, "path", r.URL.Path)
+	log.Info("Activate endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := LicenceActivationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "Cluster"
+	}
+	if req.Namespace == "" || req.Name == "" || req.Fingerprint == "" {
+		http.Error(w, "namespace, name and fingerprint are required", http.StatusBadRequest)
+		return
+	} else if len(req.Fingerprint) > 256 || len(req.DisplayName) > 256 {
+		http.Error(w, "fingerprint and displayName must be at most 256 characters", http.StatusBadRequest)
+		return
+	} else if req.Kind != "Cluster" && req.Kind != "Machine" {
+		http.Error(w, "kind must be Cluster or Machine", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("namespace", req.Namespace, "name", req.Name, "fingerprint", req.Fingerprint)
+
+	if err := s.authorize(r, &authorizationv1.ResourceAttributes{
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+		Verb:        "create",
+		Group:       productv1.GroupVersion.Group,
+		Resource:    "licences",
+		Subresource: "activate",
+	}); err != nil {
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Licence activation forbidden", "user", r.Header.Get("X-Remote-User"))
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	activation := productv1.LicenceActivation{}
+	created := false
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		licence := productv1.Licence{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, &licence); err != nil {
+			return err
+		}
+
+		now := metav1.Now()
+		if !licence.Spec.ExpireTimestamp.IsZero() && licence.Spec.ExpireTimestamp.Before(&now) {
+			return errLicenceExpired
+		}
+		if licence.Status.Suspended {
+			return errLicenceSuspended
+		}
+
+		created = true
+		for i := range licence.Status.Activations {
+			if licence.Status.Activations[i].Fingerprint == req.Fingerprint {
+				licence.Status.Activations[i].LastSeenTimestamp = now
+				if req.DisplayName != "" {
+					licence.Status.Activations[i].DisplayName = req.DisplayName
+				}
+
+				activation = licence.Status.Activations[i]
+				created = false
+				break
+			}
+		}
+
+		if created {
+			if len(licence.Status.Activations) >= int(licence.Spec.Seats) {
+				return errLicenceSeatsExceeded
+			}
+
+			activation = productv1.LicenceActivation{
+				Fingerprint:         req.Fingerprint,
+				Kind:                req.Kind,
+				DisplayName:         req.DisplayName,
+				ActivationTimestamp: now,
+				LastSeenTimestamp:   now,
+			}
+			licence.Status.Activations = append(licence.Status.Activations, activation)
+		}
+
+		return s.Client.Status().Update(r.Context(), &licence)
+	}); err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			http.Error(w, "licence not found", http.StatusNotFound)
+		case errors.Is(err, errLicenceExpired), errors.Is(err, errLicenceSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, errLicenceSeatsExceeded):
+			log.Info("Licence activation rejected, no free seat left")
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error(err, "Licence activation failed")
+			http.Error(w, "failed to activate licence: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&activation)
+	log.Info("Licence activated", "status", status)
+}
+
+func (s *ApiService) deactivateLicence(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "licences/deactivate", "method", r.Method, "path", r.URL.Path)
+	log.Info("Deactivate endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := LicenceActivationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" || req.Name == "" || req.Fingerprint == "" {
+		http.Error(w, "namespace, name and fingerprint are required", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("namespace", req.Namespace, "name", req.Name, "fingerprint", req.Fingerprint)
+
+	if err := s.authorize(r, &authorizationv1.ResourceAttributes{
+		Namespace:   req.Namespace,
+		Name:        req.Name,
+		Verb:        "create",
+		Group:       productv1.GroupVersion.Group,
+		Resource:    "licences",
+		Subresource: "deactivate",
+	}); err != nil {
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Licence deactivation forbidden", "user", r.Header.Get("X-Remote-User"))
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		licence := productv1.Licence{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, &licence); err != nil {
+			return err
+		}
+
+		activations := make([]productv1.LicenceActivation, 0, len(licence.Status.Activations))
+		for _, activation := range licence.Status.Activations {
+			if activation.Fingerprint != req.Fingerprint {
+				activations = append(activations, activation)
+			}
+		}
+		if len(activations) == len(licence.Status.Activations) {
+			return errActivationNotFound
+		}
+
+		licence.Status.Activations = activations
+		return s.Client.Status().Update(r.Context(), &licence)
+	}); err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			http.Error(w, "licence not found", http.StatusNotFound)
+		case errors.Is(err, errActivationNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			log.Error(err, "Licence deactivation failed")
+			http.Error(w, "failed to deactivate licence: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Licence deactivated", "status", http.StatusNoContent)
+}
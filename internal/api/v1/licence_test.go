@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Licence activation endpoints", func() {
+	var (
+		service    *ApiService
+		licence    *productv1.Licence
+		attributes *authorizationv1.ResourceAttributes
+		proxied    *http.Client
+		activate   *httptest.Server
+		deactivate *httptest.Server
+	)
+
+	activation := func(server *httptest.Server, user, fingerprint string) *http.Request {
+		req := remoteRequest(http.MethodPost, server.URL+"/licences", user)
+		raw, err := json.Marshal(LicenceActivationRequest{
+			Namespace:   licence.Namespace,
+			Name:        licence.Name,
+			Fingerprint: fingerprint,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+
+		return req
+	}
+
+	activations := func() []productv1.LicenceActivation {
+		stored := productv1.Licence{}
+		Expect(service.Client.Get(context.Background(), types.NamespacedName{
+			Namespace: licence.Namespace,
+			Name:      licence.Name,
+		}, &stored)).To(Succeed())
+
+		return stored.Status.Activations
+	}
+
+	BeforeEach(func() {
+		attributes = nil
+		licence = &productv1.Licence{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "order-0"},
+			Spec: productv1.LicenceSpec{
+				DisplayName:     "Cluster licence",
+				Seats:           2,
+				ExpireTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		allow := allowUsers("alice")
+		service = newTestService(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+					attributes = review.Spec.ResourceAttributes
+				}
+				return allow.Create(ctx, c, obj, opts...)
+			},
+		}, licence)
+		proxyCert := trustFrontProxy(service)
+
+		activate = serveTLS(service, service.activateLicence)
+		deactivate = serveTLS(service, service.deactivateLicence)
+		proxied = clientOf(activate, proxyCert)
+	})
+
+	It("should reject forged identity headers without the front proxy certificate", func() {
+		status, _ := do(clientOf(activate), activation(activate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		Expect(activations()).To(BeEmpty())
+	})
+
+	It("should forbid users who may not activate the licence", func() {
+		status, _ := do(proxied, activation(activate, "bob", "cluster-a"))
+		Expect(status).To(Equal(http.StatusForbidden))
+		Expect(activations()).To(BeEmpty())
+
+		Expect(attributes).NotTo(BeNil())
+		Expect(*attributes).To(Equal(authorizationv1.ResourceAttributes{
+			Namespace:   "tenant-acme",
+			Name:        "order-0",
+			Verb:        "create",
+			Group:       productv1.GroupVersion.Group,
+			Resource:    "licences",
+			Subresource: "activate",
+		}))
+	})
+
+	It("should activate up to the seats of the licence", func() {
+		status, _ := do(proxied, activation(activate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusCreated))
+		status, _ = do(proxied, activation(activate, "alice", "cluster-b"))
+		Expect(status).To(Equal(http.StatusCreated))
+
+		status, _ = do(proxied, activation(activate, "alice", "cluster-c"))
+		Expect(status).To(Equal(http.StatusConflict))
+
+		status, _ = do(proxied, activation(activate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(activations()).To(HaveLen(2))
+	})
+
+	It("should free the seat of a deactivated installation", func() {
+		status, _ := do(proxied, activation(activate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusCreated))
+
+		status, _ = do(proxied, activation(deactivate, "bob", "cluster-a"))
+		Expect(status).To(Equal(http.StatusForbidden))
+		Expect(attributes.Subresource).To(Equal("deactivate"))
+
+		status, _ = do(proxied, activation(deactivate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusNoContent))
+		Expect(activations()).To(BeEmpty())
+
+		status, _ = do(proxied, activation(deactivate, "alice", "cluster-a"))
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	Context("when the licence has expired", func() {
+		BeforeEach(func() {
+			licence.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		})
+
+		It("should reject the activation", func() {
+			status, _ := do(proxied, activation(activate, "alice", "cluster-a"))
+			Expect(status).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Context("when the licence is suspended", func() {
+		BeforeEach(func() {
+			licence.Status.Suspended = true
+		})
+
+		It("should reject the activation", func() {
+			status, _ := do(proxied, activation(activate, "alice", "cluster-a"))
+			Expect(status).To(Equal(http.StatusForbidden))
+		})
+	})
+})
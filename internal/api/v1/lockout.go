@@ -0,0 +1,381 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+const (
+	// loginThrottleLabel marks the ConfigMaps tracking failed logins per source IP.
+	loginThrottleLabel = "product.webshop.harikube.info/login-throttle"
+)
+
+var (
+	errTooManyAttempts = errors.New("too many failed login attempts")
+)
+
+// LoginProtectionConfig configures the brute-force protection of the login endpoints.
+type LoginProtectionConfig struct {
+	// MaxAttempts is the number of consecutive failures locking a user.
+	MaxAttempts int32
+	// MaxAttemptsPerIP is the number of failures blocking a source IP.
+	MaxAttemptsPerIP int32
+	// LockoutDuration is the time users and source IPs stay blocked.
+	LockoutDuration time.Duration
+	// BaseDelay is the delay after the first failure, it doubles with each further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts.
+	MaxDelay time.Duration
+}
+
+// UnlockRequest represents an administrator lifting the lockout of a user.
+type UnlockRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// loginRetryAfter returns the time the source IP or the user has to wait before the next attempt.
+// The state is read from the API server as other replicas may have recorded failures.
+func (s *ApiService) loginRetryAfter(ctx context.Context, ip string, user *productv1.User) (time.Duration, error) {
+	now := time.Now()
+
+	configMap := corev1.ConfigMap{}
+	if err := s.Reader.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: loginThrottleName(ip)}, &configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return 0, err
+		}
+	} else {
+		failures, lastFailure := parseLoginThrottle(&configMap)
+		if until := lastFailure.Add(s.LoginProtection.LockoutDuration); failures >= s.LoginProtection.MaxAttemptsPerIP && now.Before(until) {
+			return until.Sub(now), nil
+		}
+	}
+
+	if user == nil {
+		return 0, nil
+	}
+
+	current := productv1.User{}
+	if err := s.Reader.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+		return 0, err
+	}
+
+	if until := current.Status.LockedUntilTimestamp.Time; now.Before(until) {
+		return until.Sub(now), nil
+	}
+
+	if attempts := current.Status.FailedLoginAttempts; attempts > 0 {
+		if until := current.Status.LastFailedLoginTimestamp.Add(s.loginDelay(attempts)); now.Before(until) {
+			return until.Sub(now), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// recordLoginFailure counts the failure against the source IP and the user, the user is locked and notified
+// after too many consecutive failures.
+func (s *ApiService) recordLoginFailure(ctx context.Context, ip string, user *productv1.User) error {
+	now := time.Now()
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		configMap := corev1.ConfigMap{}
+		if err := s.Reader.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: loginThrottleName(ip)}, &configMap); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+
+			configMap = corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      loginThrottleName(ip),
+					Namespace: s.Namespace,
+					Labels: map[string]string{
+						loginThrottleLabel: "true",
+					},
+				},
+				Data: map[string]string{
+					"failures":    "1",
+					"lastFailure": now.UTC().Format(time.RFC3339),
+				},
+			}
+			err := s.Client.Create(ctx, &configMap)
+			if apierrors.IsAlreadyExists(err) {
+				// Another replica recorded a failure meanwhile, retry with an update.
+				return apierrors.NewConflict(corev1.Resource("configmaps"), configMap.Name, err)
+			}
+
+			return err
+		}
+
+		failures, lastFailure := parseLoginThrottle(&configMap)
+		if now.After(lastFailure.Add(s.LoginProtection.LockoutDuration)) {
+			failures = 0
+		}
+
+		configMap.Data = map[string]string{
+			"failures":    strconv.Itoa(int(failures + 1)),
+			"lastFailure": now.UTC().Format(time.RFC3339),
+		}
+		return s.Client.Update(ctx, &configMap)
+	}); err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	locked := false
+	current := productv1.User{}
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := s.Reader.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+			return err
+		}
+
+		current.Status.FailedLoginAttempts++
+		current.Status.LastFailedLoginTimestamp = metav1.NewTime(now)
+
+		locked = current.Status.FailedLoginAttempts >= s.LoginProtection.MaxAttempts
+		if locked {
+			current.Status.FailedLoginAttempts = 0
+			current.Status.LockedUntilTimestamp = metav1.NewTime(now.Add(s.LoginProtection.LockoutDuration))
+		}
+
+		return s.Client.Status().Update(ctx, &current)
+	}); err != nil {
+		return err
+	}
+
+	if locked {
+		apiServiceLog.Info("User has been locked", "namespace", current.Namespace, "name", current.Name, "lockedUntil", current.Status.LockedUntilTimestamp)
+		return s.notifyLockout(ctx, &current)
+	}
+
+	return nil
+}
+
+// resetLoginFailures clears the failure count of the user after a successful login.
+func (s *ApiService) resetLoginFailures(ctx context.Context, user *productv1.User) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := productv1.User{}
+		if err := s.Reader.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+			return err
+		}
+
+		if current.Status.FailedLoginAttempts == 0 && current.Status.LockedUntilTimestamp.IsZero() {
+			return nil
+		}
+
+		current.Status.FailedLoginAttempts = 0
+		current.Status.LastFailedLoginTimestamp = metav1.Time{}
+		current.Status.LockedUntilTimestamp = metav1.Time{}
+		return s.Client.Status().Update(ctx, &current)
+	})
+}
+
+// notifyLockout sends the locked user an Email about the lockout.
+func (s *ApiService) notifyLockout(ctx context.Context, user *productv1.User) error {
+	emailTemplate := productv1.EmailTemplate{}
+	if err := s.Client.Get(ctx, types.NamespacedName{
+		Name:      "example-webshop-service-account-locked",
+		Namespace: s.Namespace,
+	}, &emailTemplate); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	renderer, err := template.New("account_locked_template").Parse(emailTemplate.Spec.Body)
+	if err != nil {
+		return err
+	}
+
+	userMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(user)
+	if err != nil {
+		return err
+	}
+
+	var renderedBody bytes.Buffer
+	if err := renderer.Execute(&renderedBody, userMap); err != nil {
+		return err
+	}
+
+	email := productv1.Email{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-locked-%d", user.Name, user.Status.LockedUntilTimestamp.Unix()),
+			Namespace: user.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				},
+			},
+		},
+		Spec: productv1.EmailSpec{
+			ToAddress:   user.Spec.Email,
+			FromName:    emailTemplate.Spec.FromName,
+			FromAddress: emailTemplate.Spec.FromAddress,
+			Subject:     emailTemplate.Spec.Subject,
+			Body:        renderedBody.String(),
+		},
+	}
+	if err := s.Client.Create(ctx, &email); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *ApiService) unlockUser(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/unlock", "method", r.Method, "path", r.URL.Path)
+	log.Info("Unlock endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := UnlockRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" || req.Name == "" {
+		http.Error(w, "namespace and name are required", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("namespace", req.Namespace, "name", req.Name)
+
+	if err := s.authorize(r, &authorizationv1.ResourceAttributes{
+		Namespace:   req.Namespace,
+		Verb:        "update",
+		Group:       productv1.GroupVersion.Group,
+		Resource:    "users",
+		Subresource: "status",
+		Name:        req.Name,
+	}); err != nil {
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Unlock forbidden", "user", r.Header.Get("X-Remote-User"))
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := s.resetLoginFailures(r.Context(), &productv1.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: req.Namespace,
+			Name:      req.Name,
+		},
+	}); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, errUserNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Error(err, "User unlock failed")
+		http.Error(w, "failed to unlock user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("User has been unlocked", "by", r.Header.Get("X-Remote-User"))
+}
+
+// cleanupLoginThrottles deletes the source IP trackers whose lockout window has passed.
+func (s *ApiService) cleanupLoginThrottles(ctx context.Context) error {
+	configMaps := corev1.ConfigMapList{}
+	if err := s.Reader.List(ctx, &configMaps, client.InNamespace(s.Namespace), client.HasLabels{loginThrottleLabel}); err != nil {
+		return err
+	}
+
+	for i := range configMaps.Items {
+		if _, lastFailure := parseLoginThrottle(&configMaps.Items[i]); time.Now().Before(lastFailure.Add(s.LoginProtection.LockoutDuration)) {
+			continue
+		}
+
+		if err := s.Client.Delete(ctx, &configMaps.Items[i], client.Preconditions{ResourceVersion: &configMaps.Items[i].ResourceVersion}); err != nil &&
+			!apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loginDelay returns the exponential delay after the given number of consecutive failures.
+func (s *ApiService) loginDelay(attempts int32) time.Duration {
+	delay := s.LoginProtection.BaseDelay
+	for i := int32(1); i < attempts && delay < s.LoginProtection.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.LoginProtection.MaxDelay)
+}
+
+func parseLoginThrottle(configMap *corev1.ConfigMap) (int32, time.Time) {
+	failures, _ := strconv.Atoi(configMap.Data["failures"])
+	lastFailure, _ := time.Parse(time.RFC3339, configMap.Data["lastFailure"])
+
+	return int32(failures), lastFailure
+}
+
+func loginThrottleName(ip string) string {
+	sum := sha256.Sum256([]byte(ip))
+	return "example-webshop-service-login-" + hex.EncodeToString(sum[:8])
+}
+
+// clientIP returns the source address of the request. Aggregated requests are proxied by the kube-apiserver,
+// which appends the address of its client to X-Forwarded-For, so the last entry is the trusted one.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		entries := strings.Split(forwarded, ",")
+		return strings.TrimSpace(entries[len(entries)-1])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// writeTooManyAttempts responds with the time the client has to wait before the next attempt.
+func writeTooManyAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)+1))
+	http.Error(w, errTooManyAttempts.Error(), http.StatusTooManyRequests)
+}
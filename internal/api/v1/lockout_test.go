@@ -0,0 +1,167 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Login protection", func() {
+	var (
+		service  *ApiService
+		user     *productv1.User
+		password *corev1.Secret
+		login    *http.Client
+		loginURL string
+	)
+
+	attempt := func(email, password string) *http.Response {
+		resp, err := login.Do(anonymousRequest(http.MethodPost, loginURL, LoginRequest{Email: email, Password: password}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Body.Close()).To(Succeed())
+		return resp
+	}
+
+	stored := func() *productv1.User {
+		current := productv1.User{}
+		Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(user), &current)).To(Succeed())
+		return &current
+	}
+
+	BeforeEach(func() {
+		user, password = newTestUser("alice", "correct horse battery staple")
+		template := &productv1.EmailTemplate{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "webshop-system", Name: "example-webshop-service-account-locked"},
+			Spec: productv1.EmailTemplateSpec{
+				FromAddress: "noreply@example.com",
+				Subject:     "Your account has been locked",
+				Body:        "Hello {{ .spec.email }}",
+			},
+		}
+		service = newTestService(allowUsers("admin"), user, password, template, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: user.Namespace, Name: string(user.UID)},
+		})
+		// No delay between attempts, only the lockouts are tested.
+		service.LoginProtection.BaseDelay = 0
+		service.LoginProtection.MaxDelay = 0
+		service.LoginProtection.MaxAttempts = 3
+
+		server := serveTLS(service, service.loginUser)
+		login = clientOf(server)
+		loginURL = server.URL
+	})
+
+	It("should delay the next attempt after a failure", func() {
+		service.LoginProtection.BaseDelay = time.Minute
+		service.LoginProtection.MaxDelay = time.Minute
+
+		Expect(attempt("alice@example.com", "wrong horse battery staple").StatusCode).To(Equal(http.StatusUnauthorized))
+
+		resp := attempt("alice@example.com", "correct horse battery staple")
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(resp.Header.Get("Retry-After")).NotTo(BeEmpty())
+	})
+
+	It("should lock the user and send a notification after too many failures", func() {
+		for range 3 {
+			Expect(attempt("alice@example.com", "wrong horse battery staple").StatusCode).To(Equal(http.StatusUnauthorized))
+		}
+		Expect(stored().Status.LockedUntilTimestamp.Time).To(BeTemporally("~", time.Now().Add(15*time.Minute), time.Minute))
+
+		Expect(attempt("alice@example.com", "correct horse battery staple").StatusCode).To(Equal(http.StatusTooManyRequests))
+
+		emails := productv1.EmailList{}
+		Expect(service.Client.List(context.Background(), &emails, client.InNamespace(user.Namespace))).To(Succeed())
+		Expect(emails.Items).To(HaveLen(1))
+		Expect(emails.Items[0].Spec.ToAddress).To(Equal("alice@example.com"))
+		Expect(emails.Items[0].Spec.Body).To(Equal("Hello alice@example.com"))
+	})
+
+	It("should block a source address trying too many accounts", func() {
+		service.LoginProtection.MaxAttemptsPerIP = 2
+
+		Expect(attempt("mallory@example.com", "guess").StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(attempt("trudy@example.com", "guess").StatusCode).To(Equal(http.StatusUnauthorized))
+
+		Expect(attempt("alice@example.com", "correct horse battery staple").StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(stored().Status.FailedLoginAttempts).To(BeZero())
+	})
+
+	It("should clean up the trackers of source addresses whose lockout has passed", func() {
+		Expect(attempt("mallory@example.com", "guess").StatusCode).To(Equal(http.StatusUnauthorized))
+
+		throttles := func() []corev1.ConfigMap {
+			configMaps := corev1.ConfigMapList{}
+			Expect(service.Client.List(context.Background(), &configMaps, client.HasLabels{loginThrottleLabel})).To(Succeed())
+			return configMaps.Items
+		}
+		Expect(throttles()).To(HaveLen(1))
+
+		Expect(service.cleanupLoginThrottles(context.Background())).To(Succeed())
+		Expect(throttles()).To(HaveLen(1))
+
+		service.LoginProtection.LockoutDuration = -time.Minute
+		Expect(service.cleanupLoginThrottles(context.Background())).To(Succeed())
+		Expect(throttles()).To(BeEmpty())
+	})
+
+	Describe("unlock endpoint", func() {
+		var (
+			server    *httptest.Server
+			proxied   *http.Client
+			unlockURL string
+		)
+
+		unlock := func(requester string, req UnlockRequest) int {
+			r := remoteRequest(http.MethodPost, unlockURL, requester)
+			setJSONBody(r, req)
+			status, _ := do(proxied, r)
+			return status
+		}
+
+		BeforeEach(func() {
+			proxyCert := trustFrontProxy(service)
+			server = serveTLS(service, service.unlockUser)
+			proxied = clientOf(server, proxyCert)
+			unlockURL = server.URL
+
+			for range 3 {
+				Expect(attempt("alice@example.com", "wrong horse battery staple").StatusCode).To(Equal(http.StatusUnauthorized))
+			}
+			Expect(stored().Status.LockedUntilTimestamp.IsZero()).To(BeFalse())
+		})
+
+		It("should reject forged identity headers without the front proxy certificate", func() {
+			r := remoteRequest(http.MethodPost, unlockURL, "admin", "system:masters")
+			setJSONBody(r, UnlockRequest{Namespace: user.Namespace, Name: user.Name})
+			status, _ := do(clientOf(server), r)
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should forbid users who may not update the status of the user", func() {
+			Expect(unlock("alice", UnlockRequest{Namespace: user.Namespace, Name: user.Name})).To(Equal(http.StatusForbidden))
+			Expect(stored().Status.LockedUntilTimestamp.IsZero()).To(BeFalse())
+		})
+
+		It("should report unknown users", func() {
+			Expect(unlock("admin", UnlockRequest{Namespace: user.Namespace, Name: "bob"})).To(Equal(http.StatusNotFound))
+		})
+
+		It("should lift the lockout", func() {
+			Expect(unlock("admin", UnlockRequest{Namespace: user.Namespace, Name: user.Name})).To(Equal(http.StatusNoContent))
+			Expect(stored().Status.LockedUntilTimestamp.IsZero()).To(BeTrue())
+
+			Expect(attempt("alice@example.com", "correct horse battery staple").StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+})
@@ -0,0 +1,374 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+	"github.com/HariKube/example-webshop-service/internal/totp"
+)
+
+const (
+	// mfaIssuer is the issuer shown by authenticator apps.
+	mfaIssuer = "HariKube"
+	// mfaTokenTTL is the time the second login step has to be completed in.
+	mfaTokenTTL = 5 * time.Minute
+	// mfaRecoveryCodes is the number of recovery codes issued on enrollment.
+	mfaRecoveryCodes = 10
+	// recoveryCodeAlphabet avoids characters easily mistaken for each other.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	errMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	errMFANotEnrolled    = errors.New("multi-factor authentication is not enrolled")
+	errInvalidMFACode    = errors.New("invalid code")
+)
+
+// MFAEnrollResponse represents the authenticator secret and recovery codes of a new enrollment.
+type MFAEnrollResponse struct {
+	URI           string   `json:"uri"`
+	Secret        string   `json:"secret"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFACodeRequest represents a code of the authenticator app or a recovery code.
+type MFACodeRequest struct {
+	MFAToken string `json:"mfaToken,omitempty"`
+	Code     string `json:"code"`
+}
+
+func (s *ApiService) enrollMFA(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/mfa/enroll", "method", r.Method, "path", r.URL.Path)
+	log.Info("MFA enroll endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	if user.Status.MFAEnabled {
+		http.Error(w, errMFAAlreadyEnabled.Error(), http.StatusConflict)
+		return
+	}
+
+	secretKey, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error(err, "Secret generation failed")
+		http.Error(w, "failed to generate secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		log.Error(err, "Recovery code generation failed")
+		http.Error(w, "failed to generate recovery codes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mfaSecretName(user),
+			Namespace: user.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				},
+			},
+		},
+		Data: map[string][]byte{
+			"secret":        []byte(secretKey),
+			"recoveryCodes": []byte(strings.Join(hashes, "\n")),
+		},
+	}
+	if err := s.Client.Create(r.Context(), &secret); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "Secret creation failed", "secretName", secret.Name)
+			http.Error(w, "failed to store secret: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// A pending enrollment is replaced.
+		data := secret.Data
+		if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &secret); err != nil {
+				return err
+			}
+
+			secret.Data = data
+			return s.Client.Update(r.Context(), &secret)
+		}); err != nil {
+			log.Error(err, "Secret update failed", "secretName", secret.Name)
+			http.Error(w, "failed to store secret: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(&MFAEnrollResponse{
+		URI:           totp.URI(mfaIssuer, user.Spec.Email, secretKey),
+		Secret:        secretKey,
+		RecoveryCodes: codes,
+	})
+	log.Info("MFA enrolled", "status", http.StatusCreated)
+}
+
+func (s *ApiService) activateMFA(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/mfa/activate", "method", r.Method, "path", r.URL.Path)
+	log.Info("MFA activate endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	req := MFACodeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if user.Status.MFAEnabled {
+		http.Error(w, errMFAAlreadyEnabled.Error(), http.StatusConflict)
+		return
+	}
+
+	// Only a code of the authenticator app proves the enrollment, recovery codes are not accepted here.
+	if err := s.verifyMFACode(r.Context(), user, req.Code, false); err != nil {
+		switch {
+		case errors.Is(err, errInvalidMFACode):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errMFANotEnrolled):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error(err, "MFA code verification failed")
+			http.Error(w, "failed to verify code: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := productv1.User{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+			return err
+		}
+
+		current.Status.MFAEnabled = true
+		current.Status.MFARef = &corev1.LocalObjectReference{
+			Name: mfaSecretName(user),
+		}
+		return s.Client.Status().Update(r.Context(), &current)
+	}); err != nil {
+		log.Error(err, "User status update failed")
+		http.Error(w, "failed to enable mfa: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("MFA enabled", "status", http.StatusNoContent)
+}
+
+func (s *ApiService) loginMFA(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/login/mfa", "method", r.Method, "path", r.URL.Path)
+	log.Info("MFA login endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := MFACodeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MFAToken == "" || req.Code == "" {
+		http.Error(w, "mfaToken and code are required", http.StatusBadRequest)
+		return
+	}
+
+	key, err := signing.LoadKey(r.Context(), s.Client, s.Namespace)
+	if err != nil {
+		log.Error(err, "Signing key fetch failed")
+		http.Error(w, "failed to load signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	uid, err := signing.Verify(key, signing.PurposeMFA, req.MFAToken)
+	if err != nil {
+		log.Info("MFA token rejected", "reason", err.Error())
+		http.Error(w, errInvalidCredentials.Error(), http.StatusUnauthorized)
+		return
+	}
+	log = log.WithValues("uid", uid)
+
+	users := productv1.UserList{}
+	if err := s.Client.List(r.Context(), &users, client.MatchingFields{"metadata.uid": uid}); err != nil {
+		log.Error(err, "User fetch failed")
+		http.Error(w, "failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if len(users.Items) == 0 || users.Items[0].DeletionTimestamp != nil {
+		http.Error(w, errInvalidCredentials.Error(), http.StatusUnauthorized)
+		return
+	}
+	user := users.Items[0]
+
+	ip := clientIP(r)
+	if retryAfter, err := s.loginRetryAfter(r.Context(), ip, &user); err != nil {
+		log.Error(err, "Login throttle check failed")
+		http.Error(w, "failed to check login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if retryAfter > 0 {
+		log.Info("Login throttled", "retryAfter", retryAfter)
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
+
+	if err := s.verifyMFACode(r.Context(), &user, req.Code, true); err != nil {
+		if errors.Is(err, errInvalidMFACode) || errors.Is(err, errMFANotEnrolled) {
+			log.Info("MFA login failed")
+			if err := s.recordLoginFailure(r.Context(), ip, &user); err != nil {
+				log.Error(err, "Login failure recording failed")
+			}
+			http.Error(w, errInvalidCredentials.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		log.Error(err, "MFA code verification failed")
+		http.Error(w, "failed to verify code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.resetLoginFailures(r.Context(), &user); err != nil {
+		log.Error(err, "Login failure reset failed")
+		http.Error(w, "failed to reset login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := s.issueSession(r, &user)
+	if err != nil {
+		log.Error(err, "TokenRequest creation failed", "serviceAccountName", string(user.UID))
+		http.Error(w, "failed to issue token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+	s.recordUserEvent(r, &user, audit.Login, "Login succeeded with second factor")
+	log.Info("Login succeeded", "status", http.StatusOK)
+}
+
+// verifyMFACode checks the code against the authenticator secret of the user, optionally accepting a recovery code.
+// Accepted codes are consumed, authenticator codes by remembering the last time step, recovery codes by removal.
+func (s *ApiService) verifyMFACode(ctx context.Context, user *productv1.User, code string, allowRecovery bool) error {
+	// Recovery codes are displayed in groups separated by a dash, users may type them with or without it.
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+	if code == "" {
+		return errInvalidMFACode
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: mfaSecretName(user)}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return errMFANotEnrolled
+			}
+
+			return err
+		}
+
+		lastStep, _ := strconv.ParseInt(string(secret.Data["lastStep"]), 10, 64)
+		if step, ok := totp.Validate(string(secret.Data["secret"]), code, time.Now()); ok {
+			if step <= lastStep {
+				return errInvalidMFACode
+			}
+
+			secret.Data["lastStep"] = []byte(strconv.FormatInt(step, 10))
+			return s.Client.Update(ctx, &secret)
+		}
+
+		if !allowRecovery {
+			return errInvalidMFACode
+		}
+
+		sum := sha256.Sum256([]byte(code))
+		hashed := []byte(hex.EncodeToString(sum[:]))
+
+		hashes := strings.Split(string(secret.Data["recoveryCodes"]), "\n")
+		i := slices.IndexFunc(hashes, func(hash string) bool {
+			return subtle.ConstantTimeCompare([]byte(hash), hashed) == 1
+		})
+		if i < 0 {
+			return errInvalidMFACode
+		}
+
+		secret.Data["recoveryCodes"] = []byte(strings.Join(slices.Delete(hashes, i, i+1), "\n"))
+		return s.Client.Update(ctx, &secret)
+	})
+}
+
+// generateRecoveryCodes returns random recovery codes and their sha256 hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, mfaRecoveryCodes)
+	hashes := make([]string, 0, mfaRecoveryCodes)
+	for range mfaRecoveryCodes {
+		// rand.Int draws every character uniformly, a random byte modulo the alphabet length would favour its first characters.
+		code := make([]byte, 10)
+		for i := range code {
+			index, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			code[i] = recoveryCodeAlphabet[index.Int64()]
+		}
+
+		sum := sha256.Sum256(code)
+		codes = append(codes, string(code[:5])+"-"+string(code[5:]))
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+
+	return codes, hashes, nil
+}
+
+func mfaSecretName(user *productv1.User) string {
+	return string(user.UID) + "-mfa"
+}
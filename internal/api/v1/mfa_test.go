@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+	"github.com/HariKube/example-webshop-service/internal/totp"
+)
+
+var _ = Describe("MFA endpoints", func() {
+	var (
+		service *ApiService
+		user    *productv1.User
+		proxied func(handler http.HandlerFunc) (*http.Client, string)
+	)
+
+	BeforeEach(func() {
+		var password *corev1.Secret
+		user, password = newTestUser("alice", "correct horse battery staple")
+
+		service = newTestService(interceptor.Funcs{}, user, password, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: user.Namespace, Name: string(user.UID)},
+		})
+		proxyCert := trustFrontProxy(service)
+
+		proxied = func(handler http.HandlerFunc) (*http.Client, string) {
+			server := serveTLS(service, handler)
+			return clientOf(server, proxyCert), server.URL
+		}
+	})
+
+	enroll := func() MFAEnrollResponse {
+		httpClient, url := proxied(service.enrollMFA)
+		status, body := do(httpClient, userRequest(http.MethodPost, url, user, "", nil))
+		Expect(status).To(Equal(http.StatusCreated))
+
+		enrollment := MFAEnrollResponse{}
+		Expect(json.Unmarshal(body, &enrollment)).To(Succeed())
+		return enrollment
+	}
+
+	DescribeTable("should reject a forged ServiceAccount identity without the front proxy certificate",
+		func(handler func(*ApiService) http.HandlerFunc) {
+			server := serveTLS(service, handler(service))
+			status, _ := do(clientOf(server), userRequest(http.MethodPost, server.URL, user, "", MFACodeRequest{Code: "000000"}))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		},
+		Entry("enroll", func(s *ApiService) http.HandlerFunc { return s.enrollMFA }),
+		Entry("activate", func(s *ApiService) http.HandlerFunc { return s.activateMFA }),
+	)
+
+	It("should enroll a secret with hashed recovery codes", func() {
+		enrollment := enroll()
+		Expect(enrollment.URI).To(HavePrefix("otpauth://totp/"))
+		Expect(enrollment.RecoveryCodes).To(HaveLen(mfaRecoveryCodes))
+		for _, code := range enrollment.RecoveryCodes {
+			Expect(code).To(MatchRegexp(`^[` + recoveryCodeAlphabet + `]{5}-[` + recoveryCodeAlphabet + `]{5}$`))
+		}
+
+		secret := corev1.Secret{}
+		Expect(service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: user.Namespace, Name: mfaSecretName(user)}, &secret)).To(Succeed())
+		Expect(string(secret.Data["secret"])).To(Equal(enrollment.Secret))
+		Expect(strings.Split(string(secret.Data["recoveryCodes"]), "\n")).To(HaveLen(mfaRecoveryCodes))
+		Expect(string(secret.Data["recoveryCodes"])).NotTo(ContainSubstring(strings.ReplaceAll(enrollment.RecoveryCodes[0], "-", "")))
+	})
+
+	It("should not activate with a recovery code or a wrong code", func() {
+		enrollment := enroll()
+
+		httpClient, url := proxied(service.activateMFA)
+		for _, code := range []string{enrollment.RecoveryCodes[0], "000000"} {
+			status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", MFACodeRequest{Code: code}))
+			Expect(status).To(Equal(http.StatusBadRequest))
+		}
+	})
+
+	It("should activate with a code of the authenticator app", func() {
+		enrollment := enroll()
+		code, err := totp.Code(enrollment.Secret, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		httpClient, url := proxied(service.activateMFA)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", MFACodeRequest{Code: code}))
+		Expect(status).To(Equal(http.StatusNoContent))
+
+		current := productv1.User{}
+		Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(user), &current)).To(Succeed())
+		Expect(current.Status.MFAEnabled).To(BeTrue())
+		Expect(current.Status.MFARef.Name).To(Equal(mfaSecretName(user)))
+	})
+
+	It("should log in once with a recovery code as it was displayed", func() {
+		enrollment := enroll()
+
+		key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		mfaToken, err := signing.Sign(key, signing.PurposeMFA, string(user.UID), time.Now().Add(time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+
+		server := serveTLS(service, service.loginMFA)
+		login := func(code string) (int, []byte) {
+			return do(clientOf(server), anonymousRequest(http.MethodPost, server.URL, MFACodeRequest{MFAToken: mfaToken, Code: code}))
+		}
+
+		status, body := login(enrollment.RecoveryCodes[0])
+		Expect(status).To(Equal(http.StatusOK))
+		response := LoginResponse{}
+		Expect(json.Unmarshal(body, &response)).To(Succeed())
+		Expect(response.Token).To(Equal("fake-token"))
+
+		By("Using the same recovery code again")
+		status, _ = login(enrollment.RecoveryCodes[0])
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+})
+
+var _ = Describe("Recovery codes", func() {
+	It("should draw every character of the alphabet", func() {
+		seen := map[rune]bool{}
+		for range 20 {
+			codes, hashes, err := generateRecoveryCodes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hashes).To(HaveLen(len(codes)))
+			for _, code := range codes {
+				for _, c := range strings.ReplaceAll(code, "-", "") {
+					seen[c] = true
+				}
+			}
+		}
+
+		Expect(seen).To(HaveLen(len(recoveryCodeAlphabet)))
+	})
+})
@@ -0,0 +1,538 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/alexedwards/argon2id"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+const (
+	// resetNonceKey is the data key of the pending reset nonce in the password Secret of a User.
+	resetNonceKey = "resetNonce"
+	// resetSentKey is the data key of the time the last reset link was sent in the password Secret of a User.
+	resetSentKey = "resetSentTimestamp"
+	// passwordResetResendInterval is the time a new reset link can be requested after.
+	passwordResetResendInterval = time.Minute
+)
+
+var (
+	errResetTokenUsed = errors.New("reset token already used")
+)
+
+// PasswordResetRequest represents a user asking for a password reset link.
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetCompleteRequest represents a user setting a new password with a reset token.
+type PasswordResetCompleteRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (s *ApiService) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/password-reset", "method", r.Method, "path", r.URL.Path)
+	log.Info("Password reset endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := PasswordResetRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("email", req.Email)
+
+	users := productv1.UserList{}
+	if err := s.Client.List(r.Context(), &users, client.MatchingFields{"spec.email": req.Email}); err != nil {
+		log.Error(err, "User fetch failed")
+		http.Error(w, "failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The response does not reveal whether the email address is registered.
+	if len(users.Items) != 0 && users.Items[0].Status.PasswordRef != nil {
+		if err := s.sendPasswordReset(r.Context(), &users.Items[0]); err != nil {
+			log.Error(err, "Password reset failed")
+			http.Error(w, "failed to reset password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		log.Info("User not found, skipping password reset")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	log.Info("Password reset requested", "status", http.StatusAccepted)
+}
+
+// sendPasswordReset emails the signed reset link to the user. The pending nonce in the password Secret is reused,
+// so repeated requests do not invalidate the links sent before, and no link is sent within the resend interval.
+func (s *ApiService) sendPasswordReset(ctx context.Context, user *productv1.User) error {
+	log := apiServiceLog.WithValues("namespace", user.Namespace, "name", user.Name)
+
+	nonce := ""
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+			return err
+		}
+
+		nonce = string(secret.Data[resetNonceKey])
+		if sent, err := time.Parse(time.RFC3339, string(secret.Data[resetSentKey])); err == nil && nonce != "" {
+			if time.Since(sent) < passwordResetResendInterval {
+				nonce = ""
+				return nil
+			}
+		}
+
+		if nonce == "" {
+			raw := make([]byte, 16)
+			if _, err := rand.Read(raw); err != nil {
+				return fmt.Errorf("failed to generate nonce: %w", err)
+			}
+			nonce = base64.RawURLEncoding.EncodeToString(raw)
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[resetNonceKey] = []byte(nonce)
+		secret.Data[resetSentKey] = []byte(time.Now().UTC().Format(time.RFC3339))
+		return s.Client.Update(ctx, &secret)
+	}); err != nil {
+		return err
+	}
+	if nonce == "" {
+		log.Info("Password reset requested too early, skipping the email")
+		return nil
+	}
+
+	key, err := signing.LoadKey(ctx, s.Client, s.Namespace)
+	if err != nil {
+		return err
+	}
+
+	token, err := signing.Sign(key, signing.PurposePasswordReset, string(user.UID)+"."+nonce, time.Now().Add(s.PasswordReset.TTL))
+	if err != nil {
+		return err
+	}
+
+	emailTemplate := productv1.EmailTemplate{}
+	if err := s.Client.Get(ctx, types.NamespacedName{
+		Name:      "example-webshop-service-password-reset",
+		Namespace: s.Namespace,
+	}, &emailTemplate); err != nil {
+		return err
+	}
+
+	renderer, err := template.New("password_reset_template").Parse(emailTemplate.Spec.Body)
+	if err != nil {
+		return err
+	}
+
+	userMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(user)
+	if err != nil {
+		return err
+	}
+	userMap["resetURL"] = s.PasswordReset.URL + "?token=" + url.QueryEscape(token)
+
+	var renderedBody bytes.Buffer
+	if err := renderer.Execute(&renderedBody, userMap); err != nil {
+		return err
+	}
+
+	email := productv1.Email{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-password-reset-%d", user.Name, time.Now().Unix()),
+			Namespace: user.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				},
+			},
+		},
+		Spec: productv1.EmailSpec{
+			ToAddress:   user.Spec.Email,
+			FromName:    emailTemplate.Spec.FromName,
+			FromAddress: emailTemplate.Spec.FromAddress,
+			Subject:     emailTemplate.Spec.Subject,
+			Body:        renderedBody.String(),
+		},
+	}
+	if err := s.Client.Create(ctx, &email); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *ApiService) completePasswordReset(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/password-reset/complete", "method", r.Method, "path", r.URL.Path)
+	log.Info("Password reset complete endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := PasswordResetCompleteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := passwords.Validate(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := signing.LoadKey(r.Context(), s.Client, s.Namespace)
+	if err != nil {
+		log.Error(err, "Signing key fetch failed")
+		http.Error(w, "failed to load signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subject, err := signing.Verify(key, signing.PurposePasswordReset, req.Token)
+	if err != nil {
+		log.Info("Reset token rejected", "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	uid, nonce, _ := strings.Cut(subject, ".")
+	log = log.WithValues("uid", uid)
+
+	users := productv1.UserList{}
+	if err := s.Client.List(r.Context(), &users, client.MatchingFields{"metadata.uid": uid}); err != nil {
+		log.Error(err, "User fetch failed")
+		http.Error(w, "failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if len(users.Items) == 0 || users.Items[0].Status.PasswordRef == nil {
+		http.Error(w, errUserNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	user := users.Items[0]
+
+	hash, err := passwords.Hash(req.Password)
+	if err != nil {
+		log.Error(err, "Password hashing failed")
+		http.Error(w, "failed to hash password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare(secret.Data[resetNonceKey], []byte(nonce)) != 1 {
+			return errResetTokenUsed
+		}
+
+		delete(secret.Data, resetNonceKey)
+		delete(secret.Data, resetSentKey)
+		secret.Data["hash"] = []byte(hash)
+		return s.Client.Update(r.Context(), &secret)
+	}); err != nil {
+		if errors.Is(err, errResetTokenUsed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Error(err, "Password update failed")
+		http.Error(w, "failed to update password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.revokeSessions(r.Context(), &user); err != nil {
+		log.Error(err, "Session revocation failed")
+		http.Error(w, "failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.recordUserEvent(r, &user, audit.PasswordReset, "Password has been reset")
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Password has been reset", "status", http.StatusNoContent)
+}
+
+// PasswordChangeRequest represents a signed in user replacing the password.
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (s *ApiService) changePassword(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/password", "method", r.Method, "path", r.URL.Path)
+	log.Info("Password change endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	req := PasswordChangeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "currentPassword and newPassword are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := passwords.Validate(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A stolen session must not allow guessing the password, the check counts against the login protection.
+	ip := clientIP(r)
+	if retryAfter, err := s.loginRetryAfter(r.Context(), ip, user); err != nil {
+		log.Error(err, "Login throttle check failed")
+		http.Error(w, "failed to check login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if retryAfter > 0 {
+		log.Info("Password change throttled", "retryAfter", retryAfter)
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
+
+	if _, err := s.authenticateUser(r, user.Spec.Email, req.CurrentPassword); err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			log.Info("Current password rejected")
+			if err := s.recordLoginFailure(r.Context(), ip, user); err != nil {
+				log.Error(err, "Login failure recording failed")
+			}
+			http.Error(w, "current password is invalid", http.StatusForbidden)
+			return
+		}
+
+		log.Error(err, "Password verification failed")
+		http.Error(w, "failed to verify password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.resetLoginFailures(r.Context(), user); err != nil {
+		log.Error(err, "Login failure reset failed")
+		http.Error(w, "failed to reset login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hash, err := passwords.Hash(req.NewPassword)
+	if err != nil {
+		log.Error(err, "Password hashing failed")
+		http.Error(w, "failed to hash password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+			return err
+		}
+
+		delete(secret.Data, resetNonceKey)
+		delete(secret.Data, resetSentKey)
+		secret.Data["hash"] = []byte(hash)
+		return s.Client.Update(r.Context(), &secret)
+	}); err != nil {
+		log.Error(err, "Password update failed")
+		http.Error(w, "failed to update password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.recordUserEvent(r, user, audit.PasswordChange, "Password has been changed")
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Password has been changed", "status", http.StatusNoContent)
+}
+
+// PasswordHashParams represents the argon2id parameters of a password hash.
+type PasswordHashParams struct {
+	Memory      uint32 `json:"memory"`
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	SaltLength  uint32 `json:"saltLength"`
+	KeyLength   uint32 `json:"keyLength"`
+}
+
+// OutdatedPasswordHash represents a user whose password hash was created with weaker parameters.
+type OutdatedPasswordHash struct {
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Email     string             `json:"email"`
+	Params    PasswordHashParams `json:"params"`
+}
+
+// PasswordHashReport lists the users still waiting for a login to rehash their password.
+type PasswordHashReport struct {
+	Params   PasswordHashParams     `json:"params"`
+	Users    int                    `json:"users"`
+	Outdated []OutdatedPasswordHash `json:"outdated"`
+}
+
+// rehashPassword replaces the hash of the password Secret if it was created with weaker parameters than the current ones.
+func (s *ApiService) rehashPassword(ctx context.Context, user *productv1.User, password string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+			return err
+		}
+
+		if outdated, err := passwords.Outdated(string(secret.Data["hash"])); err != nil || !outdated {
+			return err
+		}
+
+		hash, err := passwords.Hash(password)
+		if err != nil {
+			return err
+		}
+
+		secret.Data["hash"] = []byte(hash)
+		if err := s.Client.Update(ctx, &secret); err != nil {
+			return err
+		}
+
+		apiServiceLog.Info("Password has been rehashed", "namespace", user.Namespace, "name", user.Name)
+		return nil
+	})
+}
+
+func (s *ApiService) passwordHashReport(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/password-hashes", "method", r.Method, "path", r.URL.Path)
+	log.Info("Password hash report endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := s.authorize(r, &authorizationv1.ResourceAttributes{
+		Verb:     "list",
+		Group:    productv1.GroupVersion.Group,
+		Resource: "users",
+	}); err != nil {
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case errors.Is(err, errForbidden):
+			log.Info("Password hash report forbidden", "user", r.Header.Get("X-Remote-User"))
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			log.Error(err, "Authorization failed")
+			http.Error(w, "failed to authorize: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	users := productv1.UserList{}
+	if err := s.Client.List(r.Context(), &users); err != nil {
+		log.Error(err, "User fetch failed")
+		http.Error(w, "failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := PasswordHashReport{
+		Params:   hashParams(passwords.DefaultParams),
+		Outdated: []OutdatedPasswordHash{},
+	}
+	for i := range users.Items {
+		user := &users.Items[i]
+		if user.Status.PasswordRef == nil {
+			continue
+		}
+
+		secret := corev1.Secret{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			log.Error(err, "Secret fetch failed", "secretName", user.Status.PasswordRef.Name)
+			http.Error(w, "failed to get password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report.Users++
+
+		hash := string(secret.Data["hash"])
+		if outdated, err := passwords.Outdated(hash); err != nil {
+			log.Error(err, "Password hash decoding failed", "namespace", user.Namespace, "name", user.Name)
+			continue
+		} else if !outdated {
+			continue
+		}
+
+		params, _ := passwords.Params(hash)
+		report.Outdated = append(report.Outdated, OutdatedPasswordHash{
+			Namespace: user.Namespace,
+			Name:      user.Name,
+			Email:     user.Spec.Email,
+			Params:    hashParams(params),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&report)
+	log.Info("Password hash report served", "users", report.Users, "outdated", len(report.Outdated))
+}
+
+func hashParams(params *argon2id.Params) PasswordHashParams {
+	return PasswordHashParams{
+		Memory:      params.Memory,
+		Iterations:  params.Iterations,
+		Parallelism: params.Parallelism,
+		SaltLength:  params.SaltLength,
+		KeyLength:   params.KeyLength,
+	}
+}
@@ -0,0 +1,235 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+var _ = Describe("Password hash report endpoint", func() {
+	var (
+		server  *httptest.Server
+		proxied *http.Client
+		url     string
+	)
+
+	userWithHash := func(name, hash string) []client.Object {
+		user := &productv1.User{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name},
+			Spec:       productv1.UserSpec{Email: name + "@example.com"},
+		}
+		user.Status.PasswordRef = &corev1.LocalObjectReference{Name: name + "-password"}
+
+		return []client.Object{user, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name + "-password"},
+			Data:       map[string][]byte{"hash": []byte(hash)},
+		}}
+	}
+
+	BeforeEach(func() {
+		current, err := passwords.Hash("correct horse battery staple")
+		Expect(err).NotTo(HaveOccurred())
+		weak, err := argon2id.CreateHash("correct horse battery staple", &argon2id.Params{
+			Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		service := newTestService(allowUsers("admin"),
+			append(userWithHash("alice", current), userWithHash("bob", weak)...)...)
+		proxyCert := trustFrontProxy(service)
+
+		server = serveTLS(service, service.passwordHashReport)
+		proxied = clientOf(server, proxyCert)
+		url = server.URL + "/password-hashes"
+	})
+
+	It("should reject forged identity headers without the front proxy certificate", func() {
+		status, _ := do(clientOf(server), remoteRequest(http.MethodGet, url, "admin", "system:masters"))
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should forbid users who may not list users", func() {
+		status, _ := do(proxied, remoteRequest(http.MethodGet, url, "alice"))
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should report the hashes created with weaker parameters", func() {
+		status, body := do(proxied, remoteRequest(http.MethodGet, url, "admin"))
+		Expect(status).To(Equal(http.StatusOK))
+
+		report := PasswordHashReport{}
+		Expect(json.Unmarshal(body, &report)).To(Succeed())
+		Expect(report.Users).To(Equal(2))
+		Expect(report.Outdated).To(HaveLen(1))
+		Expect(report.Outdated[0].Name).To(Equal("bob"))
+		Expect(report.Outdated[0].Params.Memory).To(BeEquivalentTo(8 * 1024))
+	})
+})
+
+var _ = Describe("Password reset endpoints", func() {
+	var (
+		service     *ApiService
+		user        *productv1.User
+		request     *http.Client
+		requestURL  string
+		complete    *http.Client
+		completeURL string
+	)
+
+	passwordSecret := func() *corev1.Secret {
+		secret := corev1.Secret{}
+		Expect(service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret)).To(Succeed())
+		return &secret
+	}
+
+	resetToken := func(nonce string) string {
+		key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := signing.Sign(key, signing.PurposePasswordReset, string(user.UID)+"."+nonce, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		return token
+	}
+
+	emails := func() []productv1.Email {
+		list := productv1.EmailList{}
+		Expect(service.Client.List(context.Background(), &list, client.InNamespace(user.Namespace))).To(Succeed())
+		return list.Items
+	}
+
+	BeforeEach(func() {
+		var password *corev1.Secret
+		user, password = newTestUser("alice", "correct horse battery staple")
+		template := &productv1.EmailTemplate{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "webshop-system", Name: "example-webshop-service-password-reset"},
+			Spec: productv1.EmailTemplateSpec{
+				FromAddress: "noreply@example.com",
+				Subject:     "Reset your password",
+				Body:        "{{ .resetURL }}",
+			},
+		}
+		session := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: user.Namespace,
+				Name:      sessionSecretName(user, "first"),
+				Labels:    map[string]string{sessionLabel: "true", sessionUserLabel: string(user.UID)},
+			},
+		}
+		service = newTestService(interceptor.Funcs{}, user, password, template, session)
+		service.PasswordReset.URL = "https://shop.example.com/reset"
+
+		server := serveTLS(service, service.requestPasswordReset)
+		request = clientOf(server)
+		requestURL = server.URL
+		server = serveTLS(service, service.completePasswordReset)
+		complete = clientOf(server)
+		completeURL = server.URL
+	})
+
+	It("should not reveal whether the email address is registered", func() {
+		status, _ := do(request, anonymousRequest(http.MethodPost, requestURL, PasswordResetRequest{Email: "mallory@example.com"}))
+		Expect(status).To(Equal(http.StatusAccepted))
+		Expect(emails()).To(BeEmpty())
+	})
+
+	It("should email a reset link bound to a fresh nonce", func() {
+		status, _ := do(request, anonymousRequest(http.MethodPost, requestURL, PasswordResetRequest{Email: "alice@example.com"}))
+		Expect(status).To(Equal(http.StatusAccepted))
+
+		nonce := string(passwordSecret().Data[resetNonceKey])
+		Expect(nonce).NotTo(BeEmpty())
+
+		sent := emails()
+		Expect(sent).To(HaveLen(1))
+		Expect(sent[0].Spec.ToAddress).To(Equal("alice@example.com"))
+		Expect(sent[0].Spec.Body).To(HavePrefix("https://shop.example.com/reset?token="))
+	})
+
+	It("should keep the pending nonce and not resend the link within the resend interval", func() {
+		for range 3 {
+			status, _ := do(request, anonymousRequest(http.MethodPost, requestURL, PasswordResetRequest{Email: "alice@example.com"}))
+			Expect(status).To(Equal(http.StatusAccepted))
+		}
+		nonce := string(passwordSecret().Data[resetNonceKey])
+		Expect(emails()).To(HaveLen(1))
+
+		By("Requesting the link again after the resend interval")
+		secret := passwordSecret()
+		secret.Data[resetSentKey] = []byte(time.Now().Add(-passwordResetResendInterval).UTC().Format(time.RFC3339))
+		Expect(service.Client.Update(context.Background(), secret)).To(Succeed())
+		for _, email := range emails() {
+			Expect(service.Client.Delete(context.Background(), &email)).To(Succeed())
+		}
+
+		status, _ := do(request, anonymousRequest(http.MethodPost, requestURL, PasswordResetRequest{Email: "alice@example.com"}))
+		Expect(status).To(Equal(http.StatusAccepted))
+		Expect(string(passwordSecret().Data[resetNonceKey])).To(Equal(nonce))
+		Expect(emails()).To(HaveLen(1))
+	})
+
+	It("should reject weak passwords", func() {
+		status, _ := do(complete, anonymousRequest(http.MethodPost, completeURL, PasswordResetCompleteRequest{
+			Token:    resetToken("nonce"),
+			Password: "password",
+		}))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject tokens signed for another purpose", func() {
+		key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := signing.Sign(key, signing.PurposeVerify, string(user.UID)+".nonce", time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+
+		status, _ := do(complete, anonymousRequest(http.MethodPost, completeURL, PasswordResetCompleteRequest{
+			Token:    token,
+			Password: "N3w-Passphrase",
+		}))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should replace the password once and end the sessions of the user", func() {
+		status, _ := do(request, anonymousRequest(http.MethodPost, requestURL, PasswordResetRequest{Email: "alice@example.com"}))
+		Expect(status).To(Equal(http.StatusAccepted))
+		token := resetToken(string(passwordSecret().Data[resetNonceKey]))
+
+		status, _ = do(complete, anonymousRequest(http.MethodPost, completeURL, PasswordResetCompleteRequest{
+			Token:    token,
+			Password: "N3w-Passphrase",
+		}))
+		Expect(status).To(Equal(http.StatusNoContent))
+
+		secret := passwordSecret()
+		Expect(secret.Data).NotTo(HaveKey(resetNonceKey))
+		Expect(secret.Data).NotTo(HaveKey(resetSentKey))
+		Expect(passwords.Compare("N3w-Passphrase", string(secret.Data["hash"]))).To(BeTrue())
+
+		err := service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: user.Namespace, Name: sessionSecretName(user, "first")}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		By("Reusing the token")
+		status, _ = do(complete, anonymousRequest(http.MethodPost, completeURL, PasswordResetCompleteRequest{
+			Token:    token,
+			Password: "An0ther-Passphrase",
+		}))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,281 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// phoneCodeTTL is the time a text message code can be confirmed in.
+	phoneCodeTTL = 10 * time.Minute
+	// phoneCodeResendInterval is the time a new code can be requested after.
+	phoneCodeResendInterval = time.Minute
+	// phoneCodeMaxAttempts is the number of wrong codes invalidating a pending verification.
+	phoneCodeMaxAttempts = 5
+)
+
+var (
+	errPhoneNumberMissing    = errors.New("phone number is not set")
+	errPhoneNotPending       = errors.New("no pending phone number verification")
+	errPhoneNumberChanged    = errors.New("phone number changed since the code was sent")
+	errInvalidPhoneCode      = errors.New("invalid or expired code")
+	errPhoneAttemptsExceeded = errors.New("too many wrong codes, request a new one")
+)
+
+// PhoneConfirmRequest represents the code received in the text message.
+type PhoneConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+// PhoneVerifyResponse represents a pending phone number verification.
+type PhoneVerifyResponse struct {
+	PhoneNumber         string      `json:"phoneNumber"`
+	ExpirationTimestamp metav1.Time `json:"expirationTimestamp"`
+}
+
+func (s *ApiService) verifyPhone(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/phone/verify", "method", r.Method, "path", r.URL.Path)
+	log.Info("Phone verify endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	if user.Spec.PhoneNumber == nil || *user.Spec.PhoneNumber == "" {
+		http.Error(w, errPhoneNumberMissing.Error(), http.StatusConflict)
+		return
+	}
+	phoneNumber := *user.Spec.PhoneNumber
+
+	secret := corev1.Secret{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: phoneSecretName(user)}, &secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Secret fetch failed", "secretName", phoneSecretName(user))
+			http.Error(w, "failed to get pending verification: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if sent, err := time.Parse(time.RFC3339, string(secret.Data["sentTimestamp"])); err == nil {
+		if retryAfter := time.Until(sent.Add(phoneCodeResendInterval)); retryAfter > 0 {
+			log.Info("Phone code requested too early", "retryAfter", retryAfter)
+			writeTooManyAttempts(w, retryAfter)
+			return
+		}
+	}
+
+	code, err := generatePhoneCode()
+	if err != nil {
+		log.Error(err, "Code generation failed")
+		http.Error(w, "failed to generate code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(phoneCodeTTL)
+	sum := sha256.Sum256([]byte(code))
+	data := map[string][]byte{
+		"phoneNumber":         []byte(phoneNumber),
+		"code":                []byte(hex.EncodeToString(sum[:])),
+		"attempts":            []byte("0"),
+		"sentTimestamp":       []byte(now.UTC().Format(time.RFC3339)),
+		"expirationTimestamp": []byte(expiresAt.UTC().Format(time.RFC3339)),
+	}
+
+	secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      phoneSecretName(user),
+			Namespace: user.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				},
+			},
+		},
+		Data: data,
+	}
+	if err := s.Client.Create(r.Context(), &secret); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "Secret creation failed", "secretName", secret.Name)
+			http.Error(w, "failed to store code: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// A pending verification is replaced.
+		if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &secret); err != nil {
+				return err
+			}
+
+			secret.Data = data
+			return s.Client.Update(r.Context(), &secret)
+		}); err != nil {
+			log.Error(err, "Secret update failed", "secretName", secret.Name)
+			http.Error(w, "failed to store code: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	message := fmt.Sprintf("Your HariKube verification code is %s. It expires in %d minutes.", code, int(phoneCodeTTL.Minutes()))
+	if err := s.SMS.Send(r.Context(), phoneNumber, message); err != nil {
+		log.Error(err, "SMS sending failed")
+		http.Error(w, "failed to send code: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(&PhoneVerifyResponse{
+		PhoneNumber:         phoneNumber,
+		ExpirationTimestamp: metav1.NewTime(expiresAt),
+	})
+	log.Info("Phone code sent", "status", http.StatusAccepted)
+}
+
+func (s *ApiService) confirmPhone(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/phone/confirm", "method", r.Method, "path", r.URL.Path)
+	log.Info("Phone confirm endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	req := PhoneConfirmRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	phoneNumber, err := s.checkPhoneCode(r.Context(), user, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidPhoneCode):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errPhoneAttemptsExceeded):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, errPhoneNotPending), errors.Is(err, errPhoneNumberChanged):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error(err, "Phone code verification failed")
+			http.Error(w, "failed to verify code: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := productv1.User{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: user.Name}, &current); err != nil {
+			return err
+		}
+
+		current.Status.VerifiedPhoneNumber = phoneNumber
+		current.Status.PhoneNumberVerifiedTimestamp = metav1.Now()
+		return s.Client.Status().Update(r.Context(), &current)
+	}); err != nil {
+		log.Error(err, "User status update failed")
+		http.Error(w, "failed to verify phone number: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Phone number verified", "status", http.StatusNoContent)
+}
+
+// checkPhoneCode checks the code against the pending verification and consumes it on success,
+// wrong codes are counted and invalidate the verification after phoneCodeMaxAttempts.
+func (s *ApiService) checkPhoneCode(ctx context.Context, user *productv1.User, code string) (string, error) {
+	phoneNumber := ""
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secret := corev1.Secret{}
+		if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: phoneSecretName(user)}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return errPhoneNotPending
+			}
+			return err
+		}
+
+		phoneNumber = string(secret.Data["phoneNumber"])
+		if user.Spec.PhoneNumber == nil || *user.Spec.PhoneNumber != phoneNumber {
+			return errPhoneNumberChanged
+		}
+
+		expiresAt, err := time.Parse(time.RFC3339, string(secret.Data["expirationTimestamp"]))
+		if err != nil || time.Now().After(expiresAt) {
+			return errInvalidPhoneCode
+		}
+
+		attempts, _ := strconv.Atoi(string(secret.Data["attempts"]))
+		if attempts >= phoneCodeMaxAttempts {
+			return errPhoneAttemptsExceeded
+		}
+
+		sum := sha256.Sum256([]byte(code))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), secret.Data["code"]) != 1 {
+			secret.Data["attempts"] = []byte(strconv.Itoa(attempts + 1))
+			if err := s.Client.Update(ctx, &secret); err != nil {
+				return err
+			}
+			return errInvalidPhoneCode
+		}
+
+		// Deleting with the read resource version makes the code single-use across replicas.
+		return s.Client.Delete(ctx, &secret, client.Preconditions{ResourceVersion: &secret.ResourceVersion})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return phoneNumber, nil
+}
+
+// generatePhoneCode returns a random six digit code.
+func generatePhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func phoneSecretName(user *productv1.User) string {
+	return string(user.UID) + "-phone"
+}
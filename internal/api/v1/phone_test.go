@@ -0,0 +1,160 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+// recordingSender records the text messages instead of sending them.
+type recordingSender struct {
+	to       []string
+	messages []string
+	err      error
+}
+
+func (s *recordingSender) Send(_ context.Context, to, message string) error {
+	s.to = append(s.to, to)
+	s.messages = append(s.messages, message)
+	return s.err
+}
+
+var phoneCodePattern = regexp.MustCompile(`code is (\d+)`)
+
+var _ = Describe("Phone verification endpoints", func() {
+	var (
+		service *ApiService
+		sender  *recordingSender
+		user    *productv1.User
+		proxied func(handler http.HandlerFunc) (*http.Client, string)
+	)
+
+	BeforeEach(func() {
+		var password *corev1.Secret
+		user, password = newTestUser("alice", "correct horse battery staple")
+		user.Spec.PhoneNumber = ptr.To("+36201234567")
+
+		service = newTestService(interceptor.Funcs{}, user, password)
+		sender = &recordingSender{}
+		service.SMS = sender
+		proxyCert := trustFrontProxy(service)
+
+		proxied = func(handler http.HandlerFunc) (*http.Client, string) {
+			server := serveTLS(service, handler)
+			return clientOf(server, proxyCert), server.URL
+		}
+	})
+
+	sendCode := func() string {
+		httpClient, url := proxied(service.verifyPhone)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", nil))
+		Expect(status).To(Equal(http.StatusAccepted))
+
+		Expect(sender.messages).NotTo(BeEmpty())
+		match := phoneCodePattern.FindStringSubmatch(sender.messages[len(sender.messages)-1])
+		Expect(match).To(HaveLen(2))
+		return match[1]
+	}
+
+	confirm := func(code string) int {
+		httpClient, url := proxied(service.confirmPhone)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", PhoneConfirmRequest{Code: code}))
+		return status
+	}
+
+	updatePending := func(update func(secret *corev1.Secret)) {
+		secret := corev1.Secret{}
+		Expect(service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: user.Namespace, Name: phoneSecretName(user)}, &secret)).To(Succeed())
+		update(&secret)
+		Expect(service.Client.Update(context.Background(), &secret)).To(Succeed())
+	}
+
+	DescribeTable("should reject a forged ServiceAccount identity without the front proxy certificate",
+		func(handler func(*ApiService) http.HandlerFunc) {
+			server := serveTLS(service, handler(service))
+			status, _ := do(clientOf(server), userRequest(http.MethodPost, server.URL, user, "", PhoneConfirmRequest{Code: "123456"}))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+			Expect(sender.messages).To(BeEmpty())
+		},
+		Entry("verify", func(s *ApiService) http.HandlerFunc { return s.verifyPhone }),
+		Entry("confirm", func(s *ApiService) http.HandlerFunc { return s.confirmPhone }),
+	)
+
+	It("should send the code to the phone number of the user", func() {
+		code := sendCode()
+		Expect(sender.to).To(Equal([]string{"+36201234567"}))
+		Expect(code).To(HaveLen(6))
+	})
+
+	It("should not send a new code before the resend interval", func() {
+		sendCode()
+
+		httpClient, url := proxied(service.verifyPhone)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", nil))
+		Expect(status).To(Equal(http.StatusTooManyRequests))
+		Expect(sender.messages).To(HaveLen(1))
+	})
+
+	It("should report a failing SMS sender", func() {
+		sender.err = errors.New("gateway down")
+
+		httpClient, url := proxied(service.verifyPhone)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", nil))
+		Expect(status).To(Equal(http.StatusBadGateway))
+	})
+
+	It("should verify the phone number with the code once", func() {
+		code := sendCode()
+		Expect(confirm(code)).To(Equal(http.StatusNoContent))
+
+		current := productv1.User{}
+		Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(user), &current)).To(Succeed())
+		Expect(current.Status.VerifiedPhoneNumber).To(Equal("+36201234567"))
+
+		Expect(confirm(code)).To(Equal(http.StatusConflict))
+	})
+
+	It("should reject an expired code", func() {
+		code := sendCode()
+		updatePending(func(secret *corev1.Secret) {
+			secret.Data["expirationTimestamp"] = []byte(time.Now().Add(-time.Second).UTC().Format(time.RFC3339))
+		})
+
+		Expect(confirm(code)).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should invalidate the code after too many wrong attempts", func() {
+		code := sendCode()
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+
+		for range phoneCodeMaxAttempts {
+			Expect(confirm(wrong)).To(Equal(http.StatusBadRequest))
+		}
+		Expect(confirm(code)).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("should reject the code after the phone number changed", func() {
+		code := sendCode()
+		user.Spec.PhoneNumber = ptr.To("+36207654321")
+		Expect(service.Client.Update(context.Background(), user)).To(Succeed())
+
+		Expect(confirm(code)).To(Equal(http.StatusConflict))
+	})
+})
@@ -0,0 +1,362 @@
+package v1
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+var (
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// RegistryTokenConfig configures the Docker Registry v2 token authentication endpoint.
+type RegistryTokenConfig struct {
+	// Addr is the address the endpoint is served on at /token. Registry clients authenticate with basic auth,
+	// which the kube-apiserver does not forward to aggregated APIs, so the endpoint has a listener of its own.
+	Addr string
+	// Issuer is the value of the iss claim, it must match the issuer configured in the registry.
+	Issuer string
+	// TTL is the lifetime of the issued bearer tokens.
+	TTL time.Duration
+	// GetCertificate returns the certificate and key used to sign the bearer tokens.
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+type registryAccess struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+type registryClaims struct {
+	Issuer    string           `json:"iss"`
+	Subject   string           `json:"sub"`
+	Audience  string           `json:"aud,omitempty"`
+	ExpiresAt int64            `json:"exp"`
+	NotBefore int64            `json:"nbf"`
+	IssuedAt  int64            `json:"iat"`
+	ID        string           `json:"jti"`
+	Access    []registryAccess `json:"access"`
+}
+
+type registryTokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IssuedAt    string `json:"issued_at"`
+}
+
+// serveRegistryToken serves the token endpoint to registry clients connecting directly, with the serving
+// certificate of the API service.
+func (s *ApiService) serveRegistryToken(ctx context.Context) error {
+	certificate, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load serving certificate: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", s.issueRegistryToken)
+
+	apiServiceLog.Info("Serving registry token endpoint", "addr", s.RegistryToken.Addr)
+	return listenAndServeTLS(ctx, &http.Server{
+		Addr:              s.RegistryToken.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+		},
+	})
+}
+
+// issueRegistryToken implements the token endpoint of the Docker Registry v2 token authentication protocol.
+// Credentials are checked against the Secrets of RegistryTokens and the granted pull scopes are limited
+// to the repositories entitled by the addons of the non-expired Licences of the tenant.
+func (s *ApiService) issueRegistryToken(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "registrytokens/token", "method", r.Method, "path", r.URL.Path)
+	log.Info("Token endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.RegistryToken == nil {
+		http.Error(w, "registry token service is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	log = log.WithValues("username", username)
+
+	token, err := s.authenticateRegistryToken(r, username, password)
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			log.Info("Registry authentication failed")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		log.Error(err, "Registry authentication failed")
+		http.Error(w, "failed to authenticate: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	repositories, err := s.entitledRepositories(r, token.Namespace)
+	if err != nil {
+		log.Error(err, "Licence fetch failed")
+		http.Error(w, "failed to list licences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	access := []registryAccess{}
+	for _, scope := range r.URL.Query()["scope"] {
+		for _, requested := range strings.Split(scope, " ") {
+			resourceType, name, actions, ok := parseRegistryScope(requested)
+			if !ok || resourceType != "repository" || !slices.Contains(actions, "pull") {
+				continue
+			}
+
+			if slices.ContainsFunc(repositories, func(pattern string) bool {
+				matched, err := path.Match(pattern, name)
+				return err == nil && matched
+			}) {
+				access = append(access, registryAccess{
+					Type:    resourceType,
+					Name:    name,
+					Actions: []string{"pull"},
+				})
+			}
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.RegistryToken.TTL)
+	if token.Spec.ExpireTimestamp.Time.Before(expiresAt) {
+		expiresAt = token.Spec.ExpireTimestamp.Time
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		log.Error(err, "Token id generation failed")
+		http.Error(w, "failed to generate token id", http.StatusInternalServerError)
+		return
+	}
+
+	signed, err := s.signRegistryToken(&registryClaims{
+		Issuer:    s.RegistryToken.Issuer,
+		Subject:   username,
+		Audience:  r.URL.Query().Get("service"),
+		ExpiresAt: expiresAt.Unix(),
+		NotBefore: now.Add(-time.Minute).Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        base64.RawURLEncoding.EncodeToString(jti),
+		Access:    access,
+	})
+	if err != nil {
+		log.Error(err, "Token signing failed")
+		http.Error(w, "failed to sign token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&registryTokenResponse{
+		Token:       signed,
+		AccessToken: signed,
+		ExpiresIn:   int64(expiresAt.Sub(now).Seconds()),
+		IssuedAt:    now.UTC().Format(time.RFC3339),
+	})
+	log.Info("Registry token issued", "scopes", len(access))
+}
+
+// authenticateRegistryToken resolves the RegistryToken named by the namespace/name username and checks the password.
+// The previous credential of a rotated token is accepted until its own expiry. Tokens of suspended tenants are rejected
+// without waiting for the controller to withdraw their credentials.
+func (s *ApiService) authenticateRegistryToken(r *http.Request, username, password string) (*productv1.RegistryToken, error) {
+	namespace, name, ok := strings.Cut(username, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, errInvalidCredentials
+	}
+
+	token := productv1.RegistryToken{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: namespace, Name: name}, &token); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.DeletionTimestamp != nil || token.Status.Phase == "Expired" || token.Status.Phase == "Suspended" ||
+		token.Spec.ExpireTimestamp.Time.Before(now) {
+		return nil, errInvalidCredentials
+	}
+
+	if tenant, err := tenancy.TenantOf(r.Context(), s.Client, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	} else if tenant.Spec.Suspended {
+		return nil, errInvalidCredentials
+	}
+
+	refs := []*corev1.LocalObjectReference{token.Status.TokenRef}
+	if token.Status.PreviousExpireTimestamp.Time.After(now) {
+		refs = append(refs, token.Status.PreviousTokenRef)
+	}
+
+	for _, ref := range refs {
+		if ref == nil {
+			continue
+		}
+
+		secret := corev1.Secret{}
+		if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if subtle.ConstantTimeCompare(secret.Data["username"], []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare(secret.Data["password"], []byte(password)) == 1 {
+			return &token, nil
+		}
+	}
+
+	return nil, errInvalidCredentials
+}
+
+// entitledRepositories collects the repository patterns of the addons of all non-expired, non-suspended Licences
+// in the namespace.
+func (s *ApiService) entitledRepositories(r *http.Request, namespace string) ([]string, error) {
+	licences := productv1.LicenceList{}
+	if err := s.Client.List(r.Context(), &licences, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	repositories := []string{}
+	for _, licence := range licences.Items {
+		if licence.Status.Suspended ||
+			(!licence.Spec.ExpireTimestamp.IsZero() && licence.Spec.ExpireTimestamp.Time.Before(time.Now())) {
+			continue
+		}
+
+		for _, addon := range licence.Spec.Addons {
+			repositories = append(repositories, addon.Spec.Repositories...)
+		}
+	}
+
+	return repositories, nil
+}
+
+// signRegistryToken serializes the claims into a JWT signed by the configured certificate,
+// the certificate chain is embedded into the x5c header for the registry to verify.
+func (s *ApiService) signRegistryToken(claims *registryClaims) (string, error) {
+	cert, err := s.RegistryToken.GetCertificate(nil)
+	if err != nil {
+		return "", err
+	} else if cert == nil {
+		return "", errors.New("signing certificate is not loaded")
+	}
+
+	x5c := make([]string, 0, len(cert.Certificate))
+	for _, der := range cert.Certificate {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(der))
+	}
+
+	var alg string
+	switch key := cert.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if key.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("unsupported ecdsa curve %s", key.Curve.Params().Name)
+		}
+		alg = "ES256"
+	default:
+		return "", fmt.Errorf("unsupported signing key %T", cert.PrivateKey)
+	}
+
+	header, err := json.Marshal(map[string]any{
+		"typ": "JWT",
+		"alg": alg,
+		"x5c": x5c,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch key := cert.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		var sr, ss *big.Int
+		if sr, ss, err = ecdsa.Sign(rand.Reader, key, digest[:]); err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		sr.FillBytes(signature[:32])
+		ss.FillBytes(signature[32:])
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseRegistryScope splits a scope of the form type:name:action[,action...].
+func parseRegistryScope(scope string) (string, string, []string, bool) {
+	resourceType, rest, ok := strings.Cut(scope, ":")
+	if !ok {
+		return "", "", nil, false
+	}
+
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", "", nil, false
+	}
+
+	return resourceType, rest[:i], strings.Split(rest[i+1:], ","), true
+}
@@ -0,0 +1,265 @@
+package v1
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+// decodeRegistryToken splits the JWT and verifies its signature with the leaf of the x5c chain.
+func decodeRegistryToken(token string) (map[string]any, registryClaims, []*x509.Certificate) {
+	parts := strings.Split(token, ".")
+	Expect(parts).To(HaveLen(3))
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	Expect(err).NotTo(HaveOccurred())
+	header := map[string]any{}
+	Expect(json.Unmarshal(rawHeader, &header)).To(Succeed())
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	Expect(err).NotTo(HaveOccurred())
+	claims := registryClaims{}
+	Expect(json.Unmarshal(rawClaims, &claims)).To(Succeed())
+
+	chain := []*x509.Certificate{}
+	for _, entry := range header["x5c"].([]any) {
+		der, err := base64.StdEncoding.DecodeString(entry.(string))
+		Expect(err).NotTo(HaveOccurred())
+		certificate, err := x509.ParseCertificate(der)
+		Expect(err).NotTo(HaveOccurred())
+		chain = append(chain, certificate)
+	}
+	Expect(chain).NotTo(BeEmpty())
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	Expect(err).NotTo(HaveOccurred())
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch key := chain[0].PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		Expect(signature).To(HaveLen(64))
+		Expect(ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]))).To(BeTrue())
+	case *rsa.PublicKey:
+		Expect(rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)).To(Succeed())
+	default:
+		Fail("unexpected public key")
+	}
+
+	return header, claims, chain
+}
+
+// rsaSigningCertificate returns a self-signed RSA certificate for signing registry tokens.
+func rsaSigningCertificate() tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "registry-token"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+var _ = Describe("Registry token endpoint", func() {
+	DescribeTable("should parse scopes",
+		func(scope, resourceType, name string, actions []string, ok bool) {
+			gotType, gotName, gotActions, gotOK := parseRegistryScope(scope)
+			Expect(gotOK).To(Equal(ok))
+			Expect(gotType).To(Equal(resourceType))
+			Expect(gotName).To(Equal(name))
+			Expect(gotActions).To(Equal(actions))
+		},
+		Entry("repository pull", "repository:acme/app:pull", "repository", "acme/app", []string{"pull"}, true),
+		Entry("multiple actions", "repository:acme/app:pull,push", "repository", "acme/app", []string{"pull", "push"}, true),
+		Entry("registry host with port", "repository:registry:5000/acme/app:pull", "repository", "registry:5000/acme/app", []string{"pull"}, true),
+		Entry("missing actions", "repository:acme/app", "", "", nil, false),
+		Entry("missing name", "repository::pull", "", "", nil, false),
+		Entry("no separator", "repository", "", "", nil, false),
+	)
+
+	Describe("signing", func() {
+		var service *ApiService
+
+		BeforeEach(func() {
+			service = newTestService(interceptor.Funcs{})
+		})
+
+		sign := func(certificate tls.Certificate) string {
+			service.RegistryToken = &RegistryTokenConfig{
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &certificate, nil },
+			}
+
+			signed, err := service.signRegistryToken(&registryClaims{
+				Issuer:    "webshop",
+				Subject:   "tenant-acme/ci",
+				ExpiresAt: 1700000300,
+				Access:    []registryAccess{{Type: "repository", Name: "acme/app", Actions: []string{"pull"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return signed
+		}
+
+		It("should sign with ES256 and embed the certificate chain", func() {
+			ca := newTestCA("registry-ca")
+			certificate := ca.issue("registry-token")
+			certificate.Certificate = append(certificate.Certificate, ca.certificate.Raw)
+
+			header, claims, chain := decodeRegistryToken(sign(certificate))
+			Expect(header).To(HaveKeyWithValue("alg", "ES256"))
+			Expect(header).To(HaveKeyWithValue("typ", "JWT"))
+			Expect(chain).To(HaveLen(2))
+			Expect(chain[0].Subject.CommonName).To(Equal("registry-token"))
+			Expect(chain[1].Equal(ca.certificate)).To(BeTrue())
+			Expect(claims.Issuer).To(Equal("webshop"))
+			Expect(claims.Subject).To(Equal("tenant-acme/ci"))
+			Expect(claims.Access).To(ConsistOf(registryAccess{Type: "repository", Name: "acme/app", Actions: []string{"pull"}}))
+		})
+
+		It("should sign with RS256", func() {
+			header, _, chain := decodeRegistryToken(sign(rsaSigningCertificate()))
+			Expect(header).To(HaveKeyWithValue("alg", "RS256"))
+			Expect(chain).To(HaveLen(1))
+		})
+	})
+
+	Describe("issuing", func() {
+		var (
+			server  *httptest.Server
+			service *ApiService
+			token   *productv1.RegistryToken
+			tenant  *productv1.Tenant
+			licence *productv1.Licence
+		)
+
+		BeforeEach(func() {
+			token = &productv1.RegistryToken{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "ci"},
+				Spec:       productv1.RegistryTokenSpec{ExpireTimestamp: metav1.NewTime(time.Now().Add(time.Hour))},
+			}
+			token.Status.Phase = "Active"
+			token.Status.TokenRef = &corev1.LocalObjectReference{Name: "ci-credentials"}
+
+			tenant = &productv1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: "acme"}}
+			licence = &productv1.Licence{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "pro"},
+				Spec: productv1.LicenceSpec{
+					Addons: []productv1.Addon{{Spec: productv1.AddonSpec{Repositories: []string{"acme/*"}}}},
+				},
+			}
+
+			service = newTestService(interceptor.Funcs{}, token, licence, tenant, &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant-acme", Labels: map[string]string{tenancy.TenantLabel: "acme"}},
+			}, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: "ci-credentials"},
+				Data:       map[string][]byte{"username": []byte("tenant-acme/ci"), "password": []byte("s3cret")},
+			})
+			signing := newTestCA("registry-token").issue("registry-token")
+			service.RegistryToken = &RegistryTokenConfig{
+				Issuer: "webshop",
+				TTL:    5 * time.Minute,
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return &signing, nil
+				},
+			}
+
+			// Registry clients connect to the token listener directly, not through the front proxy.
+			server = httptest.NewTLSServer(http.HandlerFunc(service.issueRegistryToken))
+			DeferCleanup(server.Close)
+		})
+
+		request := func(password string) *http.Request {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/token?service=registry&scope="+
+				"repository:acme/app:pull,push+repository:other/app:pull", nil)
+			Expect(err).NotTo(HaveOccurred())
+			if password != "" {
+				req.SetBasicAuth("tenant-acme/ci", password)
+			}
+			return req
+		}
+
+		It("should challenge requests without basic auth", func() {
+			resp, err := server.Client().Do(request(""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(resp.Header.Get("WWW-Authenticate")).To(HavePrefix("Basic"))
+		})
+
+		It("should reject a wrong password", func() {
+			status, _ := do(server.Client(), request("wrong"))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should grant pull on the entitled repositories only", func() {
+			status, body := do(server.Client(), request("s3cret"))
+			Expect(status).To(Equal(http.StatusOK))
+
+			response := registryTokenResponse{}
+			Expect(json.Unmarshal(body, &response)).To(Succeed())
+			Expect(response.AccessToken).To(Equal(response.Token))
+			Expect(response.ExpiresIn).To(BeNumerically("~", 300, 1))
+
+			_, claims, _ := decodeRegistryToken(response.Token)
+			Expect(claims.Audience).To(Equal("registry"))
+			Expect(claims.Subject).To(Equal("tenant-acme/ci"))
+			Expect(claims.Access).To(ConsistOf(registryAccess{Type: "repository", Name: "acme/app", Actions: []string{"pull"}}))
+		})
+
+		It("should reject suspended tokens", func() {
+			token.Status.Phase = "Suspended"
+			Expect(service.Client.Status().Update(context.Background(), token)).To(Succeed())
+
+			status, _ := do(server.Client(), request("s3cret"))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should reject the tokens of suspended tenants before the controller withdraws them", func() {
+			tenant.Spec.Suspended = true
+			Expect(service.Client.Update(context.Background(), tenant)).To(Succeed())
+
+			status, _ := do(server.Client(), request("s3cret"))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should not grant the repositories of suspended licences", func() {
+			licence.Status.Suspended = true
+			Expect(service.Client.Status().Update(context.Background(), licence)).To(Succeed())
+
+			status, body := do(server.Client(), request("s3cret"))
+			Expect(status).To(Equal(http.StatusOK))
+
+			response := registryTokenResponse{}
+			Expect(json.Unmarshal(body, &response)).To(Succeed())
+			_, claims, _ := decodeRegistryToken(response.Token)
+			Expect(claims.Access).To(BeEmpty())
+		})
+	})
+})
@@ -3,178 +3,332 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	kaf "github.com/HariKube/kubernetes-aggregator-framework/pkg/framework"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/sms"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	yaml "sigs.k8s.io/yaml"
 )
 
+const (
+	// cleanupInterval is how often expired login throttles and sessions are removed, it does not follow
+	// the configured lifetimes so short ones cannot turn the cleanup into a hot loop.
+	cleanupInterval = time.Minute
+)
+
 var (
 	apiServiceLog = logf.Log.WithName("api-service")
 )
 
-func New(dynamicClient dynamic.Interface, scheme *runtime.Scheme, port, certPath, certFile, keyFile, namespace string) *ApiService {
+func New(kubeClient client.Client, dynamicClient dynamic.Interface, scheme *runtime.Scheme, port, certPath, certFile, keyFile, namespace string) *ApiService {
+	certFile = fmt.Sprintf("%s%c%s", certPath, os.PathSeparator, certFile)
+	keyFile = fmt.Sprintf("%s%c%s", certPath, os.PathSeparator, keyFile)
+
 	sas := ApiService{
+		addr:          port,
+		certFile:      certFile,
+		keyFile:       keyFile,
+		Client:        kubeClient,
+		Reader:        kubeClient,
 		DynamicClient: dynamicClient,
 		Scheme:        scheme,
-		Server: *kaf.NewServer(kaf.ServerConfig{
-			Port:     port,
-			CertFile: fmt.Sprintf("%s%c%s", certPath, os.PathSeparator, certFile),
-			KeyFile:  fmt.Sprintf("%s%c%s", certPath, os.PathSeparator, keyFile),
-			Group:    "api." + productv1.GroupVersion.Group,
-			Version:  productv1.GroupVersion.Version,
-			APIKinds: []kaf.APIKind{
-				{
-					ApiResource: metav1.APIResource{
-						Name:  "registrations",
-						Verbs: []string{"create"},
-					},
-					CustomResource: &kaf.CustomResource{
-						CreateHandler: func(namespace, name string, w http.ResponseWriter, r *http.Request) {
-							log := apiServiceLog.WithValues("handler", "registrations", "method", r.Method, "path", r.URL.Path)
-							log.Info("Received registration request")
-
-							body, err := io.ReadAll(r.Body)
-							if err != nil {
-								log.Error(err, "Failed to read request body")
-								http.Error(w, "failed to read request body: "+err.Error(), http.StatusBadRequest)
+		Namespace:     namespace,
+		LoginTTL:      time.Hour,
+		SMS:           &sms.LogSender{Log: apiServiceLog.WithName("sms")},
+		PasswordReset: PasswordResetConfig{
+			TTL: time.Hour,
+		},
+		LoginProtection: LoginProtectionConfig{
+			MaxAttempts:      5,
+			MaxAttemptsPerIP: 20,
+			LockoutDuration:  15 * time.Minute,
+			BaseDelay:        time.Second,
+			MaxDelay:         30 * time.Second,
+		},
+	}
+	sas.Server = *kaf.NewServer(kaf.ServerConfig{
+		KubeClient: kubeClient,
+		Port:       backendAddr,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		Group:      "api." + productv1.GroupVersion.Group,
+		Version:    productv1.GroupVersion.Version,
+		APIKinds: []kaf.APIKind{
+			{
+				ApiResource: metav1.APIResource{
+					Name:  "registrations",
+					Verbs: []string{"create"},
+				},
+				CustomResource: &kaf.CustomResource{
+					CreateHandler: func(namespace, name string, w http.ResponseWriter, r *http.Request) {
+						log := apiServiceLog.WithValues("handler", "registrations", "method", r.Method, "path", r.URL.Path)
+						log.Info("Received registration request")
+
+						body, err := io.ReadAll(r.Body)
+						if err != nil {
+							log.Error(err, "Failed to read request body")
+							http.Error(w, "failed to read request body: "+err.Error(), http.StatusBadRequest)
+							return
+						}
+						log.Info("Request body read successfully", "size", len(body))
+
+						var req productv1.RegistrationRequest
+
+						ct := strings.ToLower(r.Header.Get("Content-Type"))
+						log.Info("Processing request", "contentType", ct)
+						switch {
+						case strings.Contains(ct, "json") || strings.HasPrefix(strings.TrimSpace(string(body)), "{"):
+							if err := json.Unmarshal(body, &req); err != nil {
+								log.Error(err, "Failed to decode JSON request")
+								http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
 								return
 							}
-							log.Info("Request body read successfully", "size", len(body))
-
-							var req productv1.RegistrationRequest
-
-							ct := strings.ToLower(r.Header.Get("Content-Type"))
-							log.Info("Processing request", "contentType", ct)
-							switch {
-							case strings.Contains(ct, "json") || strings.HasPrefix(strings.TrimSpace(string(body)), "{"):
-								if err := json.Unmarshal(body, &req); err != nil {
-									log.Error(err, "Failed to decode JSON request")
-									http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
-									return
-								}
-								log.Info("Successfully decoded JSON request")
-							case strings.Contains(ct, "yaml"), strings.Contains(ct, "x-yaml"):
-								fallthrough
-							default:
-								if err := yaml.Unmarshal(body, &req); err != nil {
-									log.Error(err, "Failed to decode YAML request")
-									http.Error(w, "failed to decode yaml request: "+err.Error(), http.StatusBadRequest)
-									return
-								}
-								log.Info("Successfully decoded YAML request")
+							log.Info("Successfully decoded JSON request")
+						case strings.Contains(ct, "yaml"), strings.Contains(ct, "x-yaml"):
+							fallthrough
+						default:
+							if err := yaml.Unmarshal(body, &req); err != nil {
+								log.Error(err, "Failed to decode YAML request")
+								http.Error(w, "failed to decode yaml request: "+err.Error(), http.StatusBadRequest)
+								return
 							}
+							log.Info("Successfully decoded YAML request")
+						}
 
-							req.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("RegistrationRequest"))
-							log.Info("RegistrationRequest details", "name", req.GetName(), "email", req.Spec.User.Email)
+						req.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("RegistrationRequest"))
+						log.Info("RegistrationRequest details", "name", req.GetName(), "email", req.Spec.User.Email)
 
-							uriParts := strings.Split(r.RequestURI, "/")
-							req.Name = uriParts[len(uriParts)-1]
+						uriParts := strings.Split(r.RequestURI, "/")
+						req.Name = uriParts[len(uriParts)-1]
 
-							objMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&req)
-							if err != nil {
-								log.Error(err, "Failed to convert object to unstructured")
-								http.Error(w, "failed to convert object to unstructured: "+err.Error(), http.StatusInternalServerError)
+						objMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&req)
+						if err != nil {
+							log.Error(err, "Failed to convert object to unstructured")
+							http.Error(w, "failed to convert object to unstructured: "+err.Error(), http.StatusInternalServerError)
+							return
+						}
+
+						gvr := schema.GroupVersionResource{Group: productv1.GroupVersion.Group, Version: productv1.GroupVersion.Version, Resource: "registrationrequests"}
+						log.Info("Creating RegistrationRequest", "gvr", gvr.String(), "name", req.GetName())
+
+						_, err = dynamicClient.Resource(gvr).Namespace(namespace).Create(r.Context(), &unstructured.Unstructured{Object: objMap}, metav1.CreateOptions{})
+						if err != nil {
+							if apierrors.IsAlreadyExists(err) {
+								log.Info("RegistrationRequest already exists", "name", req.GetName())
+								http.Error(w, "resource already exists", http.StatusConflict)
 								return
 							}
 
-							gvr := schema.GroupVersionResource{Group: productv1.GroupVersion.Group, Version: productv1.GroupVersion.Version, Resource: "registrationrequests"}
-							log.Info("Creating RegistrationRequest", "gvr", gvr.String(), "name", req.GetName())
+							log.Error(err, "Failed to create RegistrationRequest", "name", req.GetName())
+							http.Error(w, "failed to create resource: "+err.Error(), http.StatusInternalServerError)
+							return
+						}
+						log.Info("RegistrationRequest created successfully", "name", req.GetName())
 
-							_, err = dynamicClient.Resource(gvr).Namespace(namespace).Create(r.Context(), &unstructured.Unstructured{Object: objMap}, metav1.CreateOptions{})
-							if err != nil {
-								if apierrors.IsAlreadyExists(err) {
-									log.Info("RegistrationRequest already exists", "name", req.GetName())
-									http.Error(w, "resource already exists", http.StatusConflict)
-									return
-								}
+						listOpts := metav1.ListOptions{FieldSelector: "metadata.name=" + req.GetName(), Watch: true}
+						log.Info("Starting watch for RegistrationRequest deletion", "name", req.GetName())
+						wch, err := dynamicClient.Resource(gvr).Namespace(namespace).Watch(r.Context(), listOpts)
+						if err != nil {
+							log.Error(err, "Failed to watch registration flow", "name", req.GetName())
+							http.Error(w, "failed to watch registration flow", http.StatusInternalServerError)
+							return
+						}
+						defer wch.Stop()
 
-								log.Error(err, "Failed to create RegistrationRequest", "name", req.GetName())
-								http.Error(w, "failed to create resource: "+err.Error(), http.StatusInternalServerError)
-								return
-							}
-							log.Info("RegistrationRequest created successfully", "name", req.GetName())
-
-							listOpts := metav1.ListOptions{FieldSelector: "metadata.name=" + req.GetName(), Watch: true}
-							log.Info("Starting watch for RegistrationRequest deletion", "name", req.GetName())
-							wch, err := dynamicClient.Resource(gvr).Namespace(namespace).Watch(r.Context(), listOpts)
-							if err != nil {
-								log.Error(err, "Failed to watch registration flow", "name", req.GetName())
-								http.Error(w, "failed to watch registration flow", http.StatusInternalServerError)
-								return
+						for ev := range wch.ResultChan() {
+							if ev.Object == nil {
+								continue
 							}
-							defer wch.Stop()
 
-							for ev := range wch.ResultChan() {
-								if ev.Object == nil {
-									continue
-								}
+							log.V(1).Info("Received watch event", "type", ev.Type, "name", req.GetName())
 
-								log.V(1).Info("Received watch event", "type", ev.Type, "name", req.GetName())
-
-								if ev.Type == watch.Deleted {
-									log.Info("RegistrationRequest completed (deleted), registration flow finished", "name", req.GetName())
-									w.WriteHeader(http.StatusCreated)
-									if fl, ok := w.(http.Flusher); ok {
-										fl.Flush()
-									}
-									return
+							if ev.Type == watch.Deleted {
+								log.Info("RegistrationRequest completed (deleted), registration flow finished", "name", req.GetName())
+								w.WriteHeader(http.StatusCreated)
+								if fl, ok := w.(http.Flusher); ok {
+									fl.Flush()
 								}
+								return
 							}
-							log.Info("Watch channel closed", "name", req.GetName())
-						},
+						}
+						log.Info("Watch channel closed", "name", req.GetName())
 					},
 				},
-				{
-					ApiResource: metav1.APIResource{
-						Name:  "users",
-						Verbs: []string{"get"},
-					},
-					RawEndpoints: map[string]http.HandlerFunc{
-						"/login": func(w http.ResponseWriter, r *http.Request) {
-							log := apiServiceLog.WithValues("handler", "login", "method", r.Method, "path", r.URL.Path)
-							log.Info("Login endpoint called")
-							w.Header().Set("Content-Type", "application/json; charset=utf-8")
-							w.WriteHeader(http.StatusOK)
-							log.Info("Login response sent", "status", http.StatusOK)
-						},
-						"/verify": func(w http.ResponseWriter, r *http.Request) {
-							log := apiServiceLog.WithValues("handler", "verify", "method", r.Method, "path", r.URL.Path)
-							log.Info("Verify endpoint called")
-							w.Header().Set("Content-Type", "application/json; charset=utf-8")
-							w.WriteHeader(http.StatusOK)
-							log.Info("Verify response sent", "status", http.StatusOK)
-						},
-					},
+			},
+			{
+				ApiResource: metav1.APIResource{
+					Name:  "users",
+					Verbs: []string{"get"},
+				},
+				RawEndpoints: map[string]http.HandlerFunc{
+					"/login":                   sas.loginUser,
+					"/login/mfa":               sas.loginMFA,
+					"/mfa/enroll":              sas.enrollMFA,
+					"/mfa/activate":            sas.activateMFA,
+					"/verify":                  sas.verifyUser,
+					"/unlock":                  sas.unlockUser,
+					"/sessions":                sas.listSessions,
+					"/sessions/revoke":         sas.revokeSession,
+					"/sessions/revoke-all":     sas.revokeAllSessions,
+					"/password":                sas.changePassword,
+					"/password-reset":          sas.requestPasswordReset,
+					"/password-reset/complete": sas.completePasswordReset,
+					"/password-hashes":         sas.passwordHashReport,
+					"/phone/verify":            sas.verifyPhone,
+					"/phone/confirm":           sas.confirmPhone,
 				},
 			},
-		}),
-	}
+			{
+				ApiResource: metav1.APIResource{
+					Name:  "invitations",
+					Verbs: []string{"get"},
+				},
+				RawEndpoints: map[string]http.HandlerFunc{
+					"/accept": sas.acceptInvitation,
+				},
+			},
+			{
+				ApiResource: metav1.APIResource{
+					Name:  "licences",
+					Verbs: []string{"create"},
+				},
+				RawEndpoints: map[string]http.HandlerFunc{
+					"/activate":   sas.activateLicence,
+					"/deactivate": sas.deactivateLicence,
+				},
+			},
+			{
+				ApiResource: metav1.APIResource{
+					Name:  "tenants",
+					Verbs: []string{"get"},
+				},
+				RawEndpoints: map[string]http.HandlerFunc{
+					"/audit":  sas.tenantAudit,
+					"/export": sas.exportTenant,
+				},
+			},
+		},
+	})
 
 	return &sas
 }
 
 type ApiService struct {
 	kaf.Server
+	addr     string
+	certFile string
+	keyFile  string
+	// requestHeader is the current front proxy trust, see frontProxyHandler.
+	requestHeader atomic.Pointer[requestHeaderAuthentication]
+
+	Client client.Client
+	// Reader reads around the cache, it sees the state written by the other replicas serving the API.
+	Reader        client.Reader
 	DynamicClient dynamic.Interface
 	Scheme        *runtime.Scheme
+	// Namespace is the namespace of the service holding its own Secrets, like the signing key.
+	Namespace     string
+	RegistryToken *RegistryTokenConfig
+	// LoginTTL is the lifetime of the ServiceAccount tokens issued on login.
+	LoginTTL        time.Duration
+	PasswordReset   PasswordResetConfig
+	LoginProtection LoginProtectionConfig
+	// SMS delivers the phone number verification codes.
+	SMS sms.SMSSender
+}
+
+// PasswordResetConfig configures the password reset links sent to users.
+type PasswordResetConfig struct {
+	// URL is the address of the page completing the reset, the token is appended as query parameter.
+	URL string
+	// TTL is the lifetime of the reset tokens.
+	TTL time.Duration
 }
 
 func (s *ApiService) Start(ctx context.Context) (err error) {
-	apiServiceLog.Info("Serving api-service server", "host", "", "port", "7443")
-	return s.Server.Start(ctx)
+	apiServiceLog.Info("Serving api-service server", "addr", s.addr, "backend", backendAddr)
+
+	authn, err := loadRequestHeaderAuthentication(ctx, s.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to load front proxy authentication: %w", err)
+	}
+	s.requestHeader.Store(authn)
+
+	go wait.UntilWithContext(ctx, s.refreshRequestHeaderAuthentication, requestHeaderRefreshInterval)
+
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.cleanupLoginThrottles(ctx); err != nil {
+			apiServiceLog.Error(err, "Login throttle cleanup failed")
+		}
+	}, cleanupInterval)
+
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.cleanupSessions(ctx); err != nil {
+			apiServiceLog.Error(err, "Session cleanup failed")
+		}
+	}, cleanupInterval)
+
+	servers := []func(context.Context) error{s.Server.Start, s.serveFrontProxy}
+	if s.RegistryToken != nil && s.RegistryToken.Addr != "" {
+		servers = append(servers, s.serveRegistryToken)
+	}
+
+	errs := make(chan error, len(servers))
+	for _, serve := range servers {
+		go func() {
+			errs <- serve(ctx)
+		}()
+	}
+
+	for range servers {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listenAndServeTLS serves until the context is done, the server has its certificates in its TLSConfig.
+func listenAndServeTLS(ctx context.Context, srv *http.Server) error {
+	errChan := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	return srv.Shutdown(context.Background())
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, the API is served by every replica
+// and shares its state through the API server.
+func (s *ApiService) NeedLeaderElection() bool {
+	return false
 }
@@ -0,0 +1,368 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// sessionLabel marks the Secrets the tokens of a session are bound to.
+	sessionLabel = "product.webshop.harikube.info/session"
+	// sessionUserLabel holds the UID of the User owning the session.
+	sessionUserLabel = "product.webshop.harikube.info/user"
+	// credentialIDExtra is the user info extra the kube-apiserver reports the token id of ServiceAccount tokens in.
+	credentialIDExtra = "authentication.kubernetes.io/credential-id"
+)
+
+var (
+	errUnauthenticated = errors.New("authentication required")
+	errSessionNotFound = errors.New("session not found")
+)
+
+// Session represents a login of a user, its tokens are valid until the session is revoked or expires.
+type Session struct {
+	ID                  string      `json:"id"`
+	LoginTimestamp      metav1.Time `json:"loginTimestamp"`
+	ExpirationTimestamp metav1.Time `json:"expirationTimestamp"`
+	ClientIP            string      `json:"clientIP,omitempty"`
+	UserAgent           string      `json:"userAgent,omitempty"`
+	Current             bool        `json:"current,omitempty"`
+}
+
+// SessionList represents the active sessions of a user.
+type SessionList struct {
+	Items []Session `json:"items"`
+}
+
+// SessionRevokeRequest represents a user ending one of the sessions.
+type SessionRevokeRequest struct {
+	ID string `json:"id"`
+}
+
+// issueSession creates the Secret of a new session and requests a token for the ServiceAccount of the user
+// bound to it, deleting the Secret invalidates the token immediately.
+func (s *ApiService) issueSession(r *http.Request, user *productv1.User) (*LoginResponse, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(raw)
+
+	now := time.Now()
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sessionSecretName(user, id),
+			Namespace: user.Namespace,
+			Labels: map[string]string{
+				sessionLabel:     "true",
+				sessionUserLabel: string(user.UID),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: productv1.GroupVersion.String(),
+					Kind:       "User",
+					Name:       user.Name,
+					UID:        user.UID,
+				},
+			},
+		},
+		Data: map[string][]byte{
+			"id":                  []byte(id),
+			"loginTimestamp":      []byte(now.UTC().Format(time.RFC3339)),
+			"expirationTimestamp": []byte(now.Add(s.LoginTTL).UTC().Format(time.RFC3339)),
+			"clientIP":            []byte(clientIP(r)),
+			"userAgent":           []byte(r.UserAgent()),
+		},
+	}
+	if err := s.Client.Create(r.Context(), &secret); err != nil {
+		return nil, err
+	}
+
+	expirationSeconds := int64(s.LoginTTL / time.Second)
+	serviceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      string(user.UID),
+			Namespace: user.Namespace,
+		},
+	}
+	tokenRequest := authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+			BoundObjectRef: &authenticationv1.BoundObjectReference{
+				APIVersion: "v1",
+				Kind:       "Secret",
+				Name:       secret.Name,
+				UID:        secret.UID,
+			},
+		},
+	}
+	if err := s.Client.SubResource("token").Create(r.Context(), &serviceAccount, &tokenRequest); err != nil {
+		_ = s.Client.Delete(r.Context(), &secret)
+		return nil, err
+	}
+
+	// The token id lets the session be recognized when its token authenticates a request.
+	if jti := tokenID(tokenRequest.Status.Token); jti != "" {
+		secret.Data["jti"] = []byte(jti)
+		if err := s.Client.Update(r.Context(), &secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LoginResponse{
+		Namespace:           user.Namespace,
+		Token:               tokenRequest.Status.Token,
+		ExpirationTimestamp: tokenRequest.Status.ExpirationTimestamp,
+	}, nil
+}
+
+func (s *ApiService) listSessions(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/sessions", "method", r.Method, "path", r.URL.Path)
+	log.Info("Sessions endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, current, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+
+	secrets, err := s.sessionSecrets(r.Context(), user)
+	if err != nil {
+		log.Error(err, "Session fetch failed")
+		http.Error(w, "failed to list sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessions := SessionList{Items: []Session{}}
+	for _, secret := range secrets {
+		session := sessionFromSecret(&secret)
+		if session.ExpirationTimestamp.Time.Before(time.Now()) {
+			continue
+		}
+
+		session.Current = current != "" && string(secret.Data["jti"]) == current
+		sessions.Items = append(sessions.Items, session)
+	}
+	sort.Slice(sessions.Items, func(i, j int) bool {
+		return sessions.Items[i].LoginTimestamp.After(sessions.Items[j].LoginTimestamp.Time)
+	})
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&sessions)
+	log.Info("Sessions listed", "count", len(sessions.Items))
+}
+
+func (s *ApiService) revokeSession(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/sessions/revoke", "method", r.Method, "path", r.URL.Path)
+	log.Info("Session revoke endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+
+	req := SessionRevokeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email, "session", req.ID)
+
+	secret := corev1.Secret{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: user.Namespace, Name: sessionSecretName(user, req.ID)}, &secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, errSessionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Error(err, "Session fetch failed")
+		http.Error(w, "failed to get session: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if secret.Labels[sessionUserLabel] != string(user.UID) {
+		http.Error(w, errSessionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := s.Client.Delete(r.Context(), &secret); err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Session revocation failed")
+		http.Error(w, "failed to revoke session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("Session has been revoked", "status", http.StatusNoContent)
+}
+
+func (s *ApiService) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/sessions/revoke-all", "method", r.Method, "path", r.URL.Path)
+	log.Info("Session revoke all endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, _, ok := s.bearerUser(w, r)
+	if !ok {
+		return
+	}
+	log = log.WithValues("email", user.Spec.Email)
+
+	if err := s.revokeSessions(r.Context(), user); err != nil {
+		log.Error(err, "Session revocation failed")
+		http.Error(w, "failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info("All sessions have been revoked", "status", http.StatusNoContent)
+}
+
+// revokeSessions invalidates all tokens of the user by deleting the Secrets of its sessions.
+func (s *ApiService) revokeSessions(ctx context.Context, user *productv1.User) error {
+	return s.Client.DeleteAllOf(ctx, &corev1.Secret{},
+		client.InNamespace(user.Namespace),
+		client.MatchingLabels{sessionLabel: "true", sessionUserLabel: string(user.UID)})
+}
+
+// cleanupSessions deletes the Secrets of expired sessions.
+func (s *ApiService) cleanupSessions(ctx context.Context) error {
+	secrets := corev1.SecretList{}
+	if err := s.Client.List(ctx, &secrets, client.MatchingLabels{sessionLabel: "true"}); err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		if sessionFromSecret(&secrets.Items[i]).ExpirationTimestamp.Time.After(time.Now()) {
+			continue
+		}
+
+		if err := s.Client.Delete(ctx, &secrets.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *ApiService) sessionSecrets(ctx context.Context, user *productv1.User) ([]corev1.Secret, error) {
+	secrets := corev1.SecretList{}
+	if err := s.Client.List(ctx, &secrets,
+		client.InNamespace(user.Namespace),
+		client.MatchingLabels{sessionLabel: "true", sessionUserLabel: string(user.UID)}); err != nil {
+		return nil, err
+	}
+
+	return secrets.Items, nil
+}
+
+// authenticateBearer returns the User whose ServiceAccount token the kube-apiserver authenticated, along with the
+// id of the token. The identity is taken from the headers of the verified front proxy, see frontProxyHandler.
+func (s *ApiService) authenticateBearer(r *http.Request) (*productv1.User, string, error) {
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("X-Remote-User"), "system:serviceaccount:"), ":")
+	if !ok || !strings.HasPrefix(r.Header.Get("X-Remote-User"), "system:serviceaccount:") {
+		return nil, "", errUnauthenticated
+	}
+
+	users := productv1.UserList{}
+	if err := s.Client.List(r.Context(), &users, client.InNamespace(namespace), client.MatchingFields{"metadata.uid": name}); err != nil {
+		return nil, "", err
+	} else if len(users.Items) == 0 || users.Items[0].DeletionTimestamp != nil || users.Items[0].Status.PasswordRef == nil {
+		return nil, "", errUnauthenticated
+	}
+
+	credentialID := ""
+	if values := remoteExtra(r)[credentialIDExtra]; len(values) != 0 {
+		credentialID = strings.TrimPrefix(values[0], "JTI=")
+	}
+
+	return &users.Items[0], credentialID, nil
+}
+
+// bearerUser authenticates the request and writes the error response when it fails.
+func (s *ApiService) bearerUser(w http.ResponseWriter, r *http.Request) (*productv1.User, string, bool) {
+	user, credentialID, err := s.authenticateBearer(r)
+	if err != nil {
+		if errors.Is(err, errUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="users"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return nil, "", false
+		}
+
+		apiServiceLog.Error(err, "Authentication failed", "path", r.URL.Path)
+		http.Error(w, "failed to authenticate: "+err.Error(), http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	return user, credentialID, true
+}
+
+func sessionFromSecret(secret *corev1.Secret) Session {
+	loginTimestamp, _ := time.Parse(time.RFC3339, string(secret.Data["loginTimestamp"]))
+	expirationTimestamp, _ := time.Parse(time.RFC3339, string(secret.Data["expirationTimestamp"]))
+
+	return Session{
+		ID:                  string(secret.Data["id"]),
+		LoginTimestamp:      metav1.NewTime(loginTimestamp),
+		ExpirationTimestamp: metav1.NewTime(expirationTimestamp),
+		ClientIP:            string(secret.Data["clientIP"]),
+		UserAgent:           string(secret.Data["userAgent"]),
+	}
+}
+
+func sessionSecretName(user *productv1.User, id string) string {
+	return string(user.UID) + "-session-" + id
+}
+
+// tokenID extracts the jti claim of a token issued by the TokenRequest API.
+func tokenID(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	claims := struct {
+		ID string `json:"jti"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+
+	return claims.ID
+}
@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+)
+
+var _ = Describe("Session endpoints", func() {
+	var (
+		service *ApiService
+		user    *productv1.User
+		proxied func(handler http.HandlerFunc) (*http.Client, string)
+	)
+
+	session := func(id, jti string, loginAt time.Time) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: user.Namespace,
+				Name:      sessionSecretName(user, id),
+				Labels:    map[string]string{sessionLabel: "true", sessionUserLabel: string(user.UID)},
+			},
+			Data: map[string][]byte{
+				"id":                  []byte(id),
+				"jti":                 []byte(jti),
+				"loginTimestamp":      []byte(loginAt.UTC().Format(time.RFC3339)),
+				"expirationTimestamp": []byte(loginAt.Add(time.Hour).UTC().Format(time.RFC3339)),
+			},
+		}
+	}
+
+	sessionExists := func(id string) bool {
+		err := service.Client.Get(context.Background(),
+			types.NamespacedName{Namespace: user.Namespace, Name: sessionSecretName(user, id)}, &corev1.Secret{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	BeforeEach(func() {
+		var password *corev1.Secret
+		user, password = newTestUser("alice", "correct horse battery staple")
+
+		now := time.Now()
+		service = newTestService(interceptor.Funcs{}, user, password,
+			session("first", "jti-first", now.Add(-30*time.Minute)),
+			session("second", "jti-second", now.Add(-10*time.Minute)),
+			session("expired", "jti-expired", now.Add(-2*time.Hour)))
+		proxyCert := trustFrontProxy(service)
+
+		proxied = func(handler http.HandlerFunc) (*http.Client, string) {
+			server := serveTLS(service, handler)
+			return clientOf(server, proxyCert), server.URL
+		}
+	})
+
+	Describe("authentication", func() {
+		It("should reject a forged ServiceAccount identity without the front proxy certificate", func() {
+			server := serveTLS(service, service.listSessions)
+			status, _ := do(clientOf(server), userRequest(http.MethodGet, server.URL, user, "jti-first", nil))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should reject identities other than ServiceAccounts", func() {
+			httpClient, url := proxied(service.listSessions)
+			status, _ := do(httpClient, remoteRequest(http.MethodGet, url, "alice@example.com"))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("should reject ServiceAccounts not belonging to a User", func() {
+			httpClient, url := proxied(service.listSessions)
+			status, _ := do(httpClient, remoteRequest(http.MethodGet, url, "system:serviceaccount:tenant-acme:default"))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	It("should list the active sessions and mark the current one", func() {
+		httpClient, url := proxied(service.listSessions)
+		status, body := do(httpClient, userRequest(http.MethodGet, url, user, "jti-first", nil))
+		Expect(status).To(Equal(http.StatusOK))
+
+		sessions := SessionList{}
+		Expect(json.Unmarshal(body, &sessions)).To(Succeed())
+		Expect(sessions.Items).To(HaveLen(2))
+		Expect(sessions.Items[0].ID).To(Equal("second"))
+		Expect(sessions.Items[0].Current).To(BeFalse())
+		Expect(sessions.Items[1].ID).To(Equal("first"))
+		Expect(sessions.Items[1].Current).To(BeTrue())
+	})
+
+	It("should revoke a session", func() {
+		httpClient, url := proxied(service.revokeSession)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "jti-first", SessionRevokeRequest{ID: "second"}))
+		Expect(status).To(Equal(http.StatusNoContent))
+		Expect(sessionExists("second")).To(BeFalse())
+		Expect(sessionExists("first")).To(BeTrue())
+
+		status, _ = do(httpClient, userRequest(http.MethodPost, url, user, "jti-first", SessionRevokeRequest{ID: "unknown"}))
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should revoke all sessions", func() {
+		httpClient, url := proxied(service.revokeAllSessions)
+		status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "jti-first", nil))
+		Expect(status).To(Equal(http.StatusNoContent))
+
+		secrets := corev1.SecretList{}
+		Expect(service.Client.List(context.Background(), &secrets, client.MatchingLabels{sessionLabel: "true"})).To(Succeed())
+		Expect(secrets.Items).To(BeEmpty())
+	})
+
+	Describe("password change", func() {
+		It("should reject a wrong current password", func() {
+			httpClient, url := proxied(service.changePassword)
+			status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", PasswordChangeRequest{
+				CurrentPassword: "wrong horse battery staple",
+				NewPassword:     "N3w-Passphrase",
+			}))
+			Expect(status).To(Equal(http.StatusForbidden))
+		})
+
+		It("should throttle and lock after repeated wrong current passwords", func() {
+			service.LoginProtection.BaseDelay = 0
+			service.LoginProtection.MaxDelay = 0
+			service.LoginProtection.MaxAttempts = 3
+
+			httpClient, url := proxied(service.changePassword)
+			change := func(currentPassword string) int {
+				status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", PasswordChangeRequest{
+					CurrentPassword: currentPassword,
+					NewPassword:     "N3w-Passphrase",
+				}))
+				return status
+			}
+
+			for range 3 {
+				Expect(change("wrong horse battery staple")).To(Equal(http.StatusForbidden))
+			}
+			Expect(change("wrong horse battery staple")).To(Equal(http.StatusTooManyRequests))
+			Expect(change("correct horse battery staple")).To(Equal(http.StatusTooManyRequests))
+
+			current := productv1.User{}
+			Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(user), &current)).To(Succeed())
+			Expect(current.Status.LockedUntilTimestamp.IsZero()).To(BeFalse())
+		})
+
+		It("should replace the password hash", func() {
+			httpClient, url := proxied(service.changePassword)
+			status, _ := do(httpClient, userRequest(http.MethodPost, url, user, "", PasswordChangeRequest{
+				CurrentPassword: "correct horse battery staple",
+				NewPassword:     "N3w-Passphrase",
+			}))
+			Expect(status).To(Equal(http.StatusNoContent))
+
+			secret := corev1.Secret{}
+			Expect(service.Client.Get(context.Background(),
+				types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret)).To(Succeed())
+			Expect(passwords.Compare("N3w-Passphrase", string(secret.Data["hash"]))).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,243 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"slices"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+)
+
+// These tests use Ginkgo (BDD-style Go testing framework). Refer to
+// http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
+
+func TestAPIService(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "API Service Suite")
+}
+
+var _ = BeforeSuite(func() {
+	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+})
+
+// newTestService returns an ApiService backed by a fake client holding the objects.
+func newTestService(funcs interceptor.Funcs, objects ...client.Object) *ApiService {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(productv1.AddToScheme(scheme)).To(Succeed())
+
+	kubeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(&productv1.User{}, &productv1.Tenant{}, &productv1.Licence{}, &productv1.RegistryToken{},
+			&productv1.Invitation{}).
+		WithInterceptorFuncs(funcs).
+		WithIndex(&productv1.User{}, "spec.email", func(obj client.Object) []string {
+			return []string{obj.(*productv1.User).Spec.Email}
+		}).
+		WithIndex(&productv1.User{}, "metadata.uid", func(obj client.Object) []string {
+			return []string{string(obj.GetUID())}
+		}).
+		Build()
+
+	return New(kubeClient, nil, scheme, ":0", GinkgoT().TempDir(), "tls.crt", "tls.key", "webshop-system")
+}
+
+// allowUsers answers the SubjectAccessReviews of the service, only the users are allowed.
+func allowUsers(users ...string) interceptor.Funcs {
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+				review.Status.Allowed = slices.Contains(users, review.Spec.User)
+				return nil
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+}
+
+// newTestUser returns a User of the tenant-acme namespace with the email <name>@example.com, and its password Secret.
+func newTestUser(name, password string) (*productv1.User, *corev1.Secret) {
+	hash, err := passwords.Hash(password)
+	Expect(err).NotTo(HaveOccurred())
+
+	user := &productv1.User{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name, UID: types.UID(name + "-uid")},
+		Spec:       productv1.UserSpec{Email: name + "@example.com"},
+	}
+	user.Status.PasswordRef = &corev1.LocalObjectReference{Name: name + "-password"}
+
+	return user, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name + "-password"},
+		Data:       map[string][]byte{"hash": []byte(hash)},
+	}
+}
+
+// userRequest returns a request carrying the identity the front proxy sets for the login token of the user.
+func userRequest(method, url string, user *productv1.User, credentialID string, body any) *http.Request {
+	req := remoteRequest(method, url, "system:serviceaccount:"+user.Namespace+":"+string(user.UID),
+		"system:serviceaccounts", "system:authenticated")
+	if credentialID != "" {
+		req.Header.Set("X-Remote-Extra-"+neturl.PathEscape(credentialIDExtra), "JTI="+credentialID)
+	}
+	if body != nil {
+		setJSONBody(req, body)
+	}
+
+	return req
+}
+
+// anonymousRequest returns a request of a client that is not signed in, with the body encoded as JSON.
+func anonymousRequest(method, url string, body any) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	Expect(err).NotTo(HaveOccurred())
+	if body != nil {
+		setJSONBody(req, body)
+	}
+
+	return req
+}
+
+func setJSONBody(req *http.Request, body any) {
+	raw, err := json.Marshal(body)
+	Expect(err).NotTo(HaveOccurred())
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+}
+
+// trustFrontProxy makes the service trust a new front proxy CA and returns the client certificate the
+// kube-apiserver presents.
+func trustFrontProxy(service *ApiService) tls.Certificate {
+	proxyCA := newTestCA("front-proxy-ca")
+	service.requestHeader.Store(&requestHeaderAuthentication{
+		ClientCAs:    proxyCA.pool(),
+		AllowedNames: []string{"front-proxy-client"},
+	})
+
+	return proxyCA.issue("front-proxy-client")
+}
+
+// remoteRequest returns a request carrying the identity headers the front proxy sets.
+func remoteRequest(method, url, user string, groups ...string) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	Expect(err).NotTo(HaveOccurred())
+	req.Header.Set("X-Remote-User", user)
+	for _, group := range groups {
+		req.Header.Add("X-Remote-Group", group)
+	}
+
+	return req
+}
+
+// do sends the request and returns the response status and body.
+func do(httpClient *http.Client, req *http.Request) (int, []byte) {
+	resp, err := httpClient.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	body, err := io.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp.Body.Close()).To(Succeed())
+
+	return resp.StatusCode, body
+}
+
+// testCA is a certificate authority issuing client certificates in tests.
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(commonName string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	certificate, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCA{certificate: certificate, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.certificate)
+	return pool
+}
+
+// issue returns a client certificate with the common name signed by the CA.
+func (ca *testCA) issue(commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.certificate, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveTLS serves the handler behind the front proxy check over TLS, requesting client certificates like the
+// exposed listener does.
+func serveTLS(service *ApiService, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewUnstartedServer(service.frontProxyHandler(handler))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	DeferCleanup(server.Close)
+
+	return server
+}
+
+// clientOf returns a client of the server presenting the certificates.
+func clientOf(server *httptest.Server, certificates ...tls.Certificate) *http.Client {
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = certificates
+
+	return &http.Client{Transport: transport}
+}
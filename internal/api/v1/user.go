@@ -0,0 +1,272 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+
+var (
+	errUserNotFound = errors.New("user not found")
+)
+
+// LoginRequest represents the credentials a user signs in with.
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// LoginResponse represents the credential issued for the ServiceAccount of the signed in user,
+// or the token of the second login step when the user enabled multi-factor authentication.
+type LoginResponse struct {
+	Namespace           string      `json:"namespace,omitempty"`
+	Token               string      `json:"token,omitempty"`
+	ExpirationTimestamp metav1.Time `json:"expirationTimestamp,omitempty"`
+	MFARequired         bool        `json:"mfaRequired,omitempty"`
+	MFAToken            string      `json:"mfaToken,omitempty"`
+}
+
+func (s *ApiService) loginUser(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/login", "method", r.Method, "path", r.URL.Path)
+	log.Info("Login endpoint called")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := LoginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error(err, "Failed to decode JSON request")
+		http.Error(w, "failed to decode json request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" || req.Password == "" {
+		http.Error(w, "email and password are required", http.StatusBadRequest)
+		return
+	}
+	ip := clientIP(r)
+	log = log.WithValues("email", req.Email, "ip", ip)
+
+	user, err := s.findUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		log.Error(err, "User fetch failed")
+		http.Error(w, "failed to list users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if retryAfter, err := s.loginRetryAfter(r.Context(), ip, user); err != nil {
+		log.Error(err, "Login throttle check failed")
+		http.Error(w, "failed to check login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if retryAfter > 0 {
+		log.Info("Login throttled", "retryAfter", retryAfter)
+		writeTooManyAttempts(w, retryAfter)
+		return
+	}
+
+	if user == nil {
+		err = errInvalidCredentials
+	} else {
+		err = s.checkPassword(r.Context(), user, req.Password)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			log.Info("Login failed")
+			if err := s.recordLoginFailure(r.Context(), ip, user); err != nil {
+				log.Error(err, "Login failure recording failed")
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		log.Error(err, "Login failed")
+		http.Error(w, "failed to authenticate: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The login knows the password, the only chance to move a weak hash to the current parameters.
+	if err := s.rehashPassword(r.Context(), user, req.Password); err != nil {
+		log.Error(err, "Password rehash failed")
+	}
+
+	if user.Status.MFAEnabled {
+		key, err := signing.LoadKey(r.Context(), s.Client, s.Namespace)
+		if err != nil {
+			log.Error(err, "Signing key fetch failed")
+			http.Error(w, "failed to load signing key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mfaToken, err := signing.Sign(key, signing.PurposeMFA, string(user.UID), time.Now().Add(mfaTokenTTL))
+		if err != nil {
+			log.Error(err, "MFA token signing failed")
+			http.Error(w, "failed to sign mfa token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(&LoginResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		log.Info("Login requires second factor", "status", http.StatusOK)
+		return
+	}
+
+	if err := s.resetLoginFailures(r.Context(), user); err != nil {
+		log.Error(err, "Login failure reset failed")
+		http.Error(w, "failed to reset login attempts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := s.issueSession(r, user)
+	if err != nil {
+		log.Error(err, "TokenRequest creation failed", "serviceAccountName", string(user.UID))
+		http.Error(w, "failed to issue token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+	s.recordUserEvent(r, user, audit.Login, "Login succeeded")
+	log.Info("Login succeeded", "status", http.StatusOK)
+}
+
+// VerifyResponse represents the state of the User after email verification.
+type VerifyResponse struct {
+	Email string `json:"email"`
+	Phase string `json:"phase"`
+}
+
+func (s *ApiService) verifyUser(w http.ResponseWriter, r *http.Request) {
+	log := apiServiceLog.WithValues("handler", "users/verify", "method", r.Method, "path", r.URL.Path)
+	log.Info("Verify endpoint called")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	key, err := signing.LoadKey(r.Context(), s.Client, s.Namespace)
+	if err != nil {
+		log.Error(err, "Signing key fetch failed")
+		http.Error(w, "failed to load signing key: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	uid, err := signing.Verify(key, signing.PurposeVerify, token)
+	if err != nil {
+		log.Info("Verification token rejected", "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log = log.WithValues("uid", uid)
+
+	user := productv1.User{}
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		users := productv1.UserList{}
+		if err := s.Client.List(r.Context(), &users, client.MatchingFields{"metadata.uid": uid}); err != nil {
+			return err
+		} else if len(users.Items) == 0 {
+			return errUserNotFound
+		}
+		user = users.Items[0]
+
+		if user.Status.Phase == "Validated" {
+			return nil
+		}
+
+		user.Status.Phase = "Validated"
+		return s.Client.Status().Update(r.Context(), &user)
+	}); err != nil {
+		if errors.Is(err, errUserNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		log.Error(err, "User verification failed")
+		http.Error(w, "failed to verify user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(&VerifyResponse{
+		Email: user.Spec.Email,
+		Phase: user.Status.Phase,
+	})
+	log.Info("User verified", "status", http.StatusOK)
+}
+
+// authenticateUser resolves the User by email and checks the password against the argon2id hash of the password Secret.
+func (s *ApiService) authenticateUser(r *http.Request, email, password string) (*productv1.User, error) {
+	user, err := s.findUserByEmail(r.Context(), email)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, errInvalidCredentials
+	}
+
+	if err := s.checkPassword(r.Context(), user, password); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// findUserByEmail returns the User registered with the email address, or nil if there is none.
+func (s *ApiService) findUserByEmail(ctx context.Context, email string) (*productv1.User, error) {
+	users := productv1.UserList{}
+	if err := s.Client.List(ctx, &users, client.MatchingFields{"spec.email": email}); err != nil {
+		return nil, err
+	} else if len(users.Items) == 0 {
+		return nil, nil
+	}
+
+	return &users.Items[0], nil
+}
+
+// checkPassword checks the password against the argon2id hash of the password Secret of the user.
+func (s *ApiService) checkPassword(ctx context.Context, user *productv1.User, password string) error {
+	if user.DeletionTimestamp != nil || user.Status.PasswordRef == nil {
+		return errInvalidCredentials
+	}
+
+	secret := corev1.Secret{}
+	if err := s.Client.Get(ctx, types.NamespacedName{Namespace: user.Namespace, Name: user.Status.PasswordRef.Name}, &secret); err != nil {
+		return err
+	}
+
+	match, err := passwords.Compare(password, string(secret.Data["hash"]))
+	if err != nil {
+		return err
+	} else if !match {
+		return errInvalidCredentials
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	passwords "github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+)
+
+var _ = Describe("Login endpoint", func() {
+	var (
+		service    *ApiService
+		user       *productv1.User
+		password   *corev1.Secret
+		httpClient *http.Client
+		serverURL  string
+	)
+
+	login := func(email, password string) (int, []byte) {
+		return do(httpClient, anonymousRequest(http.MethodPost, serverURL, LoginRequest{Email: email, Password: password}))
+	}
+
+	stored := func() *productv1.User {
+		current := productv1.User{}
+		Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(user), &current)).To(Succeed())
+		return &current
+	}
+
+	BeforeEach(func() {
+		user, password = newTestUser("alice", "correct horse battery staple")
+	})
+
+	JustBeforeEach(func() {
+		service = newTestService(interceptor.Funcs{}, user, password, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: user.Namespace, Name: string(user.UID)},
+		})
+
+		server := serveTLS(service, service.loginUser)
+		httpClient = clientOf(server)
+		serverURL = server.URL
+	})
+
+	It("should only accept POST", func() {
+		status, _ := do(httpClient, anonymousRequest(http.MethodGet, serverURL, nil))
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("should require the email and the password", func() {
+		status, _ := login("alice@example.com", "")
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject unknown email addresses", func() {
+		status, _ := login("mallory@example.com", "correct horse battery staple")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should reject a wrong password and count the failure", func() {
+		status, _ := login("alice@example.com", "wrong horse battery staple")
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		Expect(stored().Status.FailedLoginAttempts).To(BeEquivalentTo(1))
+	})
+
+	It("should issue a token bound to a new session", func() {
+		status, body := login("alice@example.com", "correct horse battery staple")
+		Expect(status).To(Equal(http.StatusOK))
+
+		response := LoginResponse{}
+		Expect(json.Unmarshal(body, &response)).To(Succeed())
+		Expect(response.Namespace).To(Equal("tenant-acme"))
+		Expect(response.Token).To(Equal("fake-token"))
+		Expect(response.MFARequired).To(BeFalse())
+
+		secrets := corev1.SecretList{}
+		Expect(service.Client.List(context.Background(), &secrets,
+			client.MatchingLabels{sessionLabel: "true", sessionUserLabel: string(user.UID)})).To(Succeed())
+		Expect(secrets.Items).To(HaveLen(1))
+	})
+
+	Context("when the user failed to log in before", func() {
+		BeforeEach(func() {
+			user.Status.FailedLoginAttempts = 2
+			user.Status.LastFailedLoginTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		})
+
+		It("should reset the failures after a successful login", func() {
+			status, _ := login("alice@example.com", "correct horse battery staple")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(stored().Status.FailedLoginAttempts).To(BeZero())
+		})
+	})
+
+	Context("when the password was hashed with weaker parameters", func() {
+		BeforeEach(func() {
+			hash, err := argon2id.CreateHash("correct horse battery staple", &argon2id.Params{
+				Memory: 16 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			password.Data["hash"] = []byte(hash)
+		})
+
+		It("should rehash the password with the default parameters", func() {
+			status, _ := login("alice@example.com", "correct horse battery staple")
+			Expect(status).To(Equal(http.StatusOK))
+
+			secret := corev1.Secret{}
+			Expect(service.Client.Get(context.Background(), client.ObjectKeyFromObject(password), &secret)).To(Succeed())
+			Expect(passwords.Params(string(secret.Data["hash"]))).To(Equal(passwords.DefaultParams))
+			Expect(passwords.Compare("correct horse battery staple", string(secret.Data["hash"]))).To(BeTrue())
+		})
+	})
+
+	Context("when the user enabled multi-factor authentication", func() {
+		BeforeEach(func() {
+			user.Status.MFAEnabled = true
+		})
+
+		It("should ask for the second factor instead of issuing a token", func() {
+			status, body := login("alice@example.com", "correct horse battery staple")
+			Expect(status).To(Equal(http.StatusOK))
+
+			response := LoginResponse{}
+			Expect(json.Unmarshal(body, &response)).To(Succeed())
+			Expect(response.Token).To(BeEmpty())
+			Expect(response.MFARequired).To(BeTrue())
+
+			key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(signing.Verify(key, signing.PurposeMFA, response.MFAToken)).To(Equal(string(user.UID)))
+		})
+	})
+})
+
+var _ = Describe("Verify endpoint", func() {
+	var (
+		service    *ApiService
+		user       *productv1.User
+		httpClient *http.Client
+		serverURL  string
+	)
+
+	verify := func(token string) (int, []byte) {
+		return do(httpClient, anonymousRequest(http.MethodGet, serverURL+"?token="+url.QueryEscape(token), nil))
+	}
+
+	sign := func(purpose, subject string) string {
+		key, err := signing.LoadKey(context.Background(), service.Client, service.Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		token, err := signing.Sign(key, purpose, subject, time.Now().Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		return token
+	}
+
+	BeforeEach(func() {
+		user, _ = newTestUser("alice", "correct horse battery staple")
+		user.Status.Phase = "Pending"
+		service = newTestService(interceptor.Funcs{}, user)
+
+		server := serveTLS(service, service.verifyUser)
+		httpClient = clientOf(server)
+		serverURL = server.URL
+	})
+
+	It("should require a token", func() {
+		status, _ := do(httpClient, anonymousRequest(http.MethodGet, serverURL, nil))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject tokens signed for another purpose", func() {
+		status, _ := verify(sign(signing.PurposeMFA, string(user.UID)))
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should reject tokens of unknown users", func() {
+		status, _ := verify(sign(signing.PurposeVerify, "unknown-uid"))
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("should validate the user", func() {
+		status, body := verify(sign(signing.PurposeVerify, string(user.UID)))
+		Expect(status).To(Equal(http.StatusOK))
+
+		response := VerifyResponse{}
+		Expect(json.Unmarshal(body, &response)).To(Succeed())
+		Expect(response).To(Equal(VerifyResponse{Email: "alice@example.com", Phase: "Validated"}))
+
+		current := productv1.User{}
+		Expect(service.Client.Get(context.Background(), types.NamespacedName{
+			Namespace: user.Namespace,
+			Name:      user.Name,
+		}, &current)).To(Succeed())
+		Expect(current.Status.Phase).To(Equal("Validated"))
+	})
+})
@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the business events of tenants as immutable AuditEvents in the tenant namespace.
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+// Actions of the recorded business events.
+const (
+	Login                = "Login"
+	PasswordChange       = "PasswordChange"
+	PasswordReset        = "PasswordReset"
+	OrderPlaced          = "OrderPlaced"
+	PaymentSucceeded     = "PaymentSucceeded"
+	PaymentFailed        = "PaymentFailed"
+	LicenceIssued        = "LicenceIssued"
+	RegistryTokenCreated = "RegistryTokenCreated"
+)
+
+// Controller returns the actor of the actions the controller performs on its own.
+func Controller(name string) productv1.AuditActor {
+	return productv1.AuditActor{Username: "system:webshop:" + name}
+}
+
+// UserActor returns the actor of the actions the User performs through its ServiceAccount.
+func UserActor(user *productv1.User) productv1.AuditActor {
+	return productv1.AuditActor{
+		Username: "system:serviceaccount:" + user.Namespace + ":" + string(user.UID),
+		Email:    user.Spec.Email,
+	}
+}
+
+// Ref references an object of the product API group.
+func Ref(kind string, obj client.Object) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion: productv1.GroupVersion.String(),
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
+}
+
+// Record creates an AuditEvent of the action in the namespace, stamped with the current time unless the action
+// carries its own. Events of a known name are recorded once, the controllers name them after the object so a
+// repeated reconciliation does not record them again. Events without a name get a generated one.
+func Record(ctx context.Context, c client.Writer, namespace, name string, spec productv1.AuditEventSpec) error {
+	event := productv1.AuditEvent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: spec,
+	}
+	if name == "" {
+		event.GenerateName = strings.ToLower(spec.Action) + "-"
+	}
+	if event.Spec.Timestamp.IsZero() {
+		event.Spec.Timestamp = metav1.NewTime(time.Now())
+	}
+
+	if err := c.Create(ctx, &event); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+// InvitationReconciler reconciles a Invitation object
+type InvitationReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	Namespace string
+	// AcceptURL is the address of the page accepting invitations, the token is appended as query parameter.
+	AcceptURL string
+	// TTL is the time invitations can be accepted in.
+	TTL time.Duration
+}
+
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations;emails;emailtemplates;tenants,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations/finalizers,verbs=update
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// TODO(user): Modify the Reconcile function to compare the state specified by
+// the Invitation object against the actual cluster state, and then
+// perform operations to make the cluster state reflect the state specified by
+// the user.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
+func (r *InvitationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "invitation", "name", req.NamespacedName)
+
+	invitation := productv1.Invitation{}
+	if err := r.Get(ctx, req.NamespacedName, &invitation); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Invitation fetch failed")
+		return ctrl.Result{}, err
+	}
+	invitation.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("Invitation"))
+
+	if invitation.DeletionTimestamp != nil || !invitation.DeletionTimestamp.IsZero() {
+		logger.Info("Invitation deleted")
+
+		return ctrl.Result{}, nil
+	}
+
+	patchedInvitation := invitation.DeepCopy()
+	patchedInvitation.Status.LastGeneration = invitation.Generation
+
+	switch invitation.Status.Phase {
+	case "":
+		logger.Info("Invitation created")
+
+		expiresAt := invitation.CreationTimestamp.Add(r.TTL)
+		email, err := r.sendInvitation(ctx, &invitation, expiresAt)
+		if err != nil {
+			logger.Error(err, "Invitation email creation failed")
+			return ctrl.Result{}, err
+		}
+
+		patchedInvitation.Status.Phase = "Pending"
+		patchedInvitation.Status.ExpireTimestamp = metav1.NewTime(expiresAt)
+		if email != nil {
+			patchedInvitation.Status.EmailRef = &corev1.LocalObjectReference{
+				Name: email.Name,
+			}
+		}
+	case "Pending":
+		if time.Now().Before(invitation.Status.ExpireTimestamp.Time) {
+			return ctrl.Result{RequeueAfter: time.Until(invitation.Status.ExpireTimestamp.Time)}, nil
+		}
+
+		logger.Info("Invitation expired")
+		patchedInvitation.Status.Phase = "Expired"
+	default:
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Status().Patch(ctx, patchedInvitation, client.MergeFrom(&invitation)); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Invitation status update failed")
+		return ctrl.Result{}, err
+	}
+
+	if patchedInvitation.Status.Phase == "Pending" {
+		return ctrl.Result{RequeueAfter: time.Until(patchedInvitation.Status.ExpireTimestamp.Time)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// sendInvitation creates the Email carrying the accept link of the invitation.
+// It returns nil without error if the EmailTemplate is not installed.
+func (r *InvitationReconciler) sendInvitation(ctx context.Context, invitation *productv1.Invitation, expiresAt time.Time) (*productv1.Email, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "invitation", "name", invitation.Name, "namespace", invitation.Namespace)
+
+	emailTemplate := productv1.EmailTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      "example-webshop-service-invitation",
+		Namespace: r.Namespace,
+	}, &emailTemplate); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("EmailTemplate not found, skipping invitation", "emailTemplateName", "example-webshop-service-invitation")
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	renderer, err := template.New("invitation_template").Parse(emailTemplate.Spec.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	invitationMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := tenancy.TenantOf(ctx, r.Client, invitation.Namespace)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		tenant = &productv1.Tenant{}
+	}
+	invitationMap["companyName"] = tenant.Spec.CompanyName
+
+	key, err := signing.LoadKey(ctx, r.Client, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	acceptToken, err := signing.Sign(key, signing.PurposeInvitation, invitation.Namespace+"/"+invitation.Name+"/"+string(invitation.UID), expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	invitationMap["acceptURL"] = r.AcceptURL + "?token=" + url.QueryEscape(acceptToken)
+
+	var renderedBody bytes.Buffer
+	if err := renderer.Execute(&renderedBody, invitationMap); err != nil {
+		return nil, err
+	}
+
+	email := productv1.Email{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      invitation.Name + "-invitation",
+			Namespace: invitation.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: invitation.APIVersion,
+					Kind:       invitation.Kind,
+					Name:       invitation.Name,
+					UID:        invitation.UID,
+				},
+			},
+		},
+		Spec: productv1.EmailSpec{
+			ToAddress:   invitation.Spec.Email,
+			FromName:    emailTemplate.Spec.FromName,
+			FromAddress: emailTemplate.Spec.FromAddress,
+			Subject:     emailTemplate.Spec.Subject,
+			Body:        renderedBody.String(),
+		},
+	}
+	if err := r.Create(ctx, &email); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+	} else {
+		logger.Info("Email has been created", "emailName", email.Name)
+	}
+
+	return &email, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InvitationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&productv1.Invitation{}).
+		Named("invitation").
+		Complete(r)
+}
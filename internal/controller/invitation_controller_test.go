@@ -0,0 +1,93 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Invitation Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "test-resource"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default", // TODO(user):Modify as needed
+		}
+		invitation := &productv1.Invitation{}
+
+		BeforeEach(func() {
+			By("creating the custom resource for the Kind Invitation")
+			err := k8sClient.Get(ctx, typeNamespacedName, invitation)
+			if err != nil && errors.IsNotFound(err) {
+				resource := &productv1.Invitation{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      resourceName,
+						Namespace: "default",
+					},
+					Spec: productv1.InvitationSpec{
+						Email: "colleague@harikube.info",
+						Role:  "Member",
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			// TODO(user): Cleanup logic after each test, like removing the resource instance.
+			resource := &productv1.Invitation{}
+			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Cleanup the specific resource instance Invitation")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+		It("should wait for the acceptance until the invitation expires", func() {
+			By("Reconciling the created resource")
+			controllerReconciler := &InvitationReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				TTL:    time.Hour,
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Checking the invitation waits for acceptance")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, invitation)).To(Succeed())
+			Expect(invitation.Status.Phase).To(Equal("Pending"))
+			Expect(invitation.Status.ExpireTimestamp.Time).To(BeTemporally("~", invitation.CreationTimestamp.Add(time.Hour), time.Second))
+		})
+	})
+})
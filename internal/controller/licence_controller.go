@@ -18,13 +18,17 @@ package controller
 
 import (
 	"context"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/audit"
 )
 
 // LicenceReconciler reconciles a Licence object
@@ -36,20 +40,44 @@ type LicenceReconciler struct {
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences/finalizers,verbs=update
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=auditevents,verbs=get;list;watch;create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Licence object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It records the issued Licence in the audit trail.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *LicenceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = logf.FromContext(ctx)
+	logger := logf.FromContext(ctx).WithValues("controller", "licence", "name", req.NamespacedName)
 
-	// TODO(user): your logic here
+	licence := productv1.Licence{}
+	if err := r.Get(ctx, req.NamespacedName, &licence); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Licence fetch failed")
+		return ctrl.Result{}, err
+	}
+
+	if licence.DeletionTimestamp != nil || !licence.DeletionTimestamp.IsZero() {
+		logger.Info("Licence deleted")
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := audit.Record(ctx, r.Client, licence.Namespace, "licence-issued-"+string(licence.UID), productv1.AuditEventSpec{
+		Action:     audit.LicenceIssued,
+		Actor:      audit.Controller("licence"),
+		Timestamp:  licence.CreationTimestamp,
+		ObjectRefs: []corev1.ObjectReference{audit.Ref("Licence", &licence)},
+		Message: "Licence " + licence.Spec.DisplayName + " has been issued until " +
+			licence.Spec.ExpireTimestamp.UTC().Format(time.RFC3339),
+	}); err != nil {
+		logger.Error(err, "AuditEvent creation failed")
+		return ctrl.Result{}, err
+	}
 
 	return ctrl.Result{}, nil
 }
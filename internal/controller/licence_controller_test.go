@@ -69,7 +69,7 @@ var _ = Describe("Licence Controller", func() {
 			By("Cleanup the specific resource instance Licence")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 		})
-		It("should successfully reconcile the resource", func() {
+		It("should record the issued licence in the audit trail", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &LicenceReconciler{
 				Client: k8sClient,
@@ -80,8 +80,17 @@ var _ = Describe("Licence Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the issued licence is recorded in the audit trail")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, licence)).To(Succeed())
+			event := &productv1.AuditEvent{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      "licence-issued-" + string(licence.UID),
+				Namespace: "default",
+			}, event)).To(Succeed())
+			Expect(event.Spec.Action).To(Equal("LicenceIssued"))
+			Expect(event.Spec.ObjectRefs).To(HaveLen(1))
+			Expect(event.Spec.ObjectRefs[0].Name).To(Equal(resourceName))
 		})
 	})
 })
@@ -18,15 +18,30 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
+// licenceLifetime is how long a Licence issued for a paid Order is valid.
+const licenceLifetime = 365 * 24 * time.Hour
+
 // OrderReconciler reconciles a Order object
 type OrderReconciler struct {
 	client.Client
@@ -36,28 +51,174 @@ type OrderReconciler struct {
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=orders,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=orders/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=orders/finalizers,verbs=update
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=payments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=auditevents,verbs=get;list;watch;create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Order object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It records the placed Order in the audit trail. Once the Order has been paid it issues the Licences of
+// the ordered products and upgrades the plan of the tenant for plan products.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *OrderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = logf.FromContext(ctx)
+	logger := logf.FromContext(ctx).WithValues("controller", "order", "name", req.NamespacedName)
+
+	order := productv1.Order{}
+	if err := r.Get(ctx, req.NamespacedName, &order); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Order fetch failed")
+		return ctrl.Result{}, err
+	}
+
+	if order.DeletionTimestamp != nil || !order.DeletionTimestamp.IsZero() {
+		logger.Info("Order deleted")
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := audit.Record(ctx, r.Client, order.Namespace, "order-placed-"+string(order.UID), productv1.AuditEventSpec{
+		Action:     audit.OrderPlaced,
+		Actor:      productv1.AuditActor{Email: order.Spec.User.Email},
+		Timestamp:  order.Spec.OrderTimestamp,
+		ObjectRefs: []corev1.ObjectReference{audit.Ref("Order", &order)},
+		Message:    fmt.Sprintf("Order of %d products has been placed", len(order.Spec.Products)),
+	}); err != nil {
+		logger.Error(err, "AuditEvent creation failed")
+		return ctrl.Result{}, err
+	}
+
+	if order.Status.PaymentRef == nil {
+		return ctrl.Result{}, nil
+	}
+
+	payment := productv1.Payment{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      order.Status.PaymentRef.Name,
+		Namespace: order.Namespace,
+	}, &payment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Payment fetch failed", "paymentName", order.Status.PaymentRef.Name)
+		return ctrl.Result{}, err
+	}
+	if payment.Status.PaymentTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.issueLicences(ctx, &order, &payment); err != nil {
+		logger.Error(err, "Licence issuing failed")
+		return ctrl.Result{}, err
+	}
+
+	upgrade := ""
+	for _, ordered := range order.Spec.Products {
+		if p := ordered.Product.UpgradePlan; p != "" && (upgrade == "" || plan.Rank(p) > plan.Rank(upgrade)) {
+			upgrade = p
+		}
+	}
+	if upgrade == "" {
+		return ctrl.Result{}, nil
+	}
+
+	tenant, err := tenancy.TenantOf(ctx, r.Client, order.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
 
-	// TODO(user): your logic here
+		logger.Error(err, "Tenant fetch failed", "namespaceName", order.Namespace)
+		return ctrl.Result{}, err
+	}
+	if plan.Rank(upgrade) <= plan.Rank(tenant.Spec.Plan) {
+		return ctrl.Result{}, nil
+	}
+
+	patchedTenant := tenant.DeepCopy()
+	patchedTenant.Spec.Plan = upgrade
+	if err := r.Patch(ctx, patchedTenant, client.MergeFrom(tenant)); err != nil {
+		logger.Error(err, "Tenant plan upgrade failed", "tenantName", tenant.Name)
+		return ctrl.Result{}, err
+	}
+	logger.Info("Tenant plan has been upgraded", "tenantName", tenant.Name, "plan", upgrade)
 
 	return ctrl.Result{}, nil
 }
 
+// issueLicences issues a Licence for every ordered product which is not a plan, the ordered quantity
+// becomes the seats of the Licence.
+func (r *OrderReconciler) issueLicences(ctx context.Context, order *productv1.Order, payment *productv1.Payment) error {
+	for i, ordered := range order.Spec.Products {
+		if ordered.Product.UpgradePlan != "" {
+			continue
+		}
+
+		seats := ordered.Quantity
+		if seats < 1 {
+			seats = 1
+		}
+
+		licence := productv1.Licence{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", order.Name, i),
+				Namespace: order.Namespace,
+			},
+			Spec: productv1.LicenceSpec{
+				DisplayName:     ordered.Product.DisplayName,
+				Description:     ordered.Product.Description,
+				Addons:          append(slices.Clone(ordered.Product.Addons), ordered.Addons...),
+				Seats:           seats,
+				ExpireTimestamp: metav1.NewTime(payment.Status.PaymentTimestamp.Add(licenceLifetime)),
+			},
+		}
+		if err := r.Create(ctx, &licence); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+
+			return err
+		}
+		logf.FromContext(ctx).Info("Licence has been issued", "licenceName", licence.Name, "seats", seats)
+	}
+
+	return nil
+}
+
+// ordersOfPayment maps a Payment to the Orders it pays.
+func (r *OrderReconciler) ordersOfPayment(ctx context.Context, obj client.Object) []reconcile.Request {
+	orders := productv1.OrderList{}
+	if err := r.List(ctx, &orders, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "Order list failed", "paymentName", obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, order := range orders.Items {
+		if order.Status.PaymentRef != nil && order.Status.PaymentRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      order.Name,
+					Namespace: order.Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *OrderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&productv1.Order{}).
+		Watches(&productv1.Payment{}, handler.EnqueueRequestsFromMapFunc(r.ordersOfPayment)).
 		Named("order").
 		Complete(r)
 }
@@ -18,9 +18,11 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -28,6 +30,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
 var _ = Describe("Order Controller", func() {
@@ -96,5 +99,117 @@ var _ = Describe("Order Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+		It("should issue a licence with the ordered quantity as seats once the order is paid", func() {
+			By("Paying the order")
+			payment := &productv1.Payment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: productv1.PaymentSpec{
+					Price: 200,
+				},
+			}
+			Expect(k8sClient.Create(ctx, payment)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, payment)).To(Succeed())
+			}()
+			payment.Status.PaymentTimestamp = metav1.Now()
+			Expect(k8sClient.Status().Update(ctx, payment)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, order)).To(Succeed())
+			order.Status.PaymentRef = &corev1.LocalObjectReference{Name: payment.Name}
+			Expect(k8sClient.Status().Update(ctx, order)).To(Succeed())
+
+			controllerReconciler := &OrderReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the licence of the ordered product")
+			licence := &productv1.Licence{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-0",
+				Namespace: "default",
+			}, licence)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, licence)).To(Succeed())
+			}()
+			Expect(licence.Spec.DisplayName).To(Equal("Sample Product"))
+			Expect(licence.Spec.Seats).To(Equal(int32(2)))
+			Expect(licence.Spec.ExpireTimestamp.Time).To(BeTemporally("~",
+				payment.Status.PaymentTimestamp.Add(licenceLifetime), time.Second))
+		})
+		It("should upgrade the plan of the tenant once the order is paid", func() {
+			By("Creating the tenant of the namespace")
+			tenant := &productv1.Tenant{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-tenant",
+				},
+				Spec: productv1.TenantSpec{
+					Country:    "HU",
+					City:       "Budapest",
+					Address:    "Address",
+					PostalCode: "1111",
+				},
+			}
+			Expect(k8sClient.Create(ctx, tenant)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, tenant)).To(Succeed())
+			}()
+
+			By("Labelling the namespace with its tenant")
+			namespace := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, namespace)).To(Succeed())
+			namespace.Labels[tenancy.TenantLabel] = tenant.Name
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, namespace)).To(Succeed())
+				delete(namespace.Labels, tenancy.TenantLabel)
+				Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			}()
+
+			By("Paying the order of a plan product")
+			payment := &productv1.Payment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: productv1.PaymentSpec{
+					Price: 200,
+				},
+			}
+			Expect(k8sClient.Create(ctx, payment)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, payment)).To(Succeed())
+			}()
+			payment.Status.PaymentTimestamp = metav1.Now()
+			Expect(k8sClient.Status().Update(ctx, payment)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, order)).To(Succeed())
+			order.Spec.Products[0].Product.UpgradePlan = "Pro"
+			Expect(k8sClient.Update(ctx, order)).To(Succeed())
+			order.Status.PaymentRef = &corev1.LocalObjectReference{Name: payment.Name}
+			Expect(k8sClient.Status().Update(ctx, order)).To(Succeed())
+
+			controllerReconciler := &OrderReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the tenant is on the ordered plan")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: tenant.Name}, tenant)).To(Succeed())
+			Expect(tenant.Spec.Plan).To(Equal("Pro"))
+		})
 	})
 })
@@ -18,13 +18,17 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/audit"
 )
 
 // PaymentReconciler reconciles a Payment object
@@ -36,20 +40,77 @@ type PaymentReconciler struct {
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=payments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=payments/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=payments/finalizers,verbs=update
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=orders,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=auditevents,verbs=get;list;watch;create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Payment object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It records the result of the Payment in the audit trail, every failed attempt and the success once.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *PaymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = logf.FromContext(ctx)
+	logger := logf.FromContext(ctx).WithValues("controller", "payment", "name", req.NamespacedName)
 
-	// TODO(user): your logic here
+	payment := productv1.Payment{}
+	if err := r.Get(ctx, req.NamespacedName, &payment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Payment fetch failed")
+		return ctrl.Result{}, err
+	}
+
+	if payment.DeletionTimestamp != nil || !payment.DeletionTimestamp.IsZero() {
+		logger.Info("Payment deleted")
+
+		return ctrl.Result{}, nil
+	}
+
+	if payment.Status.PaymentTimestamp.IsZero() && payment.Status.ErrorTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	refs := []corev1.ObjectReference{audit.Ref("Payment", &payment)}
+	orders := productv1.OrderList{}
+	if err := r.List(ctx, &orders, client.InNamespace(payment.Namespace)); err != nil {
+		logger.Error(err, "Order list failed")
+		return ctrl.Result{}, err
+	}
+	for i := range orders.Items {
+		if ref := orders.Items[i].Status.PaymentRef; ref != nil && ref.Name == payment.Name {
+			refs = append(refs, audit.Ref("Order", &orders.Items[i]))
+		}
+	}
+
+	if !payment.Status.ErrorTimestamp.IsZero() {
+		// Each failed attempt is recorded, the attempts are told apart by their time.
+		name := fmt.Sprintf("payment-failed-%s-%d", payment.UID, payment.Status.ErrorTimestamp.Unix())
+		if err := audit.Record(ctx, r.Client, payment.Namespace, name, productv1.AuditEventSpec{
+			Action:     audit.PaymentFailed,
+			Actor:      audit.Controller("payment"),
+			Timestamp:  payment.Status.ErrorTimestamp,
+			ObjectRefs: refs,
+			Message:    payment.Status.ErrorMessage,
+		}); err != nil {
+			logger.Error(err, "AuditEvent creation failed")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !payment.Status.PaymentTimestamp.IsZero() {
+		if err := audit.Record(ctx, r.Client, payment.Namespace, "payment-succeeded-"+string(payment.UID), productv1.AuditEventSpec{
+			Action:     audit.PaymentSucceeded,
+			Actor:      audit.Controller("payment"),
+			Timestamp:  payment.Status.PaymentTimestamp,
+			ObjectRefs: refs,
+			Message:    fmt.Sprintf("Payment of %d cents has succeeded", payment.Spec.Price),
+		}); err != nil {
+			logger.Error(err, "AuditEvent creation failed")
+			return ctrl.Result{}, err
+		}
+	}
 
 	return ctrl.Result{}, nil
 }
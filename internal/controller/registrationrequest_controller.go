@@ -19,6 +19,7 @@ package controller
 import (
 	"bytes"
 	"context"
+	"net/url"
 	"text/template"
 	"time"
 
@@ -27,11 +28,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/signing"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
 // RegistrationRequestReconciler reconciles a RegistrationRequest object
@@ -39,6 +44,10 @@ type RegistrationRequestReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	Namespace string
+	// VerificationURL is the address of the page verifying the email address, the token is appended as query parameter.
+	VerificationURL string
+	// VerificationTTL is the lifetime of the email verification tokens.
+	VerificationTTL time.Duration
 }
 
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=registrationrequests;emailtemplates,verbs=get;list;watch;create;update;patch;delete
@@ -80,6 +89,12 @@ func (r *RegistrationRequestReconciler) Reconcile(ctx context.Context, req ctrl.
 		},
 		Spec: *request.Spec.Tenant.DeepCopy(),
 	}
+	// Tenants start active on the Free plan, none of these is chosen at registration.
+	tenant.Spec.Plan = ""
+	tenant.Spec.Profile = ""
+	tenant.Spec.Suspended = false
+	tenant.Spec.SuspensionReason = ""
+	tenant.Spec.DeletionRequested = false
 	if err := r.Create(ctx, &tenant); err != nil {
 		if !apierrors.IsAlreadyExists(err) {
 			logger.Error(err, "Tenant creation failed")
@@ -89,15 +104,17 @@ func (r *RegistrationRequestReconciler) Reconcile(ctx context.Context, req ctrl.
 		logger.Info("Tenant has been created", "tenantName", tenant.Name)
 	}
 
-	namespace := corev1.Namespace{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Name: string(request.UID),
-	}, &namespace); err != nil {
-		if !apierrors.IsNotFound(err) {
-			logger.Error(err, "Namespace fetch failed")
-			return ctrl.Result{}, err
-		}
+	// The namespace is named by the naming strategy of the Tenant controller, which records it on the Tenant.
+	if err := r.Get(ctx, types.NamespacedName{Name: tenant.Name}, &tenant); err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "Tenant fetch failed", "tenantName", tenant.Name)
+		return ctrl.Result{}, err
+	}
 
+	namespaceName, err := tenancy.NamespaceOf(ctx, r.Client, &tenant)
+	if err != nil {
+		logger.Error(err, "Namespace lookup failed", "tenantName", tenant.Name)
+		return ctrl.Result{}, err
+	} else if namespaceName == "" {
 		logger.Info("Namespace not found, requeuing until Tenant controller creates it")
 
 		return ctrl.Result{
@@ -108,13 +125,12 @@ func (r *RegistrationRequestReconciler) Reconcile(ctx context.Context, req ctrl.
 	user := productv1.User{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      string(request.UID),
-			Namespace: namespace.Name,
-			Annotations: map[string]string{
-				"product.webshop.harikube.info/password": request.Spec.Password,
-			},
+			Namespace: namespaceName,
 		},
 		Spec: *request.Spec.User.DeepCopy(),
 	}
+	// The registering user owns the tenant, further users join by invitation.
+	user.Spec.Role = "Owner"
 	if err := r.Create(ctx, &user); err != nil {
 		if !apierrors.IsAlreadyExists(err) {
 			logger.Error(err, "User creation failed")
@@ -133,6 +149,48 @@ func (r *RegistrationRequestReconciler) Reconcile(ctx context.Context, req ctrl.
 	}
 	user.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("User"))
 
+	// The hash never touches the User object, the User controller moves it out of this Secret.
+	if user.Status.PasswordRef == nil && request.Spec.Password != "" {
+		handover := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      password.HandoverSecretName(user.Name),
+				Namespace: user.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: user.APIVersion,
+						Kind:       user.Kind,
+						Name:       user.Name,
+						UID:        user.UID,
+						Controller: ptr.To(true),
+					},
+				},
+			},
+			StringData: map[string]string{
+				"hash": request.Spec.Password,
+			},
+		}
+		if err := r.Create(ctx, &handover); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				logger.Error(err, "Secret creation failed", "secretName", handover.Name)
+				return ctrl.Result{}, err
+			}
+		} else {
+			logger.Info("Secret has been created", "secretName", handover.Name)
+		}
+	}
+
+	// The hash is removed from the request as soon as it is handed over, it is readable by anyone who may
+	// get RegistrationRequests until the request is deleted.
+	if request.Spec.Password != "" {
+		patchedRequest := request.DeepCopy()
+		patchedRequest.Spec.Password = ""
+		if err := r.Patch(ctx, patchedRequest, client.MergeFrom(&request)); err != nil {
+			logger.Error(err, "RegistrationRequest password removal failed")
+			return ctrl.Result{}, err
+		}
+		request = *patchedRequest
+	}
+
 	emailTemplate := productv1.EmailTemplate{}
 	if err := r.Get(ctx, types.NamespacedName{
 		Name:      "example-webshop-service-registration",
@@ -157,6 +215,19 @@ func (r *RegistrationRequestReconciler) Reconcile(ctx context.Context, req ctrl.
 			return ctrl.Result{}, err
 		}
 
+		key, err := signing.LoadKey(ctx, r.Client, r.Namespace)
+		if err != nil {
+			logger.Error(err, "Signing key fetch failed")
+			return ctrl.Result{}, err
+		}
+
+		verificationToken, err := signing.Sign(key, signing.PurposeVerify, string(user.UID), time.Now().Add(r.VerificationTTL))
+		if err != nil {
+			logger.Error(err, "Verification token signing failed")
+			return ctrl.Result{}, err
+		}
+		userMap["verificationURL"] = r.VerificationURL + "?token=" + url.QueryEscape(verificationToken)
+
 		var renderedBody bytes.Buffer
 		if err := renderer.Execute(&renderedBody, userMap); err != nil {
 			logger.Error(err, "EmailTemplate execution failed", "emailTemplateName", emailTemplate.Name)
@@ -18,17 +18,20 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/password"
 )
 
 var _ = Describe("RegistrationRequest Controller", func() {
@@ -94,9 +97,11 @@ var _ = Describe("RegistrationRequest Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &RegistrationRequestReconciler{
-				Client:    k8sClient,
-				Scheme:    k8sClient.Scheme(),
-				Namespace: typeNamespacedName.Namespace,
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				Namespace:       typeNamespacedName.Namespace,
+				VerificationURL: "https://example.com/verify",
+				VerificationTTL: time.Hour,
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -106,5 +111,43 @@ var _ = Describe("RegistrationRequest Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+		It("should clear the password once it has been handed over", func() {
+			recorder := &passwordPatchRecorder{Client: k8sClient}
+			controllerReconciler := &RegistrationRequestReconciler{
+				Client:          recorder,
+				Scheme:          k8sClient.Scheme(),
+				Namespace:       typeNamespacedName.Namespace,
+				VerificationURL: "https://example.com/verify",
+				VerificationTTL: time.Hour,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the password has been moved to the handover Secret")
+			handover := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      password.HandoverSecretName(string(registrationrequest.UID)),
+				Namespace: string(registrationrequest.UID),
+			}, handover)).To(Succeed())
+			Expect(string(handover.Data["hash"])).To(Equal("Passwd123!"))
+			Expect(recorder.passwords).To(Equal([]string{""}))
+		})
 	})
 })
+
+// passwordPatchRecorder records the passwords of the RegistrationRequests patched through it.
+type passwordPatchRecorder struct {
+	client.Client
+	passwords []string
+}
+
+func (c *passwordPatchRecorder) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if request, ok := obj.(*productv1.RegistrationRequest); ok {
+		c.passwords = append(c.passwords, request.Spec.Password)
+	}
+
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
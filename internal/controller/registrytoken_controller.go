@@ -17,47 +17,792 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	authorizationv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/audit"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+const (
+	registryHtpasswdSecretName = "example-webshop-service-registry-htpasswd"
+
+	// registrySecretLabel marks the credential Secrets of the tokens and the htpasswd Secret, only the
+	// Secrets carrying it are cached for the registry.
+	registrySecretLabel      = "product.webshop.harikube.info/registry-secret"
+	registryCredentialSecret = "credential"
+	registryHtpasswdSecret   = "htpasswd"
+
+	// registryTokenMinRequeue is the shortest requeue of a token, deadlines already passed by the time
+	// the result is returned do not spin the controller.
+	registryTokenMinRequeue = 5 * time.Second
 )
 
 // RegistryTokenReconciler reconciles a RegistryToken object
 type RegistryTokenReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme    *runtime.Scheme
+	Namespace string
+	Registry  string
+	// Retention is the time expired tokens are kept before deletion.
+	Retention time.Duration
+	// RotateBefore is the time before expiry auto-rotating tokens are rotated,
+	// it is capped at half of the credential lifetime.
+	RotateBefore time.Duration
+	// RotationOverlap is the time the previous credential stays valid after a rotation.
+	RotationOverlap time.Duration
+	// MaxLifetime caps the lifetime of rotated credentials like the webhook caps the lifetime of new tokens,
+	// tenant namespaces may override it. Zero means no limit.
+	MaxLifetime time.Duration
+
+	// credentials reads the Secrets labelled with registrySecretLabel.
+	credentials client.Reader
 }
 
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=registrytokens,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=registrytokens/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=registrytokens/finalizers,verbs=update
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=emails,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=emailtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=auditevents,verbs=get;list;watch;create
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the RegistryToken object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It issues a random registry credential for each RegistryToken, stores it in a
+// kubernetes.io/dockerconfigjson Secret referenced by TokenRef. The shared htpasswd
+// Secret consumed by the registry is rebuilt from all tokens by the registryhtpasswd controller.
+// Expired tokens are revoked and deleted after the retention period, tokens with
+// AutoRotate get a replacement credential with a fresh lifetime before each credential expires.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *RegistryTokenReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = logf.FromContext(ctx)
+	logger := logf.FromContext(ctx).WithValues("controller", "registrytoken", "name", req.NamespacedName)
+
+	token := productv1.RegistryToken{}
+	if err := r.Get(ctx, req.NamespacedName, &token); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "RegistryToken fetch failed")
+		return ctrl.Result{}, err
+	}
+	token.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("RegistryToken"))
+
+	if token.DeletionTimestamp != nil || !token.DeletionTimestamp.IsZero() {
+		logger.Info("RegistryToken deleted")
+
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	if !token.Spec.ExpireTimestamp.Time.After(now) {
+		return r.reconcileExpired(ctx, &token, now)
+	}
+
+	tenant, err := tenancy.TenantOf(ctx, r.Client, token.Namespace)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Tenant fetch failed", "namespaceName", token.Namespace)
+			return ctrl.Result{}, err
+		}
+		tenant = &productv1.Tenant{}
+	}
+	if tenant.Spec.Suspended {
+		return r.reconcileSuspended(ctx, &token)
+	}
+
+	patchedToken := token.DeepCopy()
+
+	secret := corev1.Secret{}
+	if token.Status.TokenRef != nil {
+		if err := r.Get(ctx, types.NamespacedName{
+			Name:      token.Status.TokenRef.Name,
+			Namespace: token.Namespace,
+		}, &secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				logger.Error(err, "Secret fetch failed", "secretName", token.Status.TokenRef.Name)
+				return ctrl.Result{}, err
+			}
+
+			logger.Info("Secret not found, issuing new credential", "secretName", token.Status.TokenRef.Name)
+		} else if secret.Labels[registrySecretLabel] != registryCredentialSecret {
+			// Credentials issued before the label was introduced are labelled, so the htpasswd Secret sees them.
+			patchedSecret := secret.DeepCopy()
+			if patchedSecret.Labels == nil {
+				patchedSecret.Labels = map[string]string{}
+			}
+			patchedSecret.Labels[registrySecretLabel] = registryCredentialSecret
+			if err := r.Patch(ctx, patchedSecret, client.MergeFrom(&secret)); err != nil {
+				logger.Error(err, "Secret label patch failed", "secretName", secret.Name)
+				return ctrl.Result{}, err
+			}
+			secret = *patchedSecret
+		}
+	}
+
+	issueTimestamp := token.Status.IssueTimestamp
+	if issueTimestamp.IsZero() {
+		issueTimestamp = token.CreationTimestamp
+	}
+
+	// Each rotation issues a credential with the lifetime of the current one, capped at the maximum lifetime
+	// of the tenant, and moves the expiration of the token along. The previous credential stays valid for
+	// the overlap window, so clients have time to pick up the new one.
+	rotateAt := r.rotateAt(issueTimestamp.Time, token.Spec.ExpireTimestamp.Time)
+	rotate := token.Spec.AutoRotate && secret.Name != "" && !now.Before(rotateAt)
+	if rotate {
+		lifetime, err := r.rotationLifetime(ctx, &token, issueTimestamp.Time)
+		if err != nil {
+			logger.Error(err, "Rotation lifetime lookup failed")
+			return ctrl.Result{}, r.patchError(ctx, &token, err)
+		}
+
+		// A credential still overlapping from the rotation before is revoked right away.
+		if prev := token.Status.PreviousTokenRef; prev != nil {
+			if err := r.deleteSecret(ctx, token.Namespace, prev.Name); err != nil {
+				logger.Error(err, "Secret deletion failed", "secretName", prev.Name)
+				return ctrl.Result{}, err
+			}
+		}
+
+		revokeAt := now.Add(r.RotationOverlap)
+		if token.Spec.ExpireTimestamp.Time.Before(revokeAt) {
+			revokeAt = token.Spec.ExpireTimestamp.Time
+		}
+
+		extendedToken := token.DeepCopy()
+		extendedToken.Spec.ExpireTimestamp = metav1.NewTime(now.Add(lifetime).Truncate(time.Second))
+		if err := r.Patch(ctx, extendedToken, client.MergeFrom(&token)); err != nil {
+			logger.Error(err, "RegistryToken expiration update failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("RegistryToken expiration has been extended", "expireTimestamp", extendedToken.Spec.ExpireTimestamp)
+
+		patchedToken.Spec.ExpireTimestamp = extendedToken.Spec.ExpireTimestamp
+		patchedToken.Status.PreviousTokenRef = token.Status.TokenRef
+		patchedToken.Status.PreviousExpireTimestamp = metav1.NewTime(revokeAt)
+		patchedToken.Status.Rotations = token.Status.Rotations + 1
+		secret = corev1.Secret{}
+	}
+
+	if secret.Name == "" {
+		name := string(token.UID)
+		if patchedToken.Status.Rotations > 0 {
+			name = fmt.Sprintf("%s-%d", token.UID, patchedToken.Status.Rotations)
+		}
+
+		credential, err := r.newCredentialSecret(&token, name)
+		if err != nil {
+			logger.Error(err, "Credential generation failed")
+			return ctrl.Result{}, r.patchError(ctx, &token, err)
+		}
+
+		if err := r.Create(ctx, credential); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				logger.Error(err, "Secret creation failed", "secretName", credential.Name)
+				return ctrl.Result{}, r.patchError(ctx, &token, err)
+			}
+		} else {
+			logger.Info("Secret has been created", "secretName", credential.Name)
+		}
+
+		secret = *credential
+		patchedToken.Status.IssueTimestamp = metav1.NewTime(now)
+	}
+	patchedToken.Status.TokenExpireTimestamp = patchedToken.Spec.ExpireTimestamp
+
+	if prev := patchedToken.Status.PreviousTokenRef; prev != nil && !patchedToken.Status.PreviousExpireTimestamp.Time.After(now) {
+		if err := r.deleteSecret(ctx, token.Namespace, prev.Name); err != nil {
+			logger.Error(err, "Secret deletion failed", "secretName", prev.Name)
+			return ctrl.Result{}, err
+		}
+		logger.Info("Previous credential has been revoked", "secretName", prev.Name)
+
+		patchedToken.Status.PreviousTokenRef = nil
+		patchedToken.Status.PreviousExpireTimestamp = metav1.Time{}
+	}
+
+	if err := r.reconcileSecretAccess(ctx, &token, secret.Name); err != nil {
+		logger.Error(err, "Secret access reconciliation failed", "secretName", secret.Name)
+		return ctrl.Result{}, err
+	}
 
-	// TODO(user): your logic here
+	patchedToken.Status.LastGeneration = token.Generation
+	patchedToken.Status.Phase = "Active"
+	patchedToken.Status.TokenRef = &corev1.LocalObjectReference{
+		Name: secret.Name,
+	}
+	patchedToken.Status.ErrorMessage = ""
+	patchedToken.Status.ErrorTimestamp = metav1.Time{}
+	if err := r.Status().Patch(ctx, patchedToken, client.MergeFrom(&token)); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "RegistryToken status update failed")
+		return ctrl.Result{}, err
+	}
+
+	if token.Status.IssueTimestamp.IsZero() {
+		if err := audit.Record(ctx, r.Client, token.Namespace, "registrytoken-created-"+string(token.UID), productv1.AuditEventSpec{
+			Action:     audit.RegistryTokenCreated,
+			Actor:      productv1.AuditActor{Email: token.Spec.User.Email},
+			Timestamp:  patchedToken.Status.IssueTimestamp,
+			ObjectRefs: []corev1.ObjectReference{audit.Ref("RegistryToken", &token)},
+			Message:    "RegistryToken has been issued until " + patchedToken.Spec.ExpireTimestamp.UTC().Format(time.RFC3339),
+		}); err != nil {
+			logger.Error(err, "AuditEvent creation failed")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if rotate {
+		if err := r.notifyRotation(ctx, patchedToken); err != nil {
+			logger.Error(err, "Rotation notification failed")
+			return ctrl.Result{}, err
+		}
+	}
+
+	next := patchedToken.Spec.ExpireTimestamp.Time
+	if patchedToken.Spec.AutoRotate {
+		if at := r.rotateAt(patchedToken.Status.IssueTimestamp.Time, next); at.Before(next) {
+			next = at
+		}
+	}
+	if patchedToken.Status.PreviousTokenRef != nil && patchedToken.Status.PreviousExpireTimestamp.Time.Before(next) {
+		next = patchedToken.Status.PreviousExpireTimestamp.Time
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter(next)}, nil
+}
+
+// reconcileExpired revokes the credentials of an expired token and deletes the token after the retention period.
+func (r *RegistryTokenReconciler) reconcileExpired(ctx context.Context, token *productv1.RegistryToken, now time.Time) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "registrytoken", "name", token.Name, "namespace", token.Namespace)
+
+	if token.Status.Phase != "Expired" {
+		for _, ref := range []*corev1.LocalObjectReference{token.Status.TokenRef, token.Status.PreviousTokenRef} {
+			if ref == nil {
+				continue
+			}
+
+			if err := r.deleteSecret(ctx, token.Namespace, ref.Name); err != nil {
+				logger.Error(err, "Secret deletion failed", "secretName", ref.Name)
+				return ctrl.Result{}, err
+			}
+		}
+
+		patchedToken := token.DeepCopy()
+		patchedToken.Status.LastGeneration = token.Generation
+		patchedToken.Status.Phase = "Expired"
+		patchedToken.Status.TokenRef = nil
+		patchedToken.Status.PreviousTokenRef = nil
+		patchedToken.Status.PreviousExpireTimestamp = metav1.Time{}
+		patchedToken.Status.ExpiredTimestamp = metav1.NewTime(now)
+		if err := r.Status().Patch(ctx, patchedToken, client.MergeFrom(token)); err != nil {
+			if apierrors.IsNotFound(err) {
+				return ctrl.Result{}, nil
+			}
+
+			logger.Error(err, "RegistryToken status update failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("RegistryToken has been expired")
+
+		token = patchedToken
+	}
+
+	deleteAt := token.Status.ExpiredTimestamp.Add(r.Retention)
+	if now.Before(deleteAt) {
+		return ctrl.Result{RequeueAfter: requeueAfter(deleteAt)}, nil
+	}
+
+	if err := r.Delete(ctx, token); err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "RegistryToken deletion failed")
+		return ctrl.Result{}, err
+	}
+	logger.Info("RegistryToken has been deleted after retention")
 
 	return ctrl.Result{}, nil
 }
 
+// reconcileSuspended withdraws the credentials of a token of a suspended tenant from the registry. The
+// credential Secret is kept, so reactivating the tenant restores the very same credential.
+func (r *RegistryTokenReconciler) reconcileSuspended(ctx context.Context, token *productv1.RegistryToken) (ctrl.Result, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "registrytoken", "name", token.Name, "namespace", token.Namespace)
+
+	if token.Status.Phase != "Suspended" {
+		patchedToken := token.DeepCopy()
+		patchedToken.Status.LastGeneration = token.Generation
+		patchedToken.Status.Phase = "Suspended"
+		if err := r.Status().Patch(ctx, patchedToken, client.MergeFrom(token)); err != nil {
+			if apierrors.IsNotFound(err) {
+				return ctrl.Result{}, nil
+			}
+
+			logger.Error(err, "RegistryToken status update failed")
+			return ctrl.Result{}, err
+		}
+		logger.Info("RegistryToken has been suspended")
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter(token.Spec.ExpireTimestamp.Time)}, nil
+}
+
+// tokensOfTenant maps a Tenant to the RegistryTokens of its namespace.
+func (r *RegistryTokenReconciler) tokensOfTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenant, ok := obj.(*productv1.Tenant)
+	if !ok || tenant.Status.Namespace == "" {
+		return nil
+	}
+
+	tokens := productv1.RegistryTokenList{}
+	if err := r.List(ctx, &tokens, client.InNamespace(tenant.Status.Namespace)); err != nil {
+		logf.FromContext(ctx).Error(err, "RegistryToken list failed", "tenantName", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(tokens.Items))
+	for _, token := range tokens.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      token.Name,
+				Namespace: token.Namespace,
+			},
+		})
+	}
+
+	return requests
+}
+
+// requeueAfter returns the delay until at, it is at least registryTokenMinRequeue.
+func requeueAfter(at time.Time) time.Duration {
+	return max(time.Until(at), registryTokenMinRequeue)
+}
+
+// rotateAt returns the time a credential issued at issued and expiring at expire is due for rotation.
+func (r *RegistryTokenReconciler) rotateAt(issued, expire time.Time) time.Time {
+	before := r.RotateBefore
+	if half := expire.Sub(issued) / 2; half < before {
+		before = half
+	}
+
+	return expire.Add(-before)
+}
+
+// rotationLifetime returns the lifetime of the credential replacing the one issued at issued: the lifetime of the
+// current credential, capped at the maximum lifetime of the tenant.
+func (r *RegistryTokenReconciler) rotationLifetime(ctx context.Context, token *productv1.RegistryToken, issued time.Time) (time.Duration, error) {
+	namespace := corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: token.Namespace}, &namespace); err != nil {
+		return 0, err
+	}
+
+	maxLifetime, err := tenancy.RegistryTokenMaxLifetime(&namespace, r.MaxLifetime)
+	if err != nil {
+		return 0, err
+	}
+
+	lifetime := token.Spec.ExpireTimestamp.Sub(issued)
+	if maxLifetime > 0 && lifetime > maxLifetime {
+		lifetime = maxLifetime
+	}
+
+	return lifetime, nil
+}
+
+// notifyRotation sends the owner of the token an Email about the replaced credential.
+func (r *RegistryTokenReconciler) notifyRotation(ctx context.Context, token *productv1.RegistryToken) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "registrytoken", "name", token.Name, "namespace", token.Namespace)
+
+	emailTemplate := productv1.EmailTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      "example-webshop-service-registry-token-rotation",
+		Namespace: r.Namespace,
+	}, &emailTemplate); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("EmailTemplate not found, skipping notification", "emailTemplateName", "example-webshop-service-registry-token-rotation")
+			return nil
+		}
+
+		return err
+	}
+
+	renderer, err := template.New("registry_token_rotation_template").Parse(emailTemplate.Spec.Body)
+	if err != nil {
+		return err
+	}
+
+	tokenMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(token)
+	if err != nil {
+		return err
+	}
+
+	var renderedBody bytes.Buffer
+	if err := renderer.Execute(&renderedBody, tokenMap); err != nil {
+		return err
+	}
+
+	email := productv1.Email{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-rotation-%d", token.Name, token.Status.Rotations),
+			Namespace: token.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: token.APIVersion,
+					Kind:       token.Kind,
+					Name:       token.Name,
+					UID:        token.UID,
+				},
+			},
+		},
+		Spec: productv1.EmailSpec{
+			ToAddress:   token.Spec.User.Email,
+			FromName:    emailTemplate.Spec.FromName,
+			FromAddress: emailTemplate.Spec.FromAddress,
+			Subject:     emailTemplate.Spec.Subject,
+			Body:        renderedBody.String(),
+		},
+	}
+	if err := r.Create(ctx, &email); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	} else {
+		logger.Info("Email has been created", "emailName", email.Name)
+	}
+
+	return nil
+}
+
+// deleteSecret deletes the named Secret of the token namespace, missing Secrets are ignored.
+func (r *RegistryTokenReconciler) deleteSecret(ctx context.Context, namespace, name string) error {
+	if err := r.Delete(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// newCredentialSecret generates a random password for the token and wraps it into a docker config Secret.
+func (r *RegistryTokenReconciler) newCredentialSecret(token *productv1.RegistryToken, name string) (*corev1.Secret, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	username := token.Namespace + "/" + token.Name
+	password := base64.RawURLEncoding.EncodeToString(raw)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	dockerConfig, err := json.Marshal(map[string]any{
+		"auths": map[string]any{
+			r.Registry: map[string]string{
+				"username": username,
+				"password": password,
+				"auth":     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode docker config: %w", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: token.Namespace,
+			Labels: map[string]string{
+				registrySecretLabel: registryCredentialSecret,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: token.APIVersion,
+					Kind:       token.Kind,
+					Name:       token.Name,
+					UID:        token.UID,
+				},
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: dockerConfig,
+			"username":                 []byte(username),
+			"password":                 []byte(password),
+			"htpasswd":                 []byte(username + ":" + string(hash)),
+		},
+	}, nil
+}
+
+// reconcileSecretAccess grants the ServiceAccount of the token owner read access to the credential Secret.
+func (r *RegistryTokenReconciler) reconcileSecretAccess(ctx context.Context, token *productv1.RegistryToken, secretName string) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "registrytoken", "name", token.Name, "namespace", token.Namespace)
+
+	users := productv1.UserList{}
+	if err := r.List(ctx, &users, client.InNamespace(token.Namespace), client.MatchingFields{"spec.email": token.Spec.User.Email}); err != nil {
+		return err
+	} else if len(users.Items) == 0 {
+		logger.Info("User not found, skipping Secret access", "email", token.Spec.User.Email)
+		return nil
+	}
+	user := users.Items[0]
+
+	ownerReferences := []metav1.OwnerReference{
+		{
+			APIVersion: token.APIVersion,
+			Kind:       token.Kind,
+			Name:       token.Name,
+			UID:        token.UID,
+		},
+	}
+
+	rules := []authorizationv1.PolicyRule{
+		{
+			APIGroups:     []string{""},
+			Resources:     []string{"secrets"},
+			ResourceNames: []string{secretName},
+			Verbs:         []string{"get"},
+		},
+	}
+
+	role := authorizationv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            string(token.UID),
+			Namespace:       token.Namespace,
+			OwnerReferences: ownerReferences,
+		},
+		Rules: rules,
+	}
+	if err := r.Create(ctx, &role); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+
+		if err := r.Get(ctx, types.NamespacedName{
+			Name:      role.Name,
+			Namespace: role.Namespace,
+		}, &role); err != nil {
+			return err
+		}
+
+		role.Rules = rules
+		if err := r.Update(ctx, &role); err != nil {
+			return err
+		}
+	} else {
+		logger.Info("Role has been created", "roleName", role.Name)
+	}
+
+	roleBinding := authorizationv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            string(token.UID),
+			Namespace:       token.Namespace,
+			OwnerReferences: ownerReferences,
+		},
+		Subjects: []authorizationv1.Subject{
+			{
+				Kind:      authorizationv1.ServiceAccountKind,
+				Name:      string(user.UID),
+				Namespace: user.Namespace,
+			},
+		},
+		RoleRef: authorizationv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     role.Name,
+		},
+	}
+	if err := r.Create(ctx, &roleBinding); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	} else {
+		logger.Info("RoleBinding has been created", "roleBindingName", roleBinding.Name)
+	}
+
+	return nil
+}
+
+// reconcileHtpasswd rebuilds the htpasswd Secret consumed by the registry from the credentials of all tokens,
+// the Secret is only written when its content changes.
+func (r *RegistryTokenReconciler) reconcileHtpasswd(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "registryhtpasswd", "secretName", registryHtpasswdSecretName)
+
+	tokens := productv1.RegistryTokenList{}
+	if err := r.List(ctx, &tokens); err != nil {
+		return err
+	}
+
+	credentials := corev1.SecretList{}
+	if err := r.credentials.List(ctx, &credentials, client.MatchingLabels{registrySecretLabel: registryCredentialSecret}); err != nil {
+		return err
+	}
+	htpasswdOf := make(map[types.NamespacedName][]byte, len(credentials.Items))
+	for _, secret := range credentials.Items {
+		htpasswdOf[client.ObjectKeyFromObject(&secret)] = secret.Data["htpasswd"]
+	}
+
+	entries := []string{}
+	for _, token := range tokens.Items {
+		// htpasswd holds a single password per username, so the previous credential of a
+		// rotated token is only accepted by the token endpoint until it expires.
+		if token.DeletionTimestamp != nil || token.Status.Phase == "Expired" || token.Status.Phase == "Suspended" || token.Status.TokenRef == nil {
+			continue
+		}
+
+		if entry := htpasswdOf[types.NamespacedName{Name: token.Status.TokenRef.Name, Namespace: token.Namespace}]; len(entry) != 0 {
+			entries = append(entries, string(entry))
+		}
+	}
+	sort.Strings(entries)
+
+	htpasswd := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registryHtpasswdSecretName,
+			Namespace: r.Namespace,
+			Labels: map[string]string{
+				registrySecretLabel: registryHtpasswdSecret,
+			},
+		},
+		Data: map[string][]byte{
+			"htpasswd": []byte(strings.Join(entries, "\n")),
+		},
+	}
+
+	current := corev1.Secret{}
+	if err := r.credentials.Get(ctx, client.ObjectKeyFromObject(&htpasswd), &current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		if err := r.Create(ctx, &htpasswd); err == nil {
+			logger.Info("Secret has been created", "entries", len(entries))
+			return nil
+		} else if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	} else if string(current.Data["htpasswd"]) == string(htpasswd.Data["htpasswd"]) {
+		return nil
+	}
+
+	// A Secret created before the label was introduced is not cached, the merge patch labels it as well.
+	if err := r.Patch(ctx, &htpasswd, client.Merge); err != nil {
+		return err
+	}
+	logger.Info("Secret has been updated", "entries", len(entries))
+
+	return nil
+}
+
+// htpasswdRequest maps every token and credential event to the single request of the htpasswd Secret,
+// the events arriving while it waits in the queue are collapsed into one rebuild.
+func (r *RegistryTokenReconciler) htpasswdRequest(context.Context, client.Object) []reconcile.Request {
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{
+			Name:      registryHtpasswdSecretName,
+			Namespace: r.Namespace,
+		},
+	}}
+}
+
+// patchError records the reconciliation failure on the RegistryToken status.
+func (r *RegistryTokenReconciler) patchError(ctx context.Context, token *productv1.RegistryToken, cause error) error {
+	patchedToken := token.DeepCopy()
+	patchedToken.Status.ErrorMessage = cause.Error()
+	patchedToken.Status.ErrorTimestamp = metav1.Now()
+	if err := r.Status().Patch(ctx, patchedToken, client.MergeFrom(token)); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return cause
+}
+
 // SetupWithManager sets up the controller with the Manager.
+// The credential Secrets are watched through a cache of the labelled Secrets only, next to the token
+// controller the registryhtpasswd controller keeps the htpasswd Secret in sync.
 func (r *RegistryTokenReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	registrySecrets, err := labels.NewRequirement(registrySecretLabel, selection.Exists, nil)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient: mgr.GetHTTPClient(),
+		Scheme:     mgr.GetScheme(),
+		Mapper:     mgr.GetRESTMapper(),
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Secret{}: {Label: labels.NewSelector().Add(*registrySecrets)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(credentials); err != nil {
+		return err
+	}
+	r.credentials = credentials
+
+	if err := ctrl.NewControllerManagedBy(mgr).
 		For(&productv1.RegistryToken{}).
+		WatchesRawSource(source.Kind(credentials, &corev1.Secret{},
+			handler.TypedEnqueueRequestForOwner[*corev1.Secret](mgr.GetScheme(), mgr.GetRESTMapper(), &productv1.RegistryToken{}))).
+		Watches(&productv1.Tenant{}, handler.EnqueueRequestsFromMapFunc(r.tokensOfTenant), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Named("registrytoken").
-		Complete(r)
+		Complete(r); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Watches(&productv1.RegistryToken{}, handler.EnqueueRequestsFromMapFunc(r.htpasswdRequest)).
+		WatchesRawSource(source.Kind(credentials, &corev1.Secret{},
+			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, secret *corev1.Secret) []reconcile.Request {
+				return r.htpasswdRequest(ctx, secret)
+			}))).
+		Named("registryhtpasswd").
+		Complete(reconcile.Func(func(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, r.reconcileHtpasswd(ctx)
+		}))
 }
@@ -18,11 +18,14 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -74,19 +77,177 @@ var _ = Describe("RegistryToken Controller", func() {
 			By("Cleanup the specific resource instance RegistryToken")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 		})
-		It("should successfully reconcile the resource", func() {
+		It("should issue a labelled pull credential for the registry", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &RegistryTokenReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Namespace: "default",
+				Registry:  "registry.example.com",
+				Retention: time.Hour,
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the issued credential")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			Expect(registrytoken.Status.TokenRef).NotTo(BeNil())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      registrytoken.Status.TokenRef.Name,
+				Namespace: "default",
+			}, secret)).To(Succeed())
+			Expect(secret.Type).To(Equal(corev1.SecretTypeDockerConfigJson))
+			Expect(secret.Data).To(HaveKey(corev1.DockerConfigJsonKey))
+			Expect(string(secret.Data[corev1.DockerConfigJsonKey])).To(ContainSubstring("registry.example.com"))
+			Expect(secret.Labels).To(HaveKeyWithValue(registrySecretLabel, registryCredentialSecret))
+		})
+		It("should rebuild the htpasswd Secret from the labelled credentials", func() {
+			controllerReconciler := &RegistryTokenReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				Namespace:   "default",
+				Registry:    "registry.example.com",
+				Retention:   time.Hour,
+				credentials: k8sClient,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Rebuilding the htpasswd Secret")
+			Expect(controllerReconciler.reconcileHtpasswd(ctx)).To(Succeed())
+
+			htpasswd := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      registryHtpasswdSecretName,
+				Namespace: "default",
+			}, htpasswd)).To(Succeed())
+			Expect(htpasswd.Labels).To(HaveKeyWithValue(registrySecretLabel, registryHtpasswdSecret))
+			Expect(string(htpasswd.Data["htpasswd"])).To(HavePrefix("default/" + resourceName + ":"))
+
+			By("Leaving the unchanged htpasswd Secret alone")
+			Expect(controllerReconciler.reconcileHtpasswd(ctx)).To(Succeed())
+			unchanged := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(htpasswd), unchanged)).To(Succeed())
+			Expect(unchanged.ResourceVersion).To(Equal(htpasswd.ResourceVersion))
+		})
+		It("should rotate the credential with a fresh lifetime on every cycle", func() {
+			controllerReconciler := &RegistryTokenReconciler{
+				Client:          k8sClient,
+				Scheme:          k8sClient.Scheme(),
+				Namespace:       "default",
+				Registry:        "registry.example.com",
+				Retention:       time.Hour,
+				RotateBefore:    time.Hour,
+				RotationOverlap: 10 * time.Minute,
+			}
+
+			backdate := func() {
+				Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+				registrytoken.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(30 * time.Minute).Truncate(time.Second))
+				Expect(k8sClient.Update(ctx, registrytoken)).To(Succeed())
+				registrytoken.Status.IssueTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+				Expect(k8sClient.Status().Update(ctx, registrytoken)).To(Succeed())
+			}
+
+			By("Enabling the rotation of a token in its last hour")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			registrytoken.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(30 * time.Minute).Truncate(time.Second))
+			registrytoken.Spec.AutoRotate = true
+			Expect(k8sClient.Update(ctx, registrytoken)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			firstSecret := registrytoken.Status.TokenRef.Name
+
+			By("Rotating the credential issued before the rotation time")
+			backdate()
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">=", registryTokenMinRequeue))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			Expect(registrytoken.Spec.ExpireTimestamp.Time).To(BeTemporally("~", time.Now().Add(150*time.Minute), time.Minute))
+			Expect(registrytoken.Status.TokenExpireTimestamp.Equal(&registrytoken.Spec.ExpireTimestamp)).To(BeTrue())
+			Expect(registrytoken.Status.Rotations).To(Equal(int32(1)))
+			Expect(registrytoken.Status.PreviousTokenRef.Name).To(Equal(firstSecret))
+			Expect(registrytoken.Status.TokenRef.Name).NotTo(Equal(firstSecret))
+			Expect(registrytoken.Status.PreviousExpireTimestamp.Time).To(BeTemporally("~", time.Now().Add(10*time.Minute), time.Minute))
+			secondSecret := registrytoken.Status.TokenRef.Name
+
+			By("Keeping the replacement credential until its rotation time")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			Expect(registrytoken.Status.Rotations).To(Equal(int32(1)))
+
+			By("Rotating the replacement credential again")
+			backdate()
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			Expect(registrytoken.Status.Rotations).To(Equal(int32(2)))
+			Expect(registrytoken.Status.PreviousTokenRef.Name).To(Equal(secondSecret))
+
+			By("Revoking the credential still overlapping from the first rotation")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: firstSecret, Namespace: "default"}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+		It("should not requeue immediately once a deadline has passed", func() {
+			Expect(requeueAfter(time.Now().Add(-time.Minute))).To(Equal(registryTokenMinRequeue))
+			Expect(requeueAfter(time.Now().Add(time.Hour))).To(BeNumerically(">", 59*time.Minute))
+		})
+		It("should revoke the credential of an expired resource", func() {
+			controllerReconciler := &RegistryTokenReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Namespace: "default",
+				Registry:  "registry.example.com",
+				Retention: time.Hour,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Expiring the resource")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			secretName := registrytoken.Status.TokenRef.Name
+			registrytoken.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+			Expect(k8sClient.Update(ctx, registrytoken)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Checking the revoked credential")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, registrytoken)).To(Succeed())
+			Expect(registrytoken.Status.Phase).To(Equal("Expired"))
+			Expect(registrytoken.Status.TokenRef).To(BeNil())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: "default"}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
 		})
 	})
 })
@@ -17,33 +17,107 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/export"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
+const (
+	// tenantQuotaName is the name of the ResourceQuota in the tenant namespaces.
+	tenantQuotaName = "tenant-quota"
+	// tenantLimitRangeName is the name of the LimitRange in the tenant namespaces.
+	tenantLimitRangeName = "tenant-limits"
+	// tenantNetworkPolicyName is the name of the default-deny NetworkPolicy in the tenant namespaces.
+	tenantNetworkPolicyName = "default-deny"
+	// tenantRetainUntilAnnotation holds the time the data exports and deletion confirmations are deleted at.
+	tenantRetainUntilAnnotation = "product.webshop.harikube.info/retain-until"
+	// tenantCleanupInterval is how often the data exports and deletion confirmations are checked for deletion.
+	tenantCleanupInterval = time.Hour
+)
+
+// defaultTenantProfile guards the tenants whose TenantProfile does not exist.
+var defaultTenantProfile = productv1.TenantProfileSpec{
+	Hard: corev1.ResourceList{
+		"count/orders.product.webshop.harikube.info":         resource.MustParse("100"),
+		"count/registrytokens.product.webshop.harikube.info": resource.MustParse("20"),
+		"count/emails.product.webshop.harikube.info":         resource.MustParse("500"),
+		"count/invitations.product.webshop.harikube.info":    resource.MustParse("50"),
+		"count/secrets":    resource.MustParse("500"),
+		"count/configmaps": resource.MustParse("50"),
+	},
+	Limits: []corev1.LimitRangeItem{
+		{
+			Type: corev1.LimitTypeContainer,
+			Default: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+			DefaultRequest: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+		},
+	},
+}
+
 // TenantReconciler reconciles a Tenant object
 type TenantReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// DefaultProfile is the name of the TenantProfile of tenants without their own or one named after their plan.
+	DefaultProfile string
+	// Namespace keeps the data exports and the deletion confirmations, which outlive the tenant namespace.
+	Namespace string
+	// DeletionGracePeriod is the time the deletion of a tenant can be cancelled within.
+	DeletionGracePeriod time.Duration
+	// Retention is the time the data exports and the deletion confirmations are kept, zero keeps them forever.
+	Retention time.Duration
+	// Naming chooses the names of the tenant namespaces, they are named after the tenant if nil.
+	Naming tenancy.Naming
 }
 
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants/finalizers,verbs=update
 
-// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users;licences;orders,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenantprofiles,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=registrytokens;licences;orders,verbs=delete
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=invitations;payments;emails;registrytokens,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=emails,verbs=create;delete
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=emailtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=namespaces;resourcequotas;limitranges,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -68,21 +142,35 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 	tenant.GetObjectKind().SetGroupVersionKind(productv1.GroupVersion.WithKind("Tenant"))
 
+	namespaceName, err := r.namespaceOf(ctx, &tenant)
+	if err != nil {
+		logger.Error(err, "Namespace lookup failed")
+		return ctrl.Result{}, err
+	}
+
 	if tenant.DeletionTimestamp != nil || !tenant.DeletionTimestamp.IsZero() {
 		logger.Info("Tenant deleted")
+		tenant.Status.Namespace = namespaceName
+
+		// Finalizers are only released once the tenant has been exported and wound down in order.
+		if controllerutil.ContainsFinalizer(&tenant, "product.webshop.harikube.info/tenant") {
+			if err := r.windDownTenant(ctx, &tenant); err != nil {
+				logger.Error(err, "Tenant wind down failed")
+				return ctrl.Result{}, err
+			}
+		}
 
-		namespace := corev1.Namespace{}
-		if err := r.Get(ctx, types.NamespacedName{
-			Name: string(tenant.UID),
-		}, &namespace); err != nil {
+		namespace, err := r.tenantNamespace(ctx, &tenant)
+		if err != nil {
 			if !apierrors.IsNotFound(err) {
-				logger.Error(err, "Namespace fetch failed", "namespaceName", namespace.Name)
+				logger.Error(err, "Namespace fetch failed", "namespaceName", namespaceName)
 				return ctrl.Result{}, err
 			}
+			namespace = &corev1.Namespace{}
 		}
-		if controllerutil.ContainsFinalizer(&namespace, "product.webshop.harikube.info/tenant") {
-			controllerutil.RemoveFinalizer(&namespace, "product.webshop.harikube.info/tenant")
-			if err := r.Update(ctx, &namespace); err != nil {
+		if controllerutil.ContainsFinalizer(namespace, "product.webshop.harikube.info/tenant") {
+			controllerutil.RemoveFinalizer(namespace, "product.webshop.harikube.info/tenant")
+			if err := r.Update(ctx, namespace); err != nil {
 				if apierrors.IsNotFound(err) {
 					return ctrl.Result{}, nil
 				}
@@ -108,9 +196,761 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	} else if tenant.Generation == 1 && tenant.Status.LastGeneration == 0 {
 		logger.Info("Tenant created")
 
+		if namespaceName == "" {
+			if namespaceName, err = r.createNamespace(ctx, &tenant); err != nil {
+				logger.Error(err, "Namespace creation failed")
+				return ctrl.Result{}, err
+			}
+		}
+	} else if tenant.Status.LastGeneration != tenant.Generation {
+		logger.Info("Tenant updated")
+	}
+
+	patchedTenant := tenant.DeepCopy()
+	patchedTenant.Status.LastGeneration = tenant.Generation
+	patchedTenant.Status.Namespace = namespaceName
+
+	if err := r.labelNamespace(ctx, patchedTenant); err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "Namespace labelling failed", "namespaceName", namespaceName)
+		return ctrl.Result{}, err
+	}
+
+	profile, err := r.applyGuardrails(ctx, patchedTenant)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Tenant guardrails failed")
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Namespace not found, requeuing guardrails")
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	}
+	patchedTenant.Status.Profile = profile
+
+	if err := r.observeTenant(ctx, patchedTenant); err != nil {
+		logger.Error(err, "Tenant observation failed")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.suspendLicences(ctx, patchedTenant); err != nil {
+		logger.Error(err, "Licence suspension failed")
+		return ctrl.Result{}, err
+	}
+	setSuspendedCondition(patchedTenant)
+
+	deleteAt, err := r.scheduleDeletion(ctx, patchedTenant)
+	if err != nil {
+		logger.Error(err, "Tenant deletion scheduling failed")
+		return ctrl.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(patchedTenant.Status, tenant.Status) {
+		if err := r.Status().Patch(ctx, patchedTenant, client.MergeFrom(&tenant)); err != nil {
+			if apierrors.IsNotFound(err) {
+				return ctrl.Result{}, nil
+			}
+
+			logger.Error(err, "Tenant status update failed")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if deleteAt.IsZero() {
+		return ctrl.Result{}, nil
+	} else if wait := time.Until(deleteAt); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if err := r.Delete(ctx, patchedTenant); err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "Tenant deletion failed")
+		return ctrl.Result{}, err
+	}
+	logger.Info("Tenant has been deleted after the grace period")
+
+	return ctrl.Result{}, nil
+}
+
+// scheduleDeletion starts, keeps or cancels the grace period of a requested deletion and returns the time the
+// tenant is due for deletion at, zero if no deletion is requested. The data export is taken once the grace
+// period is over, right before the deletion.
+func (r *TenantReconciler) scheduleDeletion(ctx context.Context, tenant *productv1.Tenant) (time.Time, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	if !tenant.Spec.DeletionRequested {
+		if !tenant.Status.DeletionScheduledTimestamp.IsZero() {
+			logger.Info("Tenant deletion has been cancelled")
+		}
+		tenant.Status.DeletionScheduledTimestamp = metav1.Time{}
+		meta.RemoveStatusCondition(&tenant.Status.Conditions, "PendingDeletion")
+
+		return time.Time{}, nil
+	}
+
+	if tenant.Status.DeletionScheduledTimestamp.IsZero() {
+		tenant.Status.DeletionScheduledTimestamp = metav1.NewTime(time.Now().Add(r.DeletionGracePeriod))
+		logger.Info("Tenant deletion has been scheduled", "deletionScheduledTimestamp", tenant.Status.DeletionScheduledTimestamp)
+	}
+	deleteAt := tenant.Status.DeletionScheduledTimestamp.Time
+
+	meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+		Type:               "PendingDeletion",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tenant.Generation,
+		Reason:             "DeletionRequested",
+		Message:            "Tenant is deleted at " + deleteAt.UTC().Format(time.RFC3339) + " unless the deletion is cancelled",
+	})
+
+	if time.Now().Before(deleteAt) {
+		return deleteAt, nil
+	}
+
+	ref, err := r.exportTenant(ctx, tenant)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tenant.Status.ExportRef = ref
+
+	return deleteAt, nil
+}
+
+// exportTenant stores the data export of the tenant in a Secret of the operator namespace. An existing export
+// is kept, so the export reflects the tenant as it was when the grace period ended.
+func (r *TenantReconciler) exportTenant(ctx context.Context, tenant *productv1.Tenant) (*corev1.ObjectReference, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        tenant.Name + "-export",
+			Namespace:   r.Namespace,
+			Labels:      map[string]string{tenancy.TenantLabel: tenant.Name},
+			Annotations: r.retentionAnnotations(),
+		},
+	}
+	ref := &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  secret.Namespace,
+		Name:       secret.Name,
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(&secret), &corev1.Secret{}); err == nil {
+		return ref, nil
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	doc, err := export.Collect(ctx, r.Client, tenant)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := export.Gzip(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	secret.Data = map[string][]byte{
+		"export.json.gz": archive,
+	}
+	if err := r.Create(ctx, &secret); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+	} else {
+		logger.Info("Secret has been created", "secretName", secret.Name)
+	}
+
+	return ref, nil
+}
+
+// windDownTenant takes the data export if there is none yet, revokes the registry tokens and the licences,
+// cancels the orders which have not been paid and confirms the deletion to the owners, in this order.
+func (r *TenantReconciler) windDownTenant(ctx context.Context, tenant *productv1.Tenant) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	namespace, err := r.tenantNamespace(ctx, tenant)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if tenant.Status.ExportRef == nil && namespace.Status.Phase != corev1.NamespaceTerminating {
+		if _, err := r.exportTenant(ctx, tenant); err != nil {
+			return err
+		}
+	}
+
+	tokens := productv1.RegistryTokenList{}
+	if err := r.List(ctx, &tokens, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	for i := range tokens.Items {
+		if err := r.Delete(ctx, &tokens.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("RegistryToken has been revoked", "registryTokenName", tokens.Items[i].Name)
+	}
+
+	licences := productv1.LicenceList{}
+	if err := r.List(ctx, &licences, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	for i := range licences.Items {
+		if err := r.Delete(ctx, &licences.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Licence has been revoked", "licenceName", licences.Items[i].Name)
+	}
+
+	orders := productv1.OrderList{}
+	if err := r.List(ctx, &orders, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	paid, err := r.paidPayments(ctx, namespace.Name)
+	if err != nil {
+		return err
+	}
+	for i := range orders.Items {
+		if isOrderPaid(&orders.Items[i], paid) {
+			continue
+		}
+
+		if err := r.Delete(ctx, &orders.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Order has been cancelled", "orderName", orders.Items[i].Name)
+	}
+
+	return r.confirmDeletion(ctx, tenant)
+}
+
+// confirmDeletion sends the owners of the tenant an Email about the deletion. The Emails are kept in the
+// operator namespace, the tenant namespace is about to go away.
+func (r *TenantReconciler) confirmDeletion(ctx context.Context, tenant *productv1.Tenant) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	emailTemplate := productv1.EmailTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      "example-webshop-service-tenant-deletion",
+		Namespace: r.Namespace,
+	}, &emailTemplate); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("EmailTemplate not found, skipping confirmation", "emailTemplateName", "example-webshop-service-tenant-deletion")
+			return nil
+		}
+
+		return err
+	}
+
+	renderer, err := template.New("tenant_deletion_template").Parse(emailTemplate.Spec.Body)
+	if err != nil {
+		return err
+	}
+
+	users := productv1.UserList{}
+	if err := r.List(ctx, &users, client.InNamespace(tenant.Status.Namespace)); err != nil {
+		return err
+	}
+	for i := range users.Items {
+		user := &users.Items[i]
+		if user.Spec.Role != "Owner" {
+			continue
+		}
+
+		userMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(user)
+		if err != nil {
+			return err
+		}
+		userMap["companyName"] = tenant.Spec.CompanyName
+
+		var renderedBody bytes.Buffer
+		if err := renderer.Execute(&renderedBody, userMap); err != nil {
+			return err
+		}
+
+		email := productv1.Email{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        tenant.Name + "-deleted-" + user.Name,
+				Namespace:   r.Namespace,
+				Labels:      map[string]string{tenancy.TenantLabel: tenant.Name},
+				Annotations: r.retentionAnnotations(),
+			},
+			Spec: productv1.EmailSpec{
+				ToAddress:   user.Spec.Email,
+				FromName:    emailTemplate.Spec.FromName,
+				FromAddress: emailTemplate.Spec.FromAddress,
+				Subject:     emailTemplate.Spec.Subject,
+				Body:        renderedBody.String(),
+			},
+		}
+		if err := r.Create(ctx, &email); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+		} else {
+			logger.Info("Email has been created", "emailName", email.Name)
+		}
+	}
+
+	return nil
+}
+
+// retentionAnnotations returns the annotations stamping the end of the retention period on the objects
+// outliving the tenant, nil if they are kept forever.
+func (r *TenantReconciler) retentionAnnotations() map[string]string {
+	if r.Retention <= 0 {
+		return nil
+	}
+
+	return map[string]string{
+		tenantRetainUntilAnnotation: time.Now().Add(r.Retention).UTC().Format(time.RFC3339),
+	}
+}
+
+// cleanupRetained deletes the data exports and deletion confirmations whose retention period has passed.
+// The tenants are gone by then, so nothing triggers a reconcile for them.
+func (r *TenantReconciler) cleanupRetained(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant")
+
+	secrets := corev1.SecretList{}
+	if err := r.List(ctx, &secrets, client.InNamespace(r.Namespace), client.HasLabels{tenancy.TenantLabel}); err != nil {
+		return err
+	}
+	emails := productv1.EmailList{}
+	if err := r.List(ctx, &emails, client.InNamespace(r.Namespace), client.HasLabels{tenancy.TenantLabel}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range secrets.Items {
+		if !retentionPassed(&secrets.Items[i], now) {
+			continue
+		}
+
+		if err := r.Delete(ctx, &secrets.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Secret has been deleted after the retention period", "secretName", secrets.Items[i].Name)
+	}
+	for i := range emails.Items {
+		if !retentionPassed(&emails.Items[i], now) {
+			continue
+		}
+
+		if err := r.Delete(ctx, &emails.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Email has been deleted after the retention period", "emailName", emails.Items[i].Name)
+	}
+
+	return nil
+}
+
+// retentionPassed reports whether the retention period stamped on the object is over, objects without one are kept.
+func retentionPassed(object client.Object, now time.Time) bool {
+	retainUntil, err := time.Parse(time.RFC3339, object.GetAnnotations()[tenantRetainUntilAnnotation])
+	return err == nil && !now.Before(retainUntil)
+}
+
+// applyGuardrails creates or updates the ResourceQuota, LimitRange and default-deny NetworkPolicy of the tenant
+// namespace from the TenantProfile of the tenant. It returns the name of the applied profile.
+func (r *TenantReconciler) applyGuardrails(ctx context.Context, tenant *productv1.Tenant) (string, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	profileName := ""
+	spec := defaultTenantProfile
+	for _, name := range r.profileNames(tenant) {
+		profile := productv1.TenantProfile{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &profile); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return "", err
+			}
+
+			continue
+		}
+
+		profileName = name
+		spec = profile.Spec
+		break
+	}
+	if profileName == "" {
+		logger.Info("TenantProfile not found, applying the built-in defaults", "tenantProfileNames", r.profileNames(tenant))
+	}
+
+	namespace, err := r.tenantNamespace(ctx, tenant)
+	if err != nil {
+		return "", err
+	} else if namespace.Status.Phase == corev1.NamespaceTerminating {
+		return profileName, nil
+	}
+
+	quota := corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tenantQuotaName,
+			Namespace: namespace.Name,
+		},
+	}
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, &quota, func() error {
+		quota.Spec = corev1.ResourceQuotaSpec{
+			Hard: spec.Hard,
+		}
+		return controllerutil.SetControllerReference(tenant, &quota, r.Scheme)
+	}); err != nil {
+		return "", err
+	} else if result != controllerutil.OperationResultNone {
+		logger.Info("ResourceQuota has been "+string(result), "resourceQuotaName", quota.Name)
+	}
+
+	limitRange := corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tenantLimitRangeName,
+			Namespace: namespace.Name,
+		},
+	}
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, &limitRange, func() error {
+		limitRange.Spec = corev1.LimitRangeSpec{
+			Limits: spec.Limits,
+		}
+		return controllerutil.SetControllerReference(tenant, &limitRange, r.Scheme)
+	}); err != nil {
+		return "", err
+	} else if result != controllerutil.OperationResultNone {
+		logger.Info("LimitRange has been "+string(result), "limitRangeName", limitRange.Name)
+	}
+
+	// No rules denies all traffic of the pods in the namespace, in both directions.
+	networkPolicy := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tenantNetworkPolicyName,
+			Namespace: namespace.Name,
+		},
+	}
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, &networkPolicy, func() error {
+		networkPolicy.Spec = networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		}
+		return controllerutil.SetControllerReference(tenant, &networkPolicy, r.Scheme)
+	}); err != nil {
+		return "", err
+	} else if result != controllerutil.OperationResultNone {
+		logger.Info("NetworkPolicy has been "+string(result), "networkPolicyName", networkPolicy.Name)
+	}
+
+	return profileName, nil
+}
+
+// profileNames returns the names of the TenantProfiles of the tenant in order of preference: its own profile,
+// or the one named after its plan, like "pro", followed by the default profile.
+func (r *TenantReconciler) profileNames(tenant *productv1.Tenant) []string {
+	if tenant.Spec.Profile != "" {
+		return []string{tenant.Spec.Profile}
+	}
+
+	names := []string{strings.ToLower(plan.Of(tenant.Spec.Plan))}
+	if r.DefaultProfile != "" {
+		names = append(names, r.DefaultProfile)
+	}
+
+	return names
+}
+
+// tenantsOfProfile maps a TenantProfile to the Tenants it guards.
+func (r *TenantReconciler) tenantsOfProfile(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenants := productv1.TenantList{}
+	if err := r.List(ctx, &tenants); err != nil {
+		logf.FromContext(ctx).Error(err, "Tenant list failed", "tenantProfileName", obj.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for i := range tenants.Items {
+		if slices.Contains(r.profileNames(&tenants.Items[i]), obj.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: tenants.Items[i].Name,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
+// paidPayments returns the names of the succeeded Payments of the namespace.
+func (r *TenantReconciler) paidPayments(ctx context.Context, namespace string) (map[string]bool, error) {
+	payments := productv1.PaymentList{}
+	if err := r.List(ctx, &payments, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	paid := map[string]bool{}
+	for _, payment := range payments.Items {
+		if !payment.Status.PaymentTimestamp.IsZero() {
+			paid[payment.Name] = true
+		}
+	}
+
+	return paid, nil
+}
+
+// isOrderPaid reports whether the Payment of the Order has succeeded, every other Order is still open.
+func isOrderPaid(order *productv1.Order, paid map[string]bool) bool {
+	return order.Status.PaymentRef != nil && paid[order.Status.PaymentRef.Name]
+}
+
+// observeTenant fills the status of the tenant with the objects of its namespace.
+func (r *TenantReconciler) observeTenant(ctx context.Context, tenant *productv1.Tenant) error {
+	tenant.Status.TenantRefs = nil
+	tenant.Status.Users = 0
+	tenant.Status.ActiveLicences = 0
+	tenant.Status.OpenOrders = 0
+
+	namespace, err := r.tenantNamespace(ctx, tenant)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		// A namespace created a moment ago may not be in the cache yet.
+		if meta.FindStatusCondition(tenant.Status.Conditions, "Ready") == nil {
+			setTenantConditions(tenant, metav1.ConditionFalse, "Provisioning", "Namespace of the tenant is being created")
+			return nil
+		}
+
+		setTenantConditions(tenant, metav1.ConditionTrue, "NamespaceNotFound", "Namespace of the tenant does not exist")
+		return nil
+	}
+	tenant.Status.TenantRefs = append(tenant.Status.TenantRefs, corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       namespace.Name,
+		UID:        namespace.UID,
+	})
+
+	users := productv1.UserList{}
+	if err := r.List(ctx, &users, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	owners := 0
+	for _, user := range users.Items {
+		tenant.Status.TenantRefs = append(tenant.Status.TenantRefs, corev1.ObjectReference{
+			APIVersion: productv1.GroupVersion.String(),
+			Kind:       "User",
+			Namespace:  user.Namespace,
+			Name:       user.Name,
+			UID:        user.UID,
+		})
+		if user.Spec.Role == "Owner" {
+			owners++
+		}
+	}
+	slices.SortFunc(tenant.Status.TenantRefs[1:], func(a, b corev1.ObjectReference) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	tenant.Status.Users = int32(len(users.Items))
+
+	licences := productv1.LicenceList{}
+	if err := r.List(ctx, &licences, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	for _, licence := range licences.Items {
+		if licence.Spec.ExpireTimestamp.IsZero() || licence.Spec.ExpireTimestamp.After(time.Now()) {
+			tenant.Status.ActiveLicences++
+		}
+	}
+
+	orders := productv1.OrderList{}
+	if err := r.List(ctx, &orders, client.InNamespace(namespace.Name)); err != nil {
+		return err
+	}
+	paid, err := r.paidPayments(ctx, namespace.Name)
+	if err != nil {
+		return err
+	}
+	for i := range orders.Items {
+		if !isOrderPaid(&orders.Items[i], paid) {
+			tenant.Status.OpenOrders++
+		}
+	}
+
+	switch {
+	case namespace.Status.Phase == corev1.NamespaceTerminating:
+		setTenantConditions(tenant, metav1.ConditionFalse, "NamespaceTerminating", "Namespace of the tenant is being deleted")
+	case len(users.Items) == 0:
+		setTenantConditions(tenant, metav1.ConditionFalse, "NoUsers", "Tenant has no users yet")
+	case owners == 0:
+		setTenantConditions(tenant, metav1.ConditionTrue, "NoOwner", "Tenant has no user with the Owner role")
+	default:
+		meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: tenant.Generation,
+			Reason:             "Provisioned",
+			Message:            "Namespace and users of the tenant are in place",
+		})
+		meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+			Type:               "Degraded",
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: tenant.Generation,
+			Reason:             "Provisioned",
+			Message:            "Namespace and users of the tenant are in place",
+		})
+	}
+
+	return nil
+}
+
+// setTenantConditions marks the tenant not ready, degraded tells apart broken tenants from the ones still being set up.
+func setTenantConditions(tenant *productv1.Tenant, degraded metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: tenant.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+		Type:               "Degraded",
+		Status:             degraded,
+		ObservedGeneration: tenant.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// suspendLicences marks the Licences of the tenant namespace suspended while the tenant is suspended,
+// and lifts the mark once the tenant is reactivated.
+func (r *TenantReconciler) suspendLicences(ctx context.Context, tenant *productv1.Tenant) error {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	licences := productv1.LicenceList{}
+	if err := r.List(ctx, &licences, client.InNamespace(tenant.Status.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range licences.Items {
+		licence := &licences.Items[i]
+		if licence.Status.Suspended == tenant.Spec.Suspended {
+			continue
+		}
+
+		patchedLicence := licence.DeepCopy()
+		patchedLicence.Status.Suspended = tenant.Spec.Suspended
+		patchedLicence.Status.SuspendedTimestamp = metav1.Time{}
+		if tenant.Spec.Suspended {
+			patchedLicence.Status.SuspendedTimestamp = metav1.Now()
+		}
+		if err := r.Status().Patch(ctx, patchedLicence, client.MergeFrom(licence)); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return err
+		}
+
+		if tenant.Spec.Suspended {
+			logger.Info("Licence has been suspended", "licenceName", licence.Name)
+		} else {
+			logger.Info("Licence has been reactivated", "licenceName", licence.Name)
+		}
+	}
+
+	return nil
+}
+
+// setSuspendedCondition records the suspension of the tenant on its status.
+func setSuspendedCondition(tenant *productv1.Tenant) {
+	if !tenant.Spec.Suspended {
+		tenant.Status.SuspendedTimestamp = metav1.Time{}
+		meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+			Type:               "Suspended",
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: tenant.Generation,
+			Reason:             "Active",
+			Message:            "Tenant is active",
+		})
+		return
+	}
+
+	if tenant.Status.SuspendedTimestamp.IsZero() {
+		tenant.Status.SuspendedTimestamp = metav1.Now()
+	}
+	message := "Tenant is suspended"
+	if tenant.Spec.SuspensionReason != "" {
+		message += ": " + tenant.Spec.SuspensionReason
+	}
+	meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+		Type:               "Suspended",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tenant.Generation,
+		Reason:             "Suspended",
+		Message:            message,
+	})
+}
+
+// namespaceOf returns the name of the namespace of the tenant, empty if it has none yet. Namespaces of tenants
+// created before the naming strategies are named after the tenant, they are labelled on their next reconciliation.
+func (r *TenantReconciler) namespaceOf(ctx context.Context, tenant *productv1.Tenant) (string, error) {
+	if name, err := tenancy.NamespaceOf(ctx, r.Client, tenant); err != nil || name != "" {
+		return name, err
+	}
+
+	namespace := corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: tenant.Name}, &namespace); err != nil {
+		return "", client.IgnoreNotFound(err)
+	} else if !tenancy.Owns(tenant, &namespace) {
+		return "", nil
+	}
+
+	return namespace.Name, nil
+}
+
+// tenantNamespace fetches the namespace recorded on the status of the tenant.
+func (r *TenantReconciler) tenantNamespace(ctx context.Context, tenant *productv1.Tenant) (*corev1.Namespace, error) {
+	if tenant.Status.Namespace == "" {
+		return nil, apierrors.NewNotFound(corev1.Resource("namespaces"), "")
+	}
+
+	namespace := corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: tenant.Status.Namespace}, &namespace); err != nil {
+		return nil, err
+	}
+
+	return &namespace, nil
+}
+
+// createNamespace creates the namespace of the tenant under the first name of the naming strategy which is not
+// taken by another tenant, and returns its name.
+func (r *TenantReconciler) createNamespace(ctx context.Context, tenant *productv1.Tenant) (string, error) {
+	logger := logf.FromContext(ctx).WithValues("controller", "tenant", "name", tenant.Name)
+
+	var naming tenancy.Naming = &tenancy.NameNaming{}
+	if r.Naming != nil {
+		naming = r.Naming
+	}
+
+	for _, name := range naming.Candidates(tenant) {
+		existing := corev1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, &existing); err == nil {
+			if tenancy.Owns(tenant, &existing) {
+				return existing.Name, nil
+			}
+
+			logger.Info("Namespace name is taken", "namespaceName", name)
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+
+		labels, annotations := namespaceMetadata(tenant)
 		namespace := corev1.Namespace{
 			ObjectMeta: metav1.ObjectMeta{
-				Name: tenant.Name,
+				Name:        name,
+				Labels:      labels,
+				Annotations: annotations,
 				OwnerReferences: []metav1.OwnerReference{
 					{
 						APIVersion:         tenant.APIVersion,
@@ -125,40 +965,111 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				},
 			},
 		}
+		// A namespace missing from the cache may exist already, the next reconciliation tells whose it is.
 		if err := r.Create(ctx, &namespace); err != nil {
-			if !apierrors.IsAlreadyExists(err) {
-				logger.Error(err, "Namespace creation failed")
-				return ctrl.Result{}, err
-			}
-		} else {
-			logger.Info("Namespace has been created", "namespaceName", namespace.Name)
-		}
-	} else {
-		if tenant.Status.LastGeneration == tenant.Generation {
-			return ctrl.Result{}, nil
+			return "", err
 		}
+		logger.Info("Namespace has been created", "namespaceName", namespace.Name)
 
-		logger.Info("User updated")
+		return namespace.Name, nil
 	}
 
-	patchedTenant := tenant.DeepCopy()
-	patchedTenant.Status.LastGeneration = tenant.Generation
-	if err := r.Status().Patch(ctx, patchedTenant, client.MergeFrom(&tenant)); err != nil {
-		if apierrors.IsNotFound(err) {
-			return ctrl.Result{}, nil
-		}
+	return "", fmt.Errorf("every namespace name of tenant %s is taken", tenant.Name)
+}
 
-		logger.Error(err, "Tenant status update failed")
-		return ctrl.Result{}, err
+// labelNamespace keeps the labels and annotations describing the tenant on its namespace up to date.
+func (r *TenantReconciler) labelNamespace(ctx context.Context, tenant *productv1.Tenant) error {
+	namespace, err := r.tenantNamespace(ctx, tenant)
+	if err != nil {
+		return err
 	}
 
-	return ctrl.Result{}, nil
+	labels, annotations := namespaceMetadata(tenant)
+	patchedNamespace := namespace.DeepCopy()
+	if patchedNamespace.Labels == nil {
+		patchedNamespace.Labels = map[string]string{}
+	}
+	for key, value := range labels {
+		patchedNamespace.Labels[key] = value
+	}
+	if patchedNamespace.Annotations == nil {
+		patchedNamespace.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		patchedNamespace.Annotations[key] = value
+	}
+	if equality.Semantic.DeepEqual(patchedNamespace.ObjectMeta, namespace.ObjectMeta) {
+		return nil
+	}
+
+	if err := r.Patch(ctx, patchedNamespace, client.MergeFrom(namespace)); err != nil {
+		return err
+	}
+	logf.FromContext(ctx).Info("Namespace has been labelled", "controller", "tenant", "name", tenant.Name, "namespaceName", namespace.Name)
+
+	return nil
+}
+
+// namespaceMetadata returns the labels and annotations describing the tenant on its namespace.
+func namespaceMetadata(tenant *productv1.Tenant) (map[string]string, map[string]string) {
+	labels := map[string]string{
+		tenancy.TenantLabel:    tenant.Name,
+		tenancy.PlanLabel:      plan.Of(tenant.Spec.Plan),
+		tenancy.CountryLabel:   tenant.Spec.Country,
+		tenancy.ManagedByLabel: tenancy.ManagedBy,
+	}
+	annotations := map[string]string{
+		tenancy.CompanyNameAnnotation: tenant.Spec.CompanyName,
+	}
+
+	return labels, annotations
+}
+
+// tenantOfNamespace maps the objects inside a tenant namespace to the Tenant labelled on the namespace.
+func (r *TenantReconciler) tenantOfNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenant, err := tenancy.TenantOf(ctx, r.Client, obj.GetNamespace())
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logf.FromContext(ctx).Error(err, "Tenant lookup failed", "namespaceName", obj.GetNamespace())
+		}
+		return nil
+	}
+
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name: tenant.Name,
+			},
+		},
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Retention > 0 {
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				if err := r.cleanupRetained(ctx); err != nil {
+					logf.FromContext(ctx).Error(err, "Retained object cleanup failed", "controller", "tenant")
+				}
+			}, tenantCleanupInterval)
+			return nil
+		})); err != nil {
+			return err
+		}
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&productv1.Tenant{}).
+		Owns(&corev1.ResourceQuota{}).
+		Owns(&corev1.LimitRange{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &productv1.Tenant{})).
+		Watches(&productv1.TenantProfile{}, handler.EnqueueRequestsFromMapFunc(r.tenantsOfProfile)).
+		Watches(&productv1.User{}, handler.EnqueueRequestsFromMapFunc(r.tenantOfNamespace)).
+		Watches(&productv1.Licence{}, handler.EnqueueRequestsFromMapFunc(r.tenantOfNamespace)).
+		Watches(&productv1.Order{}, handler.EnqueueRequestsFromMapFunc(r.tenantOfNamespace)).
+		Watches(&productv1.Payment{}, handler.EnqueueRequestsFromMapFunc(r.tenantOfNamespace)).
 		Named("tenant").
 		Complete(r)
 }
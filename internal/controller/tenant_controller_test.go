@@ -18,16 +18,22 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
 var _ = Describe("Tenant Controller", func() {
@@ -71,7 +77,7 @@ var _ = Describe("Tenant Controller", func() {
 			By("Cleanup the specific resource instance Tenant")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 		})
-		It("should successfully reconcile the resource", func() {
+		It("should reflect the namespace of the tenant in the status", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &TenantReconciler{
 				Client: k8sClient,
@@ -82,8 +88,207 @@ var _ = Describe("Tenant Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the status reflects the namespace of the tenant")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			Expect(tenant.Status.TenantRefs).NotTo(BeEmpty())
+			Expect(tenant.Status.TenantRefs[0].Kind).To(Equal("Namespace"))
+			Expect(tenant.Status.TenantRefs[0].Name).To(Equal(resourceName))
+			Expect(tenant.Status.Users).To(BeZero())
+			Expect(tenant.Status.Namespace).To(Equal(resourceName))
+
+			ready := meta.FindStatusCondition(tenant.Status.Conditions, "Ready")
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("NoUsers"))
+		})
+		It("should guard the namespace of the tenant with a quota and a network policy", func() {
+			controllerReconciler := &TenantReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the namespace of the tenant is guarded")
+			quota := &corev1.ResourceQuota{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "tenant-quota", Namespace: resourceName}, quota)).To(Succeed())
+			Expect(quota.Spec.Hard).To(HaveKey(corev1.ResourceName("count/orders.product.webshop.harikube.info")))
+			policy := &networkingv1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default-deny", Namespace: resourceName}, policy)).To(Succeed())
+		})
+		It("should label the namespace of the tenant", func() {
+			controllerReconciler := &TenantReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the namespace of the tenant is labelled")
+			namespace := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName}, namespace)).To(Succeed())
+			Expect(namespace.Labels).To(HaveKeyWithValue(tenancy.TenantLabel, resourceName))
+			Expect(namespace.Labels).To(HaveKeyWithValue(tenancy.PlanLabel, "Free"))
+			Expect(namespace.Labels).To(HaveKeyWithValue(tenancy.CountryLabel, "HU"))
+		})
+		It("should count the orders which have not been paid as open", func() {
+			controllerReconciler := &TenantReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Provisioning the namespace of the tenant")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Placing a paid and an unpaid order")
+			payment := &productv1.Payment{
+				ObjectMeta: metav1.ObjectMeta{Name: "paid", Namespace: resourceName},
+				Spec:       productv1.PaymentSpec{Price: 100},
+			}
+			Expect(k8sClient.Create(ctx, payment)).To(Succeed())
+			payment.Status.PaymentTimestamp = metav1.Now()
+			Expect(k8sClient.Status().Update(ctx, payment)).To(Succeed())
+
+			for _, name := range []string{"paid", "unpaid"} {
+				order := &productv1.Order{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: resourceName},
+					Spec: productv1.OrderSpec{
+						User: productv1.UserSpec{
+							FirstName: "First",
+							LastName:  "Last",
+							Email:     "email@harikube.info",
+						},
+						Products: []productv1.OrderProduct{{
+							Product:  productv1.ProductSpec{DisplayName: "Sample Product", Price: 100},
+							Quantity: 1,
+						}},
+						OrderTimestamp: metav1.Now(),
+					},
+				}
+				Expect(k8sClient.Create(ctx, order)).To(Succeed())
+				order.Status.PaymentRef = &corev1.LocalObjectReference{Name: "paid"}
+				if name == "paid" {
+					Expect(k8sClient.Status().Update(ctx, order)).To(Succeed())
+				}
+			}
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			Expect(tenant.Status.OpenOrders).To(Equal(int32(1)))
+		})
+		It("should schedule and cancel a requested deletion", func() {
+			controllerReconciler := &TenantReconciler{
+				Client:              k8sClient,
+				Scheme:              k8sClient.Scheme(),
+				DeletionGracePeriod: time.Hour,
+			}
+
+			By("Requesting the deletion of the tenant")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			tenant.Spec.DeletionRequested = true
+			Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			Expect(tenant.Status.DeletionScheduledTimestamp.IsZero()).To(BeFalse())
+			Expect(meta.IsStatusConditionTrue(tenant.Status.Conditions, "PendingDeletion")).To(BeTrue())
+
+			By("Cancelling the deletion within the grace period")
+			tenant.Spec.DeletionRequested = false
+			Expect(k8sClient.Update(ctx, tenant)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			Expect(tenant.DeletionTimestamp).To(BeNil())
+			Expect(tenant.Status.DeletionScheduledTimestamp.IsZero()).To(BeTrue())
+			Expect(meta.FindStatusCondition(tenant.Status.Conditions, "PendingDeletion")).To(BeNil())
+		})
+		It("should delete the data export and the deletion confirmation after the retention period", func() {
+			controllerReconciler := &TenantReconciler{
+				Client:    k8sClient,
+				Scheme:    k8sClient.Scheme(),
+				Namespace: "default",
+				Retention: time.Hour,
+			}
+
+			By("Confirming the deletion to the owner")
+			owner := &productv1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default"},
+				Spec: productv1.UserSpec{
+					FirstName: "First",
+					LastName:  "Last",
+					Email:     "owner@harikube.info",
+					Role:      "Owner",
+				},
+			}
+			Expect(k8sClient.Create(ctx, owner)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, owner)
+			emailTemplate := &productv1.EmailTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "example-webshop-service-tenant-deletion", Namespace: "default"},
+				Spec: productv1.EmailTemplateSpec{
+					DisplayName: "Tenant Deletion",
+					FromName:    "HariKube",
+					FromAddress: "info@harikube.info",
+					Subject:     "Your tenant has been deleted",
+					Body:        "{{ .companyName }}",
+				},
+			}
+			Expect(k8sClient.Create(ctx, emailTemplate)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, emailTemplate)
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, tenant)).To(Succeed())
+			tenant.Status.Namespace = "default"
+			ref, err := controllerReconciler.exportTenant(ctx, tenant)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(controllerReconciler.confirmDeletion(ctx, tenant)).To(Succeed())
+
+			export := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, export)).To(Succeed())
+			email := &productv1.Email{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-deleted-owner", Namespace: "default"}, email)).To(Succeed())
+			for _, object := range []client.Object{export, email} {
+				retainUntil, err := time.Parse(time.RFC3339, object.GetAnnotations()[tenantRetainUntilAnnotation])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(retainUntil).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+			}
+
+			By("Keeping them within the retention period")
+			Expect(controllerReconciler.cleanupRetained(ctx)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(export), &corev1.Secret{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(email), &productv1.Email{})).To(Succeed())
+
+			By("Deleting them once the retention period has passed")
+			passed := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+			for _, object := range []client.Object{export, email} {
+				object.GetAnnotations()[tenantRetainUntilAnnotation] = passed
+				Expect(k8sClient.Update(ctx, object)).To(Succeed())
+			}
+			Expect(controllerReconciler.cleanupRetained(ctx)).To(Succeed())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(export), &corev1.Secret{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(email), &productv1.Email{}))).To(BeTrue())
 		})
 	})
 })
@@ -18,6 +18,8 @@ package controller
 
 import (
 	"context"
+	"slices"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	authorizationv1 "k8s.io/api/rbac/v1"
@@ -26,10 +28,60 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+var (
+	readVerbs   = []string{"get", "list", "watch"}
+	manageVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+	// tenantRoleVerbs maps the roles of users in a tenant to the verbs they get on the resources of the tenant namespace.
+	tenantRoleVerbs = map[string]map[string][]string{
+		"Owner": {
+			"orders":              {"get", "list", "watch", "create"},
+			"registrytokens":      {"get", "list", "watch", "create", "delete"},
+			"licences":            readVerbs,
+			"licences/activate":   {"create"},
+			"licences/deactivate": {"create"},
+			"payments":            readVerbs,
+			"invitations":         manageVerbs,
+			"users":               {"get", "list", "watch", "update", "patch", "delete"},
+			"auditevents":         readVerbs,
+		},
+		"Admin": {
+			"orders":              {"get", "list", "watch", "create"},
+			"registrytokens":      {"get", "list", "watch", "create", "delete"},
+			"licences":            readVerbs,
+			"licences/activate":   {"create"},
+			"licences/deactivate": {"create"},
+			"payments":            readVerbs,
+			"invitations":         manageVerbs,
+			"users":               readVerbs,
+			"auditevents":         readVerbs,
+		},
+		"Billing": {
+			"orders":   {"get", "list", "watch", "create"},
+			"licences": readVerbs,
+			"payments": readVerbs,
+		},
+		"Member": {
+			"orders":              readVerbs,
+			"registrytokens":      {"get", "list", "watch", "create", "delete"},
+			"licences":            readVerbs,
+			"licences/activate":   {"create"},
+			"licences/deactivate": {"create"},
+		},
+	}
 )
 
 // UserReconciler reconciles a User object
@@ -41,6 +93,9 @@ type UserReconciler struct {
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users;emails,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users/status;emails/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=users/finalizers,verbs=update
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=tenants/export,verbs=get
+// +kubebuilder:rbac:groups=product.webshop.harikube.info,resources=licences/activate;licences/deactivate,verbs=create
 
 // +kubebuilder:rbac:groups="",resources=secrets;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="rbac.authorization.k8s.io",resources=roles;clusterroles;rolebindings;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
@@ -75,22 +130,59 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, nil
 	}
 
+	// Users of a suspended tenant keep read access only.
+	tenant, err := tenancy.TenantOf(ctx, r.Client, user.Namespace)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Tenant fetch failed", "namespaceName", user.Namespace)
+			return ctrl.Result{}, err
+		}
+		tenant = &productv1.Tenant{}
+	}
+	suspended := tenant.Spec.Suspended
+	limits := plan.LimitsOf(tenant.Spec.Plan)
+
+	// Users without a known role get the least privileged one, never the access of an owner.
+	verbsByKind := tenantRoleVerbs[user.Spec.Role]
+	if verbsByKind == nil {
+		verbsByKind = tenantRoleVerbs["Member"]
+	}
+
 	rules := []authorizationv1.PolicyRule{}
-	for kind, verbs := range map[string][]string{
-		"orders":         {"get", "list", "watch"},
-		"registrytokens": {"get", "list", "watch", "create", "delete"},
-	} {
+	for kind, verbs := range verbsByKind {
+		// Inviting colleagues is a feature of the paid plans.
+		if kind == "invitations" && !limits.Invitations {
+			continue
+		}
+
+		// Pending users can only look around until they verify their email address.
+		if user.Status.Phase != "Validated" {
+			verbs = slices.DeleteFunc(slices.Clone(verbs), func(verb string) bool {
+				return verb == "create"
+			})
+		}
+		if suspended {
+			verbs = readOnly(verbs)
+		}
+
 		rules = append(rules, authorizationv1.PolicyRule{
 			APIGroups: []string{"product.webshop.harikube.info"},
 			Resources: []string{kind},
 			Verbs:     verbs,
 		})
 	}
+	slices.SortFunc(rules, func(a, b authorizationv1.PolicyRule) int {
+		return strings.Compare(a.Resources[0], b.Resources[0])
+	})
+	selfVerbs := []string{"get", "list", "watch", "update", "patch"}
+	if suspended {
+		selfVerbs = readOnly(selfVerbs)
+	}
 	rules = append(rules, authorizationv1.PolicyRule{
 		APIGroups:     []string{"product.webshop.harikube.info"},
 		Resources:     []string{"users"},
 		ResourceNames: []string{user.Name},
-		Verbs:         []string{"get", "list", "watch", "update", "patch"},
+		Verbs:         selfVerbs,
 	})
 
 	role := authorizationv1.Role{
@@ -133,13 +225,29 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		logger.Info("Role has been created", "roleName", role.Name)
 	}
 
-	clusterRoles := []authorizationv1.PolicyRule{
-		{
+	// The Tenant is found through the label of the namespace, users of a namespace without one get no access to
+	// tenants at all, as a rule without resource names would grant all of them.
+	tenantVerbs := []string{"get", "list", "watch"}
+	if user.Spec.Role == "Owner" && !suspended {
+		tenantVerbs = append(tenantVerbs, "update", "patch")
+	}
+	clusterRoles := []authorizationv1.PolicyRule{}
+	if tenant.Name != "" {
+		clusterRoles = append(clusterRoles, authorizationv1.PolicyRule{
 			APIGroups:     []string{"product.webshop.harikube.info"},
 			Resources:     []string{"tenants"},
-			ResourceNames: []string{user.Name},
-			Verbs:         []string{"get", "list", "watch", "update", "patch"},
-		},
+			ResourceNames: []string{tenant.Name},
+			Verbs:         tenantVerbs,
+		})
+	}
+	// Owners may take the data of the tenant with them, even while it is suspended.
+	if tenant.Name != "" && user.Spec.Role == "Owner" {
+		clusterRoles = append(clusterRoles, authorizationv1.PolicyRule{
+			APIGroups:     []string{"product.webshop.harikube.info"},
+			Resources:     []string{"tenants/export"},
+			ResourceNames: []string{tenant.Name},
+			Verbs:         []string{"get"},
+		})
 	}
 
 	clusterRole := authorizationv1.ClusterRole{
@@ -163,8 +271,7 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 
 		if err := r.Get(ctx, types.NamespacedName{
-			Name:      role.Name,
-			Namespace: role.Namespace,
+			Name: clusterRole.Name,
 		}, &clusterRole); err != nil {
 			logger.Error(err, "ClusterRole fetch failed", "clusterRoleName", clusterRole.Name)
 			return ctrl.Result{}, err
@@ -181,6 +288,16 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		logger.Info("ClusterRole has been created", "clusterRoleName", clusterRole.Name)
 	}
 
+	handover := corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      password.HandoverSecretName(user.Name),
+		Namespace: user.Namespace,
+	}, &handover); err != nil && !apierrors.IsNotFound(err) {
+		logger.Error(err, "Secret fetch failed", "secretName", password.HandoverSecretName(user.Name))
+		return ctrl.Result{}, err
+	}
+	handoverPending := isOwnedBy(&handover, user.UID) && len(handover.Data["hash"]) > 0
+
 	if user.Generation == 1 && user.Status.LastGeneration == 0 {
 		logger.Info("User created")
 
@@ -276,7 +393,7 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			logger.Info("ServiceAccount has been created", "serviceAccountName", serviceAccount.Name)
 		}
 	} else {
-		if user.Status.LastGeneration == user.Generation {
+		if user.Status.LastGeneration == user.Generation && !handoverPending {
 			return ctrl.Result{}, nil
 		}
 
@@ -285,7 +402,7 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	patchedUser := user.DeepCopy()
 
-	if hash, ok := user.Annotations["product.webshop.harikube.info/password"]; ok {
+	if handoverPending {
 		password := corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      string(user.UID),
@@ -299,8 +416,8 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 					},
 				},
 			},
-			StringData: map[string]string{
-				"hash": hash,
+			Data: map[string][]byte{
+				"hash": handover.Data["hash"],
 			},
 		}
 		if err := r.Create(ctx, &password); err != nil {
@@ -317,8 +434,8 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 				return ctrl.Result{}, err
 			}
 
-			password.StringData = map[string]string{
-				"hash": hash,
+			password.Data = map[string][]byte{
+				"hash": handover.Data["hash"],
 			}
 			if err := r.Update(ctx, &password); err != nil {
 				logger.Error(err, "Secret update failed", "secretName", password.Name)
@@ -330,22 +447,24 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			logger.Info("Secret has been created", "secretName", password.Name)
 		}
 
-		delete(user.Annotations, "product.webshop.harikube.info/password")
-		if err := r.Update(ctx, &user); err != nil {
-			if apierrors.IsNotFound(err) {
-				return ctrl.Result{}, nil
-			}
-
-			logger.Error(err, "User update failed")
+		if err := r.Delete(ctx, &handover); err != nil && !apierrors.IsNotFound(err) {
+			logger.Error(err, "Secret deletion failed", "secretName", handover.Name)
 			return ctrl.Result{}, err
 		}
+		logger.Info("Secret has been deleted", "secretName", handover.Name)
 
-		patchedUser = user.DeepCopy()
 		patchedUser.Status.PasswordRef = &corev1.LocalObjectReference{
 			Name: password.Name,
 		}
 	}
 
+	// A verification only holds for the number it was sent to.
+	if user.Status.VerifiedPhoneNumber != "" && (user.Spec.PhoneNumber == nil || *user.Spec.PhoneNumber != user.Status.VerifiedPhoneNumber) {
+		patchedUser.Status.VerifiedPhoneNumber = ""
+		patchedUser.Status.PhoneNumberVerifiedTimestamp = metav1.Time{}
+		logger.Info("Phone number verification has been revoked")
+	}
+
 	patchedUser.Status.LastGeneration = user.Generation
 	if err := r.Status().Patch(ctx, patchedUser, client.MergeFrom(&user)); err != nil {
 		if apierrors.IsNotFound(err) {
@@ -359,10 +478,58 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	return ctrl.Result{}, nil
 }
 
+// readOnly keeps the verbs which do not modify anything.
+func readOnly(verbs []string) []string {
+	return slices.DeleteFunc(slices.Clone(verbs), func(verb string) bool {
+		return !slices.Contains(readVerbs, verb)
+	})
+}
+
+// usersOfTenant maps a Tenant to the Users of its namespace.
+func (r *UserReconciler) usersOfTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenant, ok := obj.(*productv1.Tenant)
+	if !ok || tenant.Status.Namespace == "" {
+		return nil
+	}
+
+	users := productv1.UserList{}
+	if err := r.List(ctx, &users, client.InNamespace(tenant.Status.Namespace)); err != nil {
+		logf.FromContext(ctx).Error(err, "User list failed", "tenantName", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(users.Items))
+	for _, user := range users.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      user.Name,
+				Namespace: user.Namespace,
+			},
+		})
+	}
+
+	return requests
+}
+
+// isOwnedBy reports whether the object has an owner reference to the given UID.
+func isOwnedBy(obj metav1.Object, uid types.UID) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&productv1.User{}).
+		Owns(&corev1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return strings.HasSuffix(obj.GetName(), password.HandoverSecretName(""))
+		}))).
+		Watches(&productv1.Tenant{}, handler.EnqueueRequestsFromMapFunc(r.usersOfTenant), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Named("user").
 		Complete(r)
 }
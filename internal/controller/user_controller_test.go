@@ -21,6 +21,8 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	authorizationv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -28,6 +30,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
 var _ = Describe("User Controller", func() {
@@ -84,5 +88,140 @@ var _ = Describe("User Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+		It("should move the handed over password hash into the password Secret", func() {
+			By("Creating the hand-over Secret")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, user)).To(Succeed())
+			handover := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      password.HandoverSecretName(resourceName),
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: productv1.GroupVersion.String(),
+							Kind:       "User",
+							Name:       user.Name,
+							UID:        user.UID,
+						},
+					},
+				},
+				StringData: map[string]string{
+					"hash": "$argon2id$hash",
+				},
+			}
+			Expect(k8sClient.Create(ctx, handover)).To(Succeed())
+
+			controllerReconciler := &UserReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the hash left the hand-over Secret")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, user)).To(Succeed())
+			Expect(user.Annotations).NotTo(HaveKey("product.webshop.harikube.info/password"))
+			Expect(user.Status.PasswordRef).NotTo(BeNil())
+
+			password := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      user.Status.PasswordRef.Name,
+				Namespace: "default",
+			}, password)).To(Succeed())
+			Expect(string(password.Data["hash"])).To(Equal("$argon2id$hash"))
+
+			err = k8sClient.Get(ctx, types.NamespacedName{
+				Name:      handover.Name,
+				Namespace: "default",
+			}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+		It("should give the access of a member to users created without a role", func() {
+			By("Checking the role defaults to the least privileged one")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, user)).To(Succeed())
+			Expect(user.Spec.Role).To(Equal("Member"))
+
+			controllerReconciler := &UserReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the Role of the user grants none of the owner resources")
+			role := &authorizationv1.Role{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      string(user.UID),
+				Namespace: "default",
+			}, role)).To(Succeed())
+			resources := []string{}
+			for _, rule := range role.Rules {
+				resources = append(resources, rule.Resources...)
+			}
+			Expect(resources).NotTo(ContainElements("invitations", "payments", "auditevents"))
+
+			clusterRole := &authorizationv1.ClusterRole{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: string(user.UID)}, clusterRole)).To(Succeed())
+			for _, rule := range clusterRole.Rules {
+				Expect(rule.Resources).NotTo(ContainElement("tenants/export"))
+				Expect(rule.Verbs).To(HaveEach(BeElementOf("get", "list", "watch")))
+			}
+		})
+		It("should leave read access only to the users of a suspended tenant", func() {
+			By("Suspending the tenant of the namespace")
+			tenant := &productv1.Tenant{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-tenant",
+				},
+				Spec: productv1.TenantSpec{
+					Country:    "HU",
+					City:       "Budapest",
+					Address:    "Address",
+					PostalCode: "1111",
+					Suspended:  true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, tenant)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, tenant)).To(Succeed())
+			}()
+
+			By("Labelling the namespace with its tenant")
+			namespace := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, namespace)).To(Succeed())
+			namespace.Labels[tenancy.TenantLabel] = tenant.Name
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, namespace)).To(Succeed())
+				delete(namespace.Labels, tenancy.TenantLabel)
+				Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			}()
+
+			controllerReconciler := &UserReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Checking the Role of the user grants no write verbs")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, user)).To(Succeed())
+			role := &authorizationv1.Role{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      string(user.UID),
+				Namespace: "default",
+			}, role)).To(Succeed())
+			for _, rule := range role.Rules {
+				Expect(rule.Verbs).To(HaveEach(BeElementOf("get", "list", "watch")))
+			}
+		})
 	})
 })
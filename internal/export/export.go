@@ -0,0 +1,310 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package export assembles everything stored for a tenant into a single document, leaving out secret material
+// like password hashes, credentials, licence keys and the bodies of emails, which may carry one-time links.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+// Document is the export of a tenant and the objects of its namespace.
+type Document struct {
+	ExportTimestamp metav1.Time          `json:"exportTimestamp"`
+	Name            string               `json:"name"`
+	Tenant          productv1.TenantSpec `json:"tenant"`
+	Users           []User               `json:"users"`
+	Invitations     []Invitation         `json:"invitations"`
+	Orders          []Order              `json:"orders"`
+	Payments        []Payment            `json:"payments"`
+	Licences        []Licence            `json:"licences"`
+	Emails          []Email              `json:"emails"`
+	RegistryTokens  []RegistryToken      `json:"registryTokens"`
+}
+
+// User is the exported part of a User.
+type User struct {
+	Name                string             `json:"name"`
+	CreationTimestamp   metav1.Time        `json:"creationTimestamp"`
+	Spec                productv1.UserSpec `json:"spec"`
+	Phase               string             `json:"phase,omitempty"`
+	MFAEnabled          bool               `json:"mfaEnabled,omitempty"`
+	VerifiedPhoneNumber string             `json:"verifiedPhoneNumber,omitempty"`
+}
+
+// Invitation is the exported part of an Invitation.
+type Invitation struct {
+	Name              string      `json:"name"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+	Email             string      `json:"email"`
+	Role              string      `json:"role"`
+	Phase             string      `json:"phase,omitempty"`
+}
+
+// Order is the exported part of an Order.
+type Order struct {
+	Name              string              `json:"name"`
+	CreationTimestamp metav1.Time         `json:"creationTimestamp"`
+	Spec              productv1.OrderSpec `json:"spec"`
+	TotalPrice        int64               `json:"totalPrice,omitempty"`
+	Payment           string              `json:"payment,omitempty"`
+	Licences          []string            `json:"licences,omitempty"`
+}
+
+// Payment is the exported part of a Payment.
+type Payment struct {
+	Name              string                `json:"name"`
+	CreationTimestamp metav1.Time           `json:"creationTimestamp"`
+	Spec              productv1.PaymentSpec `json:"spec"`
+	PaymentTimestamp  metav1.Time           `json:"paymentTimestamp,omitempty"`
+}
+
+// Licence is the exported part of a Licence, without the licence key.
+type Licence struct {
+	Name              string                        `json:"name"`
+	CreationTimestamp metav1.Time                   `json:"creationTimestamp"`
+	Spec              productv1.LicenceSpec         `json:"spec"`
+	Activations       []productv1.LicenceActivation `json:"activations,omitempty"`
+	Suspended         bool                          `json:"suspended,omitempty"`
+}
+
+// Email is the exported part of an Email, without the body.
+type Email struct {
+	Name              string      `json:"name"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+	ToAddress         string      `json:"toAddress"`
+	FromName          string      `json:"fromName"`
+	FromAddress       string      `json:"fromAddress"`
+	Subject           string      `json:"subject"`
+	SentTimestamp     metav1.Time `json:"sentTimestamp,omitempty"`
+}
+
+// RegistryToken is the metadata of a RegistryToken, without the credential.
+type RegistryToken struct {
+	Name              string      `json:"name"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+	DisplayName       string      `json:"displayName"`
+	Description       string      `json:"description,omitempty"`
+	Email             string      `json:"email"`
+	ExpireTimestamp   metav1.Time `json:"expireTimestamp"`
+	AutoRotate        bool        `json:"autoRotate,omitempty"`
+	Phase             string      `json:"phase,omitempty"`
+	IssueTimestamp    metav1.Time `json:"issueTimestamp,omitempty"`
+	Rotations         int32       `json:"rotations,omitempty"`
+}
+
+// Collect assembles the export of the tenant from the objects of the namespace recorded on its status.
+func Collect(ctx context.Context, c client.Reader, tenant *productv1.Tenant) (*Document, error) {
+	// An empty namespace would list the objects of every tenant.
+	if tenant.Status.Namespace == "" {
+		return nil, fmt.Errorf("tenant %s has no namespace", tenant.Name)
+	}
+	namespace := client.InNamespace(tenant.Status.Namespace)
+	doc := Document{
+		ExportTimestamp: metav1.NewTime(time.Now()),
+		Name:            tenant.Name,
+		Tenant:          tenant.Spec,
+		Users:           []User{},
+		Invitations:     []Invitation{},
+		Orders:          []Order{},
+		Payments:        []Payment{},
+		Licences:        []Licence{},
+		Emails:          []Email{},
+		RegistryTokens:  []RegistryToken{},
+	}
+
+	users := productv1.UserList{}
+	if err := c.List(ctx, &users, namespace); err != nil {
+		return nil, err
+	}
+	for _, user := range users.Items {
+		doc.Users = append(doc.Users, User{
+			Name:                user.Name,
+			CreationTimestamp:   user.CreationTimestamp,
+			Spec:                user.Spec,
+			Phase:               user.Status.Phase,
+			MFAEnabled:          user.Status.MFAEnabled,
+			VerifiedPhoneNumber: user.Status.VerifiedPhoneNumber,
+		})
+	}
+
+	invitations := productv1.InvitationList{}
+	if err := c.List(ctx, &invitations, namespace); err != nil {
+		return nil, err
+	}
+	for _, invitation := range invitations.Items {
+		doc.Invitations = append(doc.Invitations, Invitation{
+			Name:              invitation.Name,
+			CreationTimestamp: invitation.CreationTimestamp,
+			Email:             invitation.Spec.Email,
+			Role:              invitation.Spec.Role,
+			Phase:             invitation.Status.Phase,
+		})
+	}
+
+	orders := productv1.OrderList{}
+	if err := c.List(ctx, &orders, namespace); err != nil {
+		return nil, err
+	}
+	for _, order := range orders.Items {
+		exported := Order{
+			Name:              order.Name,
+			CreationTimestamp: order.CreationTimestamp,
+			Spec:              order.Spec,
+			TotalPrice:        order.Status.TotalPrice,
+		}
+		if order.Status.PaymentRef != nil {
+			exported.Payment = order.Status.PaymentRef.Name
+		}
+		for _, licence := range order.Status.Licences {
+			exported.Licences = append(exported.Licences, licence.Name)
+		}
+		doc.Orders = append(doc.Orders, exported)
+	}
+
+	payments := productv1.PaymentList{}
+	if err := c.List(ctx, &payments, namespace); err != nil {
+		return nil, err
+	}
+	for _, payment := range payments.Items {
+		doc.Payments = append(doc.Payments, Payment{
+			Name:              payment.Name,
+			CreationTimestamp: payment.CreationTimestamp,
+			Spec:              payment.Spec,
+			PaymentTimestamp:  payment.Status.PaymentTimestamp,
+		})
+	}
+
+	licences := productv1.LicenceList{}
+	if err := c.List(ctx, &licences, namespace); err != nil {
+		return nil, err
+	}
+	for _, licence := range licences.Items {
+		doc.Licences = append(doc.Licences, Licence{
+			Name:              licence.Name,
+			CreationTimestamp: licence.CreationTimestamp,
+			Spec:              licence.Spec,
+			Activations:       licence.Status.Activations,
+			Suspended:         licence.Status.Suspended,
+		})
+	}
+
+	emails := productv1.EmailList{}
+	if err := c.List(ctx, &emails, namespace); err != nil {
+		return nil, err
+	}
+	for _, email := range emails.Items {
+		doc.Emails = append(doc.Emails, Email{
+			Name:              email.Name,
+			CreationTimestamp: email.CreationTimestamp,
+			ToAddress:         email.Spec.ToAddress,
+			FromName:          email.Spec.FromName,
+			FromAddress:       email.Spec.FromAddress,
+			Subject:           email.Spec.Subject,
+			SentTimestamp:     email.Status.SentTimestamp,
+		})
+	}
+
+	tokens := productv1.RegistryTokenList{}
+	if err := c.List(ctx, &tokens, namespace); err != nil {
+		return nil, err
+	}
+	for _, token := range tokens.Items {
+		doc.RegistryTokens = append(doc.RegistryTokens, RegistryToken{
+			Name:              token.Name,
+			CreationTimestamp: token.CreationTimestamp,
+			DisplayName:       token.Spec.DisplayName,
+			Description:       token.Spec.Description,
+			Email:             token.Spec.User.Email,
+			ExpireTimestamp:   token.Spec.ExpireTimestamp,
+			AutoRotate:        token.Spec.AutoRotate,
+			Phase:             token.Status.Phase,
+			IssueTimestamp:    token.Status.IssueTimestamp,
+			Rotations:         token.Status.Rotations,
+		})
+	}
+
+	return &doc, nil
+}
+
+// Gzip encodes the document as gzip compressed JSON.
+func Gzip(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(writer).Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Zip encodes the document as a zip archive holding a JSON file for the tenant and for each kind of object.
+func Zip(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name    string
+		content any
+	}{
+		{"tenant.json", struct {
+			ExportTimestamp metav1.Time          `json:"exportTimestamp"`
+			Name            string               `json:"name"`
+			Tenant          productv1.TenantSpec `json:"tenant"`
+		}{doc.ExportTimestamp, doc.Name, doc.Tenant}},
+		{"users.json", doc.Users},
+		{"invitations.json", doc.Invitations},
+		{"orders.json", doc.Orders},
+		{"payments.json", doc.Payments},
+		{"licences.json", doc.Licences},
+		{"emails.json", doc.Emails},
+		{"registrytokens.json", doc.RegistryTokens},
+	} {
+		writer, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: doc.ExportTimestamp.Time,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
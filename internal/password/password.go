@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package password holds the complexity rules and the hashing of user passwords.
+package password
+
+import (
+	"errors"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/dlclark/regexp2"
+)
+
+var (
+	ErrWeakPassword = errors.New("password must include upper, lower, number, special and be 8-64 chars")
+
+	matcher = regexp2.MustCompile(`^(?=.*[a-z])(?=.*[A-Z])(?=.*\d)(?=.*[^A-Za-z0-9]).{8,64}$`, 0)
+
+	// DefaultParams are the argon2id parameters new hashes are created with.
+	DefaultParams = &argon2id.Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+)
+
+// HandoverSecretName returns the name of the short-lived Secret handing the hash of a new User over to the User controller.
+func HandoverSecretName(userName string) string {
+	return userName + "-password-handover"
+}
+
+// Validate checks the password against the complexity rules.
+func Validate(password string) error {
+	if ok, _ := matcher.MatchString(password); !ok {
+		return ErrWeakPassword
+	}
+
+	return nil
+}
+
+// Hash creates an argon2id hash of the password with the default parameters.
+func Hash(password string) (string, error) {
+	return argon2id.CreateHash(password, DefaultParams)
+}
+
+// Compare checks the password against the argon2id hash.
+func Compare(password, hash string) (bool, error) {
+	return argon2id.ComparePasswordAndHash(password, hash)
+}
+
+// Params returns the argon2id parameters the hash was created with.
+func Params(hash string) (*argon2id.Params, error) {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	return params, err
+}
+
+// Outdated reports whether the hash was created with weaker parameters than DefaultParams,
+// such hashes should be replaced the next time the password is known.
+func Outdated(hash string) (bool, error) {
+	params, err := Params(hash)
+	if err != nil {
+		return false, err
+	}
+
+	return params.Memory < DefaultParams.Memory ||
+		params.Iterations < DefaultParams.Iterations ||
+		params.SaltLength < DefaultParams.SaltLength ||
+		params.KeyLength < DefaultParams.KeyLength, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package password
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/alexedwards/argon2id"
+)
+
+var _ = Describe("Password", func() {
+	// weaken returns DefaultParams with one parameter changed.
+	weaken := func(change func(*argon2id.Params)) *argon2id.Params {
+		params := *DefaultParams
+		change(&params)
+		return &params
+	}
+
+	DescribeTable("should report the parameters of the hash",
+		func(params *argon2id.Params) {
+			hash, err := argon2id.CreateHash("correct horse battery staple", params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(Params(hash)).To(Equal(params))
+		},
+		Entry("default", DefaultParams),
+		Entry("weaker", &argon2id.Params{Memory: 16 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}),
+	)
+
+	DescribeTable("should report hashes created with weaker parameters as outdated",
+		func(params *argon2id.Params, outdated bool) {
+			hash, err := argon2id.CreateHash("correct horse battery staple", params)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(Outdated(hash)).To(Equal(outdated))
+		},
+		Entry("default", DefaultParams, false),
+		Entry("less memory", weaken(func(p *argon2id.Params) { p.Memory = 32 * 1024 }), true),
+		Entry("fewer iterations", weaken(func(p *argon2id.Params) { p.Iterations = 1 }), true),
+		Entry("shorter salt", weaken(func(p *argon2id.Params) { p.SaltLength = 8 }), true),
+		Entry("shorter key", weaken(func(p *argon2id.Params) { p.KeyLength = 16 }), true),
+		Entry("less parallelism", weaken(func(p *argon2id.Params) { p.Parallelism = 1 }), false),
+		Entry("stronger", weaken(func(p *argon2id.Params) { p.Iterations = 4 }), false),
+	)
+
+	It("should reject malformed hashes", func() {
+		_, err := Params("$2a$10$not-an-argon2id-hash")
+		Expect(err).To(HaveOccurred())
+
+		_, err = Outdated("")
+		Expect(err).To(HaveOccurred())
+	})
+})
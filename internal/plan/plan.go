@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan holds the commercial tiers of tenants and what each of them allows.
+package plan
+
+// Names of the plans.
+const (
+	Free       = "Free"
+	Pro        = "Pro"
+	Enterprise = "Enterprise"
+)
+
+// Limits is what a tenant on a plan is allowed to do, zero counts are unlimited.
+type Limits struct {
+	// Users caps the Users of the tenant, pending Invitations included.
+	Users int
+	// RegistryTokens caps the RegistryTokens of the tenant which have not expired.
+	RegistryTokens int
+	// Invitations tells whether the users of the tenant may invite colleagues.
+	Invitations bool
+}
+
+// plans lists the plans from the lowest to the highest tier.
+var plans = []string{Free, Pro, Enterprise}
+
+var limits = map[string]Limits{
+	Free: {
+		Users:          1,
+		RegistryTokens: 2,
+	},
+	Pro: {
+		Users:          10,
+		RegistryTokens: 20,
+		Invitations:    true,
+	},
+	Enterprise: {
+		Invitations: true,
+	},
+}
+
+// Of returns the name of the plan, tenants created before plans were introduced are on the Free plan.
+func Of(name string) string {
+	if _, ok := limits[name]; !ok {
+		return Free
+	}
+
+	return name
+}
+
+// LimitsOf returns the limits of the plan.
+func LimitsOf(name string) Limits {
+	return limits[Of(name)]
+}
+
+// Rank orders the plans by tier, higher plans have a higher rank.
+func Rank(name string) int {
+	for i, p := range plans {
+		if p == Of(name) {
+			return i
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package signing issues and verifies HMAC signed, expiring tokens embedded into links sent to users.
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// KeySecretName is the name of the Secret holding the signing key in the namespace of the service.
+	KeySecretName = "example-webshop-service-signing-key"
+	// keySecretKey is the data key of the signing key in the Secret.
+	keySecretKey = "key"
+
+	// PurposeVerify marks tokens verifying the email address of a User.
+	PurposeVerify = "verify"
+	// PurposePasswordReset marks tokens resetting the password of a User.
+	PurposePasswordReset = "password-reset"
+	// PurposeMFA marks tokens of the second login step of a User with multi-factor authentication.
+	PurposeMFA = "mfa"
+	// PurposeInvitation marks tokens accepting an Invitation into a tenant.
+	PurposeInvitation = "invitation"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+type payload struct {
+	Purpose   string `json:"p"`
+	Subject   string `json:"s"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// LoadKey returns the signing key stored in the namespace, the key is generated on first use.
+func LoadKey(ctx context.Context, c client.Client, namespace string) ([]byte, error) {
+	secret := corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: KeySecretName, Namespace: namespace}, &secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+
+		secret = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      KeySecretName,
+				Namespace: namespace,
+			},
+			Data: map[string][]byte{
+				keySecretKey: key,
+			},
+		}
+		if err := c.Create(ctx, &secret); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return nil, err
+			}
+
+			if err := c.Get(ctx, types.NamespacedName{Name: KeySecretName, Namespace: namespace}, &secret); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(secret.Data[keySecretKey]) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no signing key", namespace, KeySecretName)
+	}
+
+	return secret.Data[keySecretKey], nil
+}
+
+// Sign issues a token binding the subject to the purpose until the expiry.
+func Sign(key []byte, purpose, subject string, expiresAt time.Time) (string, error) {
+	raw, err := json.Marshal(&payload{
+		Purpose:   purpose,
+		Subject:   subject,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(raw)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac(key, encoded)), nil
+}
+
+// Verify checks the signature, purpose and expiry of the token and returns its subject.
+func Verify(key []byte, purpose, token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sum, mac(key, encoded)) {
+		return "", ErrInvalidToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	p := payload{}
+	if err := json.Unmarshal(raw, &p); err != nil || p.Purpose != purpose || p.Subject == "" {
+		return "", ErrInvalidToken
+	}
+
+	if time.Now().Unix() > p.ExpiresAt {
+		return "", ErrExpiredToken
+	}
+
+	return p.Subject, nil
+}
+
+func mac(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Signing", func() {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	sign := func(purpose, subject string, expiresAt time.Time) string {
+		token, err := Sign(key, purpose, subject, expiresAt)
+		Expect(err).NotTo(HaveOccurred())
+		return token
+	}
+
+	It("should return the subject of a valid token", func() {
+		token := sign(PurposeVerify, "user-uid", time.Now().Add(time.Hour))
+
+		Expect(Verify(key, PurposeVerify, token)).To(Equal("user-uid"))
+	})
+
+	DescribeTable("should bind the token to its purpose",
+		func(signed, verified string) {
+			_, err := Verify(key, verified, sign(signed, "user-uid", time.Now().Add(time.Hour)))
+			Expect(err).To(MatchError(ErrInvalidToken))
+		},
+		Entry("verification used for a password reset", PurposeVerify, PurposePasswordReset),
+		Entry("password reset used for a verification", PurposePasswordReset, PurposeVerify),
+		Entry("invitation used for the second login step", PurposeInvitation, PurposeMFA),
+	)
+
+	It("should reject an expired token", func() {
+		token := sign(PurposePasswordReset, "user-uid", time.Now().Add(-time.Minute))
+
+		_, err := Verify(key, PurposePasswordReset, token)
+		Expect(err).To(MatchError(ErrExpiredToken))
+	})
+
+	It("should reject a token without a subject", func() {
+		_, err := Verify(key, PurposeVerify, sign(PurposeVerify, "", time.Now().Add(time.Hour)))
+		Expect(err).To(MatchError(ErrInvalidToken))
+	})
+
+	DescribeTable("should reject tampered tokens",
+		func(tamper func(encoded, signature string) string) {
+			encoded, signature, ok := strings.Cut(sign(PurposeVerify, "user-uid", time.Now().Add(time.Hour)), ".")
+			Expect(ok).To(BeTrue())
+
+			_, err := Verify(key, PurposeVerify, tamper(encoded, signature))
+			Expect(err).To(MatchError(ErrInvalidToken))
+		},
+		Entry("with another subject", func(_, signature string) string {
+			raw, err := json.Marshal(&payload{Purpose: PurposeVerify, Subject: "admin-uid", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			Expect(err).NotTo(HaveOccurred())
+			return base64.RawURLEncoding.EncodeToString(raw) + "." + signature
+		}),
+		Entry("with an extended expiry", func(_, signature string) string {
+			raw, err := json.Marshal(&payload{Purpose: PurposeVerify, Subject: "user-uid", ExpiresAt: time.Now().Add(24 * time.Hour).Unix()})
+			Expect(err).NotTo(HaveOccurred())
+			return base64.RawURLEncoding.EncodeToString(raw) + "." + signature
+		}),
+		Entry("with a flipped signature byte", func(encoded, signature string) string {
+			sum, err := base64.RawURLEncoding.DecodeString(signature)
+			Expect(err).NotTo(HaveOccurred())
+			sum[0] ^= 0xff
+			return encoded + "." + base64.RawURLEncoding.EncodeToString(sum)
+		}),
+		Entry("with a malformed signature", func(encoded, _ string) string {
+			return encoded + ".not base64!"
+		}),
+		Entry("without a signature", func(encoded, _ string) string {
+			return encoded
+		}),
+		Entry("signed with another key", func(_, _ string) string {
+			token, err := Sign([]byte("another key"), PurposeVerify, "user-uid", time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			return token
+		}),
+	)
+
+	Context("LoadKey", func() {
+		ctx := context.Background()
+
+		It("should generate the key on first use and keep it", func() {
+			c := fake.NewClientBuilder().Build()
+
+			generated, err := LoadKey(ctx, c, "operator")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(generated).To(HaveLen(32))
+
+			secret := corev1.Secret{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: KeySecretName, Namespace: "operator"}, &secret)).To(Succeed())
+			Expect(secret.Data["key"]).To(Equal(generated))
+
+			Expect(LoadKey(ctx, c, "operator")).To(Equal(generated))
+		})
+
+		It("should fail on a Secret without a key", func() {
+			c := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: KeySecretName, Namespace: "operator"},
+			}).Build()
+
+			_, err := LoadKey(ctx, c, "operator")
+			Expect(err).To(MatchError(ContainSubstring("has no signing key")))
+		})
+	})
+})
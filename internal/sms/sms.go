@@ -0,0 +1,84 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sms delivers text messages to the phone numbers of users.
+package sms
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// SMSSender delivers a text message to a phone number in E.164 format.
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// New returns the sender of the given kind, "log" or "file". The file sender appends to path.
+func New(kind, path string, log logr.Logger) (SMSSender, error) {
+	switch kind {
+	case "log":
+		return &LogSender{Log: log}, nil
+	case "file":
+		if path == "" {
+			return nil, fmt.Errorf("file sms sender requires a path")
+		}
+		return &FileSender{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown sms sender %q", kind)
+	}
+}
+
+// LogSender writes the messages to the log instead of sending them, for local development.
+type LogSender struct {
+	Log logr.Logger
+}
+
+// Send implements SMSSender.
+func (s *LogSender) Send(_ context.Context, to, message string) error {
+	s.Log.Info("SMS has been sent", "to", to, "message", message)
+	return nil
+}
+
+// FileSender appends the messages to a file instead of sending them, for local development and tests.
+type FileSender struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send implements SMSSender.
+func (s *FileSender) Send(_ context.Context, to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), to, message); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tenancy names the tenant namespaces and finds the Tenant of a namespace, and the namespace of a Tenant,
+// through the labels of the namespaces instead of their names.
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+const (
+	// TenantLabel holds the name of the Tenant on its namespace and on the objects kept for it elsewhere.
+	TenantLabel = "product.webshop.harikube.info/tenant"
+	// PlanLabel holds the plan of the Tenant on its namespace.
+	PlanLabel = "product.webshop.harikube.info/plan"
+	// CountryLabel holds the country of the Tenant on its namespace.
+	CountryLabel = "product.webshop.harikube.info/country"
+	// CompanyNameAnnotation holds the company name of the Tenant on its namespace.
+	CompanyNameAnnotation = "product.webshop.harikube.info/company-name"
+	// RegistryTokenMaxLifetimeAnnotation overrides the maximum RegistryToken lifetime on the namespace of a Tenant.
+	RegistryTokenMaxLifetimeAnnotation = "product.webshop.harikube.info/registry-token-max-lifetime"
+	// ManagedByLabel marks the namespaces managed by the service.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// ManagedBy is the value of ManagedByLabel.
+	ManagedBy = "example-webshop-service"
+
+	// maxNameLength is the longest name a namespace may have.
+	maxNameLength = 63
+	// maxSlugLength leaves room for the collision suffix within maxNameLength.
+	maxSlugLength = 40
+)
+
+// Naming proposes names for the namespace of a tenant, the first one which is not taken by another tenant is used.
+type Naming interface {
+	Candidates(tenant *productv1.Tenant) []string
+}
+
+// NewNaming returns the naming strategy of the given kind: "name" names the namespace after the Tenant, "slug"
+// after its company name and "prefix" joins the prefix and a short ID of the Tenant.
+func NewNaming(kind, prefix string) (Naming, error) {
+	switch kind {
+	case "name":
+		return &NameNaming{}, nil
+	case "slug":
+		return &SlugNaming{Prefix: prefix}, nil
+	case "prefix":
+		return &PrefixNaming{Prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("unknown tenant namespace naming %q", kind)
+	}
+}
+
+// NameNaming names the namespace after the Tenant, which is named after the UID of its RegistrationRequest.
+type NameNaming struct{}
+
+// Candidates implements Naming.
+func (n *NameNaming) Candidates(tenant *productv1.Tenant) []string {
+	return []string{tenant.Name}
+}
+
+// SlugNaming names the namespace after the company name of the Tenant. Colliding slugs get a short ID of the
+// Tenant appended, then the full UID replaces the slug. Tenants without a usable company name fall back to the
+// prefix and the short ID, then the full UID.
+type SlugNaming struct {
+	Prefix string
+}
+
+// Candidates implements Naming.
+func (n *SlugNaming) Candidates(tenant *productv1.Tenant) []string {
+	id := ShortID(tenant)
+	uid := truncate(n.Prefix + strings.ReplaceAll(string(tenant.UID), "-", ""))
+	slug := Slug(tenant.Spec.CompanyName)
+	if slug == "" {
+		return []string{truncate(n.Prefix + id), uid}
+	}
+
+	return []string{
+		truncate(n.Prefix + slug),
+		truncate(n.Prefix + slug + "-" + id),
+		uid,
+	}
+}
+
+// PrefixNaming joins the prefix and a short ID of the Tenant, falling back to the full UID on collision.
+type PrefixNaming struct {
+	Prefix string
+}
+
+// Candidates implements Naming.
+func (n *PrefixNaming) Candidates(tenant *productv1.Tenant) []string {
+	return []string{
+		truncate(n.Prefix + ShortID(tenant)),
+		truncate(n.Prefix + strings.ReplaceAll(string(tenant.UID), "-", "")),
+	}
+}
+
+// ShortID returns the first eight hexadecimal digits of the UID of the Tenant.
+func ShortID(tenant *productv1.Tenant) string {
+	id := strings.ReplaceAll(string(tenant.UID), "-", "")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+
+	return id
+}
+
+// folds maps the accented latin letters of company names to their base letter.
+var folds = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'æ': "ae",
+	'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ł': "l", 'ľ': "l", 'ĺ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ő': "o", 'ø': "o", 'ō': "o", 'œ': "oe",
+	'ř': "r", 'ŕ': "r", 'ś': "s", 'š': "s", 'ș': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ț': "t", 'ţ': "t",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u", 'ű': "u", 'ū': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Slug turns the company name into a DNS label, like "Müller & Söhne GmbH" into "muller-sohne-gmbh".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		fold, ok := folds[r]
+		switch {
+		case ok:
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			fold = string(r)
+		default:
+			dash = b.Len() > 0
+			continue
+		}
+
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(fold)
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+
+	return slug
+}
+
+func truncate(name string) string {
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+
+	return strings.Trim(name, "-")
+}
+
+// Owns reports whether the namespace belongs to the Tenant.
+func Owns(tenant *productv1.Tenant, namespace *corev1.Namespace) bool {
+	if namespace.Labels[TenantLabel] == tenant.Name {
+		return true
+	}
+
+	for _, ref := range namespace.OwnerReferences {
+		if ref.UID == tenant.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NamespaceOf returns the name of the namespace of the Tenant, empty if it has none yet. More than one
+// namespace labelled with the Tenant is an error, none of them can be trusted to be the right one.
+func NamespaceOf(ctx context.Context, c client.Reader, tenant *productv1.Tenant) (string, error) {
+	if tenant.Status.Namespace != "" {
+		return tenant.Status.Namespace, nil
+	}
+
+	namespaces := corev1.NamespaceList{}
+	if err := c.List(ctx, &namespaces, client.MatchingLabels{TenantLabel: tenant.Name}); err != nil {
+		return "", err
+	} else if len(namespaces.Items) == 0 {
+		return "", nil
+	} else if len(namespaces.Items) > 1 {
+		return "", fmt.Errorf("%d namespaces are labelled with tenant %s", len(namespaces.Items), tenant.Name)
+	}
+
+	return namespaces.Items[0].Name, nil
+}
+
+// TenantOf returns the Tenant the namespace belongs to, a NotFound error if it belongs to none.
+func TenantOf(ctx context.Context, c client.Reader, namespaceName string) (*productv1.Tenant, error) {
+	namespace := corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespaceName}, &namespace); err != nil {
+		return nil, err
+	}
+
+	name, ok := namespace.Labels[TenantLabel]
+	if !ok {
+		return nil, apierrors.NewNotFound(productv1.GroupVersion.WithResource("tenants").GroupResource(), namespaceName)
+	}
+
+	tenant := productv1.Tenant{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, &tenant); err != nil {
+		return nil, err
+	}
+
+	return &tenant, nil
+}
+
+// RegistryTokenMaxLifetime returns the maximum RegistryToken lifetime in the namespace, the fallback unless the
+// namespace overrides it with RegistryTokenMaxLifetimeAnnotation. Zero means no limit.
+func RegistryTokenMaxLifetime(namespace *corev1.Namespace, fallback time.Duration) (time.Duration, error) {
+	value, ok := namespace.Annotations[RegistryTokenMaxLifetimeAnnotation]
+	if !ok {
+		return fallback, nil
+	}
+
+	maxLifetime, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid annotation '%s' on Namespace %s: %w", RegistryTokenMaxLifetimeAnnotation, namespace.Name, err)
+	}
+
+	return maxLifetime, nil
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tenancy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+)
+
+var _ = Describe("Tenancy", func() {
+	newTenant := func(companyName string) *productv1.Tenant {
+		return &productv1.Tenant{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "acme",
+				UID:  types.UID("0a1b2c3d-4e5f-6789-abcd-ef0123456789"),
+			},
+			Spec: productv1.TenantSpec{CompanyName: companyName},
+		}
+	}
+
+	DescribeTable("should turn company names into DNS labels",
+		func(name, slug string) {
+			Expect(Slug(name)).To(Equal(slug))
+		},
+		Entry("plain", "Acme", "acme"),
+		Entry("accents and punctuation", "Müller & Söhne GmbH", "muller-sohne-gmbh"),
+		Entry("multi letter folds", "Straße Œuvre Ærø", "strasse-oeuvre-aero"),
+		Entry("Hungarian double acute", "Őrült Űrhajó Kft.", "orult-urhajo-kft"),
+		Entry("leading and trailing separators", "  --Acme Ltd.--  ", "acme-ltd"),
+		Entry("digits", "42 Solutions", "42-solutions"),
+		Entry("nothing usable", "株式会社", ""),
+		Entry("empty", "", ""),
+		Entry("truncated", "Very Long Company Name Incorporated Worldwide Holding", "very-long-company-name-incorporated-worl"),
+		Entry("truncated at a separator", strings.Repeat("a", 39)+" b", strings.Repeat("a", 39)),
+	)
+
+	It("should keep slugs within the slug length", func() {
+		Expect(len(Slug(strings.Repeat("Company ", 20)))).To(BeNumerically("<=", maxSlugLength))
+	})
+
+	DescribeTable("should propose candidates in order",
+		func(naming Naming, companyName string, candidates []string) {
+			Expect(naming.Candidates(newTenant(companyName))).To(Equal(candidates))
+		},
+		Entry("name", &NameNaming{}, "Acme Ltd.", []string{"acme"}),
+		Entry("slug", &SlugNaming{Prefix: "tenant-"}, "Acme Ltd.", []string{
+			"tenant-acme-ltd",
+			"tenant-acme-ltd-0a1b2c3d",
+			"tenant-0a1b2c3d4e5f6789abcdef0123456789",
+		}),
+		Entry("slug without a usable company name", &SlugNaming{Prefix: "tenant-"}, "株式会社", []string{
+			"tenant-0a1b2c3d",
+			"tenant-0a1b2c3d4e5f6789abcdef0123456789",
+		}),
+		Entry("slug without a prefix", &SlugNaming{}, "Acme", []string{
+			"acme",
+			"acme-0a1b2c3d",
+			"0a1b2c3d4e5f6789abcdef0123456789",
+		}),
+		Entry("prefix", &PrefixNaming{Prefix: "tenant-"}, "Acme Ltd.", []string{
+			"tenant-0a1b2c3d",
+			"tenant-0a1b2c3d4e5f6789abcdef0123456789",
+		}),
+	)
+
+	It("should keep candidates within the namespace name length", func() {
+		naming := &SlugNaming{Prefix: strings.Repeat("p", 40) + "-"}
+		for _, candidate := range naming.Candidates(newTenant(strings.Repeat("Company ", 10))) {
+			Expect(len(candidate)).To(BeNumerically("<=", maxNameLength))
+			Expect(candidate).NotTo(HaveSuffix("-"))
+		}
+	})
+
+	It("should reject unknown namings", func() {
+		_, err := NewNaming("random", "tenant-")
+		Expect(err).To(MatchError(ContainSubstring("unknown tenant namespace naming")))
+	})
+
+	DescribeTable("should read the maximum registry token lifetime of the namespace",
+		func(annotations map[string]string, lifetime time.Duration) {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-acme", Annotations: annotations}}
+			Expect(RegistryTokenMaxLifetime(namespace, 90*24*time.Hour)).To(Equal(lifetime))
+		},
+		Entry("fallback", nil, 90*24*time.Hour),
+		Entry("override", map[string]string{RegistryTokenMaxLifetimeAnnotation: "720h"}, 30*24*time.Hour),
+	)
+
+	It("should reject invalid maximum registry token lifetimes", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenant-acme",
+			Annotations: map[string]string{RegistryTokenMaxLifetimeAnnotation: "a month"},
+		}}
+		_, err := RegistryTokenMaxLifetime(namespace, time.Hour)
+		Expect(err).To(MatchError(ContainSubstring("invalid annotation")))
+	})
+
+	Context("with namespaces", func() {
+		ctx := context.Background()
+
+		namespace := func(name, tenant string) *corev1.Namespace {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			if tenant != "" {
+				ns.Labels = map[string]string{TenantLabel: tenant}
+			}
+			return ns
+		}
+
+		newClient := func(objects ...client.Object) client.Client {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			Expect(productv1.AddToScheme(scheme)).To(Succeed())
+
+			return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		}
+
+		It("should prefer the namespace recorded on the tenant", func() {
+			tenant := newTenant("")
+			tenant.Status.Namespace = "recorded"
+
+			Expect(NamespaceOf(ctx, newClient(namespace("labelled", "acme")), tenant)).To(Equal("recorded"))
+		})
+
+		It("should find the namespace by its label", func() {
+			c := newClient(namespace("labelled", "acme"), namespace("other", "globex"), namespace("plain", ""))
+
+			Expect(NamespaceOf(ctx, c, newTenant(""))).To(Equal("labelled"))
+		})
+
+		It("should return no namespace before it is created", func() {
+			Expect(NamespaceOf(ctx, newClient(namespace("other", "globex")), newTenant(""))).To(BeEmpty())
+		})
+
+		It("should fail when more than one namespace carries the label", func() {
+			c := newClient(namespace("first", "acme"), namespace("second", "acme"))
+
+			_, err := NamespaceOf(ctx, c, newTenant(""))
+			Expect(err).To(MatchError(ContainSubstring("2 namespaces are labelled with tenant acme")))
+		})
+
+		It("should find the tenant of a labelled namespace", func() {
+			c := newClient(namespace("labelled", "acme"), namespace("plain", ""), newTenant(""))
+
+			tenant, err := TenantOf(ctx, c, "labelled")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tenant.Name).To(Equal("acme"))
+
+			_, err = TenantOf(ctx, c, "plain")
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should recognise the namespaces of the tenant", func() {
+			tenant := newTenant("")
+			owned := namespace("owned", "")
+			owned.OwnerReferences = []metav1.OwnerReference{{UID: tenant.UID}}
+
+			Expect(Owns(tenant, namespace("labelled", "acme"))).To(BeTrue())
+			Expect(Owns(tenant, owned)).To(BeTrue())
+			Expect(Owns(tenant, namespace("other", "globex"))).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package totp
+
+import (
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+var _ = Describe("TOTP", func() {
+	// The RFC 6238 vectors have 8 digits, the 6 digit codes are their last digits.
+	DescribeTable("should match the RFC 6238 SHA1 test vectors",
+		func(unix int64, code string) {
+			Expect(Code(rfcSecret, time.Unix(unix, 0))).To(Equal(code))
+
+			step, ok := Validate(rfcSecret, code, time.Unix(unix, 0))
+			Expect(ok).To(BeTrue())
+			Expect(step).To(Equal(unix / Period))
+		},
+		Entry("at 59", int64(59), "287082"),
+		Entry("at 1111111109", int64(1111111109), "081804"),
+		Entry("at 1111111111", int64(1111111111), "050471"),
+		Entry("at 1234567890", int64(1234567890), "005924"),
+		Entry("at 2000000000", int64(2000000000), "279037"),
+		Entry("at 20000000000", int64(20000000000), "353130"),
+	)
+
+	It("should accept the codes of the neighbouring periods only", func() {
+		now := time.Unix(1234567890, 0)
+		for offset, accepted := range map[time.Duration]bool{
+			-2 * Period * time.Second: false,
+			-Period * time.Second:     true,
+			Period * time.Second:      true,
+			2 * Period * time.Second:  false,
+		} {
+			code, err := Code(rfcSecret, now.Add(offset))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, ok := Validate(rfcSecret, code, now)
+			Expect(ok).To(Equal(accepted), "offset %s", offset)
+		}
+	})
+
+	It("should accept lowercase secrets", func() {
+		_, ok := Validate("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "005924", time.Unix(1234567890, 0))
+		Expect(ok).To(BeTrue())
+	})
+
+	DescribeTable("should reject malformed input",
+		func(secret, code string) {
+			_, ok := Validate(secret, code, time.Unix(1234567890, 0))
+			Expect(ok).To(BeFalse())
+		},
+		Entry("wrong code", rfcSecret, "005925"),
+		Entry("short code", rfcSecret, "05924"),
+		Entry("long code", rfcSecret, "89005924"),
+		Entry("invalid secret", "not base32!", "005924"),
+	)
+
+	It("should generate distinct 160 bit secrets", func() {
+		first, err := GenerateSecret()
+		Expect(err).NotTo(HaveOccurred())
+		second, err := GenerateSecret()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(HaveLen(32))
+		Expect(first).NotTo(Equal(second))
+	})
+
+	It("should build the otpauth URI", func() {
+		uri, err := url.Parse(URI("Webshop", "alice@example.com", rfcSecret))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uri.Scheme).To(Equal("otpauth"))
+		Expect(uri.Host).To(Equal("totp"))
+		Expect(uri.Path).To(Equal("/Webshop:alice@example.com"))
+		Expect(uri.Query()).To(HaveKeyWithValue("secret", []string{rfcSecret}))
+		Expect(uri.Query()).To(HaveKeyWithValue("digits", []string{"6"}))
+		Expect(uri.Query()).To(HaveKeyWithValue("period", []string{"30"}))
+	})
+})
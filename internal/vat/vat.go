@@ -0,0 +1,313 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vat validates EU VAT identification numbers, offline by format and check digits, and online through
+// a pluggable Verifier.
+package vat
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// formats holds the formats of the VAT numbers by their prefix, which is the country code except for Greece (EL)
+// and Northern Ireland (XI).
+var formats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U\d{8}$`),
+	"BE": regexp.MustCompile(`^[01]\d{9}$`),
+	"BG": regexp.MustCompile(`^\d{9,10}$`),
+	"CY": regexp.MustCompile(`^\d{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^\d{8,10}$`),
+	"DE": regexp.MustCompile(`^\d{9}$`),
+	"DK": regexp.MustCompile(`^\d{8}$`),
+	"EE": regexp.MustCompile(`^\d{9}$`),
+	"EL": regexp.MustCompile(`^\d{9}$`),
+	"ES": regexp.MustCompile(`^[A-Z\d]\d{7}[A-Z\d]$`),
+	"FI": regexp.MustCompile(`^\d{8}$`),
+	"FR": regexp.MustCompile(`^[A-HJ-NP-Z\d]{2}\d{9}$`),
+	"HR": regexp.MustCompile(`^\d{11}$`),
+	"HU": regexp.MustCompile(`^\d{8}$`),
+	"IE": regexp.MustCompile(`^(\d{7}[A-W][A-I]?|\d[A-Z+*]\d{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^\d{11}$`),
+	"LT": regexp.MustCompile(`^(\d{9}|\d{12})$`),
+	"LU": regexp.MustCompile(`^\d{8}$`),
+	"LV": regexp.MustCompile(`^\d{11}$`),
+	"MT": regexp.MustCompile(`^\d{8}$`),
+	"NL": regexp.MustCompile(`^\d{9}B\d{2}$`),
+	"PL": regexp.MustCompile(`^\d{10}$`),
+	"PT": regexp.MustCompile(`^\d{9}$`),
+	"RO": regexp.MustCompile(`^[1-9]\d{1,9}$`),
+	"SE": regexp.MustCompile(`^\d{10}01$`),
+	"SI": regexp.MustCompile(`^[1-9]\d{7}$`),
+	"SK": regexp.MustCompile(`^\d{10}$`),
+	"XI": regexp.MustCompile(`^(\d{9}|\d{12}|GD[0-4]\d{2}|HA[5-9]\d{2})$`),
+}
+
+// checksums holds the check digit algorithms of the prefixes which have a published one.
+var checksums = map[string]func(string) bool{
+	"AT": checkAT,
+	"BE": checkBE,
+	"DE": checkDE,
+	"DK": checkDK,
+	"EL": checkEL,
+	"FI": checkFI,
+	"FR": checkFR,
+	"HR": checkDE,
+	"HU": checkHU,
+	"IT": luhn,
+	"LU": checkLU,
+	"NL": checkNL,
+	"PL": checkPL,
+	"PT": checkPT,
+	"SE": func(number string) bool { return luhn(number[:10]) },
+	"SI": checkSI,
+}
+
+// Verifier confirms with an authority, like the VIES service of the European Commission, that a well-formed
+// VAT number has been issued.
+type Verifier interface {
+	Verify(ctx context.Context, number string) error
+}
+
+// New returns the verifier of the given kind, only "offline" is supported for now.
+func New(kind string) (Verifier, error) {
+	switch kind {
+	case "offline":
+		return &OfflineVerifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown vat verifier %q", kind)
+	}
+}
+
+// OfflineVerifier accepts every well-formed number, for local development and when no online service is configured.
+type OfflineVerifier struct{}
+
+// Verify implements Verifier.
+func (v *OfflineVerifier) Verify(_ context.Context, _ string) error {
+	return nil
+}
+
+// Prefix returns the VAT number prefix of the country given by its ISO 3166-1 alpha-2 code,
+// or an empty string if the country does not issue EU VAT numbers.
+func Prefix(country string) string {
+	if country == "GR" {
+		return "EL"
+	} else if _, ok := formats[country]; !ok {
+		return ""
+	}
+
+	return country
+}
+
+// Normalize strips the separators from the VAT number and upper cases it.
+func Normalize(number string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '/':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(number))
+}
+
+// Validate checks the normalized VAT number against the format and the check digits of the prefix.
+func Validate(prefix, number string) error {
+	if !strings.HasPrefix(number, prefix) {
+		return fmt.Errorf("VAT number %q has to start with %s", number, prefix)
+	}
+
+	body := strings.TrimPrefix(number, prefix)
+	if format, ok := formats[prefix]; !ok || !format.MatchString(body) {
+		return fmt.Errorf("VAT number %q is not in the %s format", number, prefix)
+	}
+
+	if check, ok := checksums[prefix]; ok && !check(body) {
+		return fmt.Errorf("VAT number %q has an invalid check digit", number)
+	}
+
+	return nil
+}
+
+// digits returns the decimal digits of the number, which has been matched by its format already.
+func digits(number string) []int {
+	result := make([]int, 0, len(number))
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			result = append(result, int(r-'0'))
+		}
+	}
+
+	return result
+}
+
+// weighted returns the sum of the digits multiplied by the weights.
+func weighted(d []int, weights ...int) int {
+	sum := 0
+	for i, weight := range weights {
+		sum += d[i] * weight
+	}
+
+	return sum
+}
+
+func checkAT(number string) bool {
+	d := digits(number)
+	sum := 0
+	for i := 0; i < 7; i++ {
+		p := d[i] * (1 + i%2)
+		sum += p/10 + p%10
+	}
+
+	return (10-(sum+4)%10)%10 == d[7]
+}
+
+func checkBE(number string) bool {
+	var head, tail int
+	_, _ = fmt.Sscanf(number[:8], "%d", &head)
+	_, _ = fmt.Sscanf(number[8:], "%d", &tail)
+
+	return 97-head%97 == tail
+}
+
+// checkDE implements ISO 7064 MOD 11,10, which Croatia uses too.
+func checkDE(number string) bool {
+	d := digits(number)
+	product := 10
+	for _, digit := range d[:len(d)-1] {
+		sum := (digit + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = (2 * sum) % 11
+	}
+
+	return (11-product)%10 == d[len(d)-1]
+}
+
+func checkDK(number string) bool {
+	return weighted(digits(number), 2, 7, 6, 5, 4, 3, 2, 1)%11 == 0
+}
+
+func checkEL(number string) bool {
+	d := digits(number)
+	return weighted(d, 256, 128, 64, 32, 16, 8, 4, 2)%11%10 == d[8]
+}
+
+func checkFI(number string) bool {
+	d := digits(number)
+	remainder := weighted(d, 7, 9, 10, 5, 8, 4, 2) % 11
+	if remainder == 1 {
+		return false
+	} else if remainder == 0 {
+		return d[7] == 0
+	}
+
+	return 11-remainder == d[7]
+}
+
+// checkFR validates the numeric key of French numbers, the alphanumeric keys have no published algorithm.
+func checkFR(number string) bool {
+	var key, siren int
+	if _, err := fmt.Sscanf(number[:2], "%d", &key); err != nil || len(digits(number[:2])) != 2 {
+		return true
+	}
+	_, _ = fmt.Sscanf(number[2:], "%d", &siren)
+
+	return (12+3*(siren%97))%97 == key
+}
+
+func checkHU(number string) bool {
+	d := digits(number)
+	return (10-weighted(d, 9, 7, 3, 1, 9, 7, 3)%10)%10 == d[7]
+}
+
+func checkLU(number string) bool {
+	var head, tail int
+	_, _ = fmt.Sscanf(number[:6], "%d", &head)
+	_, _ = fmt.Sscanf(number[6:], "%d", &tail)
+
+	return head%89 == tail
+}
+
+// checkNL accepts the numbers of legal entities by the eleven test and the ones of sole traders by ISO 7064 MOD 97-10.
+func checkNL(number string) bool {
+	d := digits(number)
+	if (weighted(d, 9, 8, 7, 6, 5, 4, 3, 2)-d[8])%11 == 0 {
+		return true
+	}
+
+	var converted strings.Builder
+	for _, r := range "NL" + number {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&converted, "%d", r-'A'+10)
+		} else {
+			converted.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(converted.String(), 10)
+
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+func checkPL(number string) bool {
+	d := digits(number)
+	check := weighted(d, 6, 5, 7, 2, 3, 4, 5, 6, 7) % 11
+
+	return check != 10 && check == d[9]
+}
+
+func checkPT(number string) bool {
+	d := digits(number)
+	check := 11 - weighted(d, 9, 8, 7, 6, 5, 4, 3, 2)%11
+	if check > 9 {
+		check = 0
+	}
+
+	return check == d[8]
+}
+
+func checkSI(number string) bool {
+	d := digits(number)
+	check := 11 - weighted(d, 8, 7, 6, 5, 4, 3, 2)%11
+	if check == 11 {
+		return false
+	} else if check == 10 {
+		check = 0
+	}
+
+	return check == d[7]
+}
+
+// luhn validates the digits by the Luhn algorithm.
+func luhn(number string) bool {
+	d := digits(number)
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		digit := d[i]
+		if (len(d)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
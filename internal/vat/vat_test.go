@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vat
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VAT", func() {
+	DescribeTable("should accept well-formed numbers with valid check digits",
+		func(number string) {
+			Expect(Validate(number[:2], number)).To(Succeed())
+		},
+		Entry("Austria", "ATU13585627"),
+		Entry("Belgium", "BE0776091951"),
+		Entry("Belgium with the old leading zero", "BE0411905847"),
+		Entry("Cyprus without check digits", "CY10259033P"),
+		Entry("Germany", "DE136695976"),
+		Entry("Denmark", "DK13585628"),
+		Entry("Greece", "EL094259216"),
+		Entry("Spain without check digits", "ESA12345674"),
+		Entry("Finland", "FI20774740"),
+		Entry("France with a numeric key", "FR40303265045"),
+		Entry("France with an alphanumeric key", "FRK7399859412"),
+		Entry("Croatia", "HR33392005961"),
+		Entry("Hungary", "HU12892312"),
+		Entry("Ireland without check digits", "IE6388047V"),
+		Entry("Italy", "IT00743110157"),
+		Entry("Luxembourg", "LU26375245"),
+		Entry("Netherlands legal entity", "NL004495445B01"),
+		Entry("Netherlands sole trader", "NL000099998B57"),
+		Entry("Poland", "PL5260001246"),
+		Entry("Portugal", "PT501964843"),
+		Entry("Sweden", "SE556000016701"),
+		Entry("Slovenia", "SI50223054"),
+	)
+
+	DescribeTable("should reject numbers with an invalid check digit",
+		func(number string) {
+			Expect(Validate(number[:2], number)).To(MatchError(ContainSubstring("invalid check digit")))
+		},
+		Entry("Austria", "ATU13585626"),
+		Entry("Belgium", "BE0776091952"),
+		Entry("Germany", "DE136695975"),
+		Entry("Denmark", "DK13585629"),
+		Entry("Greece", "EL094259217"),
+		Entry("Finland", "FI20774741"),
+		Entry("France", "FR41303265045"),
+		Entry("Croatia", "HR33392005962"),
+		Entry("Hungary", "HU12892313"),
+		Entry("Italy", "IT00743110158"),
+		Entry("Luxembourg", "LU26375246"),
+		Entry("Netherlands", "NL004495446B01"),
+		Entry("Poland", "PL5260001247"),
+		Entry("Portugal", "PT501964844"),
+		Entry("Sweden", "SE556000016801"),
+		Entry("Slovenia", "SI50223055"),
+	)
+
+	DescribeTable("should reject numbers not in the format of the prefix",
+		func(prefix, number string) {
+			Expect(Validate(prefix, number)).To(MatchError(ContainSubstring("is not in the")))
+		},
+		Entry("Austria without the U", "AT", "AT13585627"),
+		Entry("Belgium starting with 2", "BE", "BE2776091951"),
+		Entry("Germany too short", "DE", "DE13669597"),
+		Entry("Hungary with letters", "HU", "HU1289231A"),
+		Entry("Netherlands without the B", "NL", "NL004495445001"),
+		Entry("Sweden without the 01 suffix", "SE", "SE556000016702"),
+		Entry("Slovenia with a leading zero", "SI", "SI05022305"),
+		Entry("unknown prefix", "US", "US123456789"),
+	)
+
+	It("should require the prefix of the country", func() {
+		Expect(Validate("HU", "DE136695976")).To(MatchError(ContainSubstring("has to start with HU")))
+	})
+
+	DescribeTable("should map countries to their prefix",
+		func(country, prefix string) {
+			Expect(Prefix(country)).To(Equal(prefix))
+		},
+		Entry("Hungary", "HU", "HU"),
+		Entry("Greece", "GR", "EL"),
+		Entry("Northern Ireland", "XI", "XI"),
+		Entry("a country without EU VAT numbers", "US", ""),
+	)
+
+	It("should normalize the separators and the case", func() {
+		Expect(Normalize("hu 1289-2312")).To(Equal("HU12892312"))
+		Expect(Normalize("nl.0044.9544.5/b01")).To(Equal("NL004495445B01"))
+	})
+
+	It("should accept every number offline", func() {
+		verifier, err := New("offline")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifier.Verify(context.Background(), "HU12892312")).To(Succeed())
+
+		_, err = New("vies")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,164 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+// log is for logging in this package.
+var invitationlog = logf.Log.WithName("invitation-resource")
+
+// invitableRoles lists the roles the users of each role can hand out.
+var invitableRoles = map[string][]string{
+	"Owner": {"Owner", "Admin", "Billing", "Member"},
+	"Admin": {"Billing", "Member"},
+}
+
+// SetupInvitationWebhookWithManager registers the webhook for Invitation in the manager.
+func SetupInvitationWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&productv1.Invitation{}).
+		WithValidator(&InvitationCustomValidator{
+			Client: mgr.GetClient(),
+		}).
+		Complete()
+}
+
+// NOTE: The 'path' attribute must follow a specific pattern and should not be modified directly here.
+// Modifying the path for an invalid path can cause API server errors; failing to locate the webhook.
+// +kubebuilder:webhook:path=/validate-product-webshop-harikube-info-v1-invitation,mutating=false,failurePolicy=fail,sideEffects=None,groups=product.webshop.harikube.info,resources=invitations,verbs=create;update,versions=v1,name=vinvitation-v1.kb.io,admissionReviewVersions=v1
+
+// InvitationCustomValidator struct is responsible for validating the Invitation resource
+// when it is created or updated.
+//
+// NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
+// as this struct is used only for temporary operations and does not need to be deeply copied.
+type InvitationCustomValidator struct {
+	client.Client
+}
+
+var _ webhook.CustomValidator = &InvitationCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Invitation.
+func (v *InvitationCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	invitation, ok := obj.(*productv1.Invitation)
+	if !ok {
+		return nil, fmt.Errorf("expected a Invitation object but got %T", obj)
+	}
+	invitationlog.Info("Validation for Invitation upon create", "name", invitation.GetName())
+
+	user, err := requestingUser(ctx, v.Client)
+	if err != nil {
+		return nil, err
+	} else if user != nil {
+		if invitation.Namespace != user.Namespace {
+			return nil, fmt.Errorf("Invitation %s must be created in namespace %s", invitation.Name, user.Namespace)
+		}
+
+		if !slices.Contains(invitableRoles[user.Spec.Role], invitation.Spec.Role) {
+			return nil, fmt.Errorf("user %s with role %s cannot invite users with role %s", user.Spec.Email, user.Spec.Role, invitation.Spec.Role)
+		}
+
+		if err := v.validatePlan(ctx, invitation.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	existnigUsers := &productv1.UserList{}
+	if err := v.List(ctx, existnigUsers, client.MatchingFields{"spec.email": invitation.Spec.Email}); err != nil {
+		return nil, fmt.Errorf("failed to list existing users: %w", err)
+	} else if len(existnigUsers.Items) > 0 {
+		return nil, fmt.Errorf("a user with email %s already exists", invitation.Spec.Email)
+	}
+
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Invitation.
+func (v *InvitationCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	invitation, ok := newObj.(*productv1.Invitation)
+	if !ok {
+		return nil, fmt.Errorf("expected a Invitation object for the newObj but got %T", newObj)
+	}
+	invitationOld, ok := oldObj.(*productv1.Invitation)
+	if !ok {
+		return nil, fmt.Errorf("expected a Invitation object for the oldObj but got %T", oldObj)
+	}
+	invitationlog.Info("Validation for Invitation upon update", "name", invitation.GetName())
+
+	// The accept link was sent for the original email and role.
+	if !equality.Semantic.DeepEqual(invitation.Spec, invitationOld.Spec) {
+		return nil, fmt.Errorf("spec of Invitation %s cannot be changed, create a new one instead", invitation.Name)
+	}
+
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Invitation.
+func (v *InvitationCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validatePlan rejects invitations the plan of the tenant has no room for, pending invitations count as users.
+func (v *InvitationCustomValidator) validatePlan(ctx context.Context, namespace string) error {
+	tenant, err := tenancy.TenantOf(ctx, v.Client, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	limits := plan.LimitsOf(tenant.Spec.Plan)
+	if !limits.Invitations {
+		return fmt.Errorf("the %s plan does not allow inviting users, upgrade the plan first", plan.Of(tenant.Spec.Plan))
+	} else if limits.Users == 0 {
+		return nil
+	}
+
+	users := productv1.UserList{}
+	if err := v.List(ctx, &users, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	invitations := productv1.InvitationList{}
+	if err := v.List(ctx, &invitations, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	count := len(users.Items)
+	for _, invitation := range invitations.Items {
+		if invitation.Status.Phase == "" || invitation.Status.Phase == "Pending" {
+			count++
+		}
+	}
+	if count >= limits.Users {
+		return fmt.Errorf("the %s plan allows %d users in namespace %s, upgrade the plan for more", plan.Of(tenant.Spec.Plan), limits.Users, namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	// TODO (user): Add any additional imports if needed
+)
+
+var _ = Describe("Invitation Webhook", func() {
+	var (
+		obj       *productv1.Invitation
+		oldObj    *productv1.Invitation
+		validator InvitationCustomValidator
+	)
+
+	BeforeEach(func() {
+		obj = &productv1.Invitation{}
+		oldObj = &productv1.Invitation{}
+		validator = InvitationCustomValidator{}
+		Expect(validator).NotTo(BeNil(), "Expected validator to be initialized")
+		Expect(oldObj).NotTo(BeNil(), "Expected oldObj to be initialized")
+		Expect(obj).NotTo(BeNil(), "Expected obj to be initialized")
+		// TODO (user): Add any setup logic common to all tests
+	})
+
+	AfterEach(func() {
+		// TODO (user): Add any teardown logic common to all tests
+	})
+
+	Context("When creating or updating Invitation under Validating Webhook", func() {
+		// TODO (user): Add logic for validating webhooks
+		// Example:
+		// It("Should deny creation if an admin invites an owner", func() {
+		//     By("simulating a create request of the ServiceAccount of an admin")
+		//     ctx := admission.NewContextWithRequest(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		//         UserInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:" + admin.Namespace + ":" + string(admin.UID)},
+		//     }})
+		//     obj.Spec.Role = "Owner"
+		//     Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		// })
+		//
+		// It("Should deny update if the email changes", func() {
+		//     By("simulating an update of the invited email")
+		//     oldObj.Spec.Email = "old@harikube.info"
+		//     obj.Spec.Email = "new@harikube.info"
+		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		// })
+	})
+
+})
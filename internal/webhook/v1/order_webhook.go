@@ -19,14 +19,18 @@ package v1
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
 )
 
 // log is for logging in this package.
@@ -35,7 +39,9 @@ var orderlog = logf.Log.WithName("order-resource")
 // SetupOrderWebhookWithManager registers the webhook for Order in the manager.
 func SetupOrderWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&productv1.Order{}).
-		WithValidator(&OrderCustomValidator{}).
+		WithValidator(&OrderCustomValidator{
+			Client: mgr.GetClient(),
+		}).
 		WithDefaulter(&OrderCustomDefaulter{}).
 		Complete()
 }
@@ -80,20 +86,27 @@ func (d *OrderCustomDefaulter) Default(_ context.Context, obj runtime.Object) er
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type OrderCustomValidator struct {
-	// TODO(user): Add more fields as needed for validation
+	client.Client
 }
 
 var _ webhook.CustomValidator = &OrderCustomValidator{}
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Order.
-func (v *OrderCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *OrderCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	order, ok := obj.(*productv1.Order)
 	if !ok {
 		return nil, fmt.Errorf("expected a Order object but got %T", obj)
 	}
 	orderlog.Info("Validation for Order upon creation", "name", order.GetName())
 
-	// TODO(user): fill in your validation logic upon object creation.
+	user, err := requestingUser(ctx, v.Client)
+	if err != nil {
+		return nil, err
+	} else if user != nil {
+		if err := v.validatePlan(ctx, order); err != nil {
+			return nil, err
+		}
+	}
 
 	return nil, nil
 }
@@ -123,3 +136,41 @@ func (v *OrderCustomValidator) ValidateDelete(ctx context.Context, obj runtime.O
 
 	return nil, nil
 }
+
+// validatePlan rejects products which are not in the catalogue, are not offered on the plan of the tenant, or
+// would not upgrade the plan they are meant to upgrade. The catalogue is consulted, as orders carry a copy of
+// the products.
+func (v *OrderCustomValidator) validatePlan(ctx context.Context, order *productv1.Order) error {
+	tenant, err := tenancy.TenantOf(ctx, v.Client, order.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+	current := plan.Of(tenant.Spec.Plan)
+
+	products := productv1.ProductList{}
+	if err := v.List(ctx, &products); err != nil {
+		return fmt.Errorf("failed to list products: %w", err)
+	}
+
+	for _, ordered := range order.Spec.Products {
+		i := slices.IndexFunc(products.Items, func(product productv1.Product) bool {
+			return product.Spec.DisplayName == ordered.Product.DisplayName
+		})
+		if i < 0 {
+			return fmt.Errorf("product %s is not in the catalogue", ordered.Product.DisplayName)
+		}
+		product := products.Items[i]
+
+		if len(product.Spec.Plans) > 0 && !slices.Contains(product.Spec.Plans, current) {
+			return fmt.Errorf("product %s cannot be ordered on the %s plan", product.Spec.DisplayName, current)
+		}
+
+		if ordered.Product.UpgradePlan != product.Spec.UpgradePlan {
+			return fmt.Errorf("plan upgrade of product %s does not match the catalogue", product.Spec.DisplayName)
+		} else if product.Spec.UpgradePlan != "" && plan.Rank(product.Spec.UpgradePlan) <= plan.Rank(current) {
+			return fmt.Errorf("tenant %s is on the %s plan already", tenant.Name, current)
+		}
+	}
+
+	return nil
+}
@@ -28,18 +28,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
-	"github.com/alexedwards/argon2id"
-	"github.com/dlclark/regexp2"
+	"github.com/HariKube/example-webshop-service/internal/password"
+	"github.com/HariKube/example-webshop-service/internal/vat"
 )
 
 // log is for logging in this package.
 var registrationrequestlog = logf.Log.WithName("registrationrequest-resource")
 
 // SetupRegistrationRequestWebhookWithManager registers the webhook for RegistrationRequest in the manager.
-func SetupRegistrationRequestWebhookWithManager(mgr ctrl.Manager) error {
+// The VAT numbers of the tenants to register are confirmed by the verifier once they pass the offline checks.
+func SetupRegistrationRequestWebhookWithManager(mgr ctrl.Manager, verifier vat.Verifier) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&productv1.RegistrationRequest{}).
 		WithValidator(&RegistrationRequestCustomValidator{
 			Client: mgr.GetClient(),
+			VAT:    verifier,
 		}).
 		WithDefaulter(&RegistrationRequestCustomDefaulter{}).
 		Complete()
@@ -59,17 +61,6 @@ type RegistrationRequestCustomDefaulter struct {
 
 var _ webhook.CustomDefaulter = &RegistrationRequestCustomDefaulter{}
 
-var (
-	passwordMatcher      = regexp2.MustCompile(`^(?=.*[a-z])(?=.*[A-Z])(?=.*\d)(?=.*[^A-Za-z0-9]).{8,64}$`, 0)
-	hashingDefaultParams = &argon2id.Params{
-		Memory:      64 * 1024,
-		Iterations:  3,
-		Parallelism: 2,
-		SaltLength:  16,
-		KeyLength:   32,
-	}
-)
-
 // Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind RegistrationRequest.
 func (d *RegistrationRequestCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
 	registrationrequest, ok := obj.(*productv1.RegistrationRequest)
@@ -79,11 +70,11 @@ func (d *RegistrationRequestCustomDefaulter) Default(ctx context.Context, obj ru
 	}
 	registrationrequestlog.Info("Defaulting for RegistrationRequest", "name", registrationrequest.GetName())
 
-	if ok, _ := passwordMatcher.MatchString(registrationrequest.Spec.Password); !ok {
-		return fmt.Errorf("password must include upper, lower, number, special and be 8-64 chars")
+	if err := password.Validate(registrationrequest.Spec.Password); err != nil {
+		return err
 	}
 
-	hash, err := argon2id.CreateHash(registrationrequest.Spec.Password, hashingDefaultParams)
+	hash, err := password.Hash(registrationrequest.Spec.Password)
 	if err != nil {
 		return fmt.Errorf("error hashing password: %w", err)
 	}
@@ -109,6 +100,7 @@ func (d *RegistrationRequestCustomDefaulter) Default(ctx context.Context, obj ru
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type RegistrationRequestCustomValidator struct {
 	client.Client
+	VAT vat.Verifier
 }
 
 var _ webhook.CustomValidator = &RegistrationRequestCustomValidator{}
@@ -121,6 +113,10 @@ func (v *RegistrationRequestCustomValidator) ValidateCreate(ctx context.Context,
 	}
 	registrationrequestlog.Info("Validation for RegistrationRequest upon create", "name", registrationrequest.GetName())
 
+	if err := validateTenantSpec(ctx, &registrationrequest.Spec.Tenant, v.VAT); err != nil {
+		return nil, err
+	}
+
 	existnigUsers := &productv1.UserList{}
 	if err := v.List(ctx, existnigUsers, client.MatchingFields{"spec.email": registrationrequest.Spec.User.Email}); err != nil {
 		return nil, fmt.Errorf("failed to list existing users: %w", err)
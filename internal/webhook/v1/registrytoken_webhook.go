@@ -0,0 +1,293 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/plan"
+	"github.com/HariKube/example-webshop-service/internal/tenancy"
+)
+
+const (
+	// serviceAccountUsernamePrefix is the prefix of the usernames ServiceAccounts authenticate with.
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+)
+
+// log is for logging in this package.
+var registrytokenlog = logf.Log.WithName("registrytoken-resource")
+
+// SetupRegistryTokenWebhookWithManager registers the webhook for RegistryToken in the manager.
+// maxLifetime is the default maximum lifetime of tokens in tenants without their own limit.
+func SetupRegistryTokenWebhookWithManager(mgr ctrl.Manager, maxLifetime time.Duration) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&productv1.RegistryToken{}).
+		WithValidator(&RegistryTokenCustomValidator{
+			Client:      mgr.GetClient(),
+			MaxLifetime: maxLifetime,
+		}).
+		WithDefaulter(&RegistryTokenCustomDefaulter{
+			Client: mgr.GetClient(),
+		}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-product-webshop-harikube-info-v1-registrytoken,mutating=true,failurePolicy=fail,sideEffects=None,groups=product.webshop.harikube.info,resources=registrytokens,verbs=create;update,versions=v1,name=mregistrytoken-v1.kb.io,admissionReviewVersions=v1
+
+// RegistryTokenCustomDefaulter struct is responsible for setting default values on the custom resource of the
+// Kind RegistryToken when those are created or updated.
+//
+// NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
+// as it is used only for temporary operations and does not need to be deeply copied.
+type RegistryTokenCustomDefaulter struct {
+	client.Client
+}
+
+var _ webhook.CustomDefaulter = &RegistryTokenCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind RegistryToken.
+// Spec.User of new tokens is filled from the User the requesting ServiceAccount belongs to.
+func (d *RegistryTokenCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	token, ok := obj.(*productv1.RegistryToken)
+	if !ok {
+		return fmt.Errorf("expected a RegistryToken object but got %T", obj)
+	}
+	registrytokenlog.Info("Defaulting for RegistryToken", "name", token.GetName())
+
+	if req, err := admission.RequestFromContext(ctx); err != nil {
+		return fmt.Errorf("failed to get admission request: %w", err)
+	} else if req.Operation != admissionv1.Create {
+		return nil
+	}
+
+	user, err := requestingUser(ctx, d.Client)
+	if err != nil {
+		return err
+	} else if user != nil {
+		token.Spec.User = user.Spec
+	}
+
+	return nil
+}
+
+// NOTE: The 'path' attribute must follow a specific pattern and should not be modified directly here.
+// Modifying the path for an invalid path can cause API server errors; failing to locate the webhook.
+// +kubebuilder:webhook:path=/validate-product-webshop-harikube-info-v1-registrytoken,mutating=false,failurePolicy=fail,sideEffects=None,groups=product.webshop.harikube.info,resources=registrytokens,verbs=create;update,versions=v1,name=vregistrytoken-v1.kb.io,admissionReviewVersions=v1
+
+// RegistryTokenCustomValidator struct is responsible for validating the RegistryToken resource
+// when it is created or updated.
+//
+// NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
+// as this struct is used only for temporary operations and does not need to be deeply copied.
+type RegistryTokenCustomValidator struct {
+	client.Client
+	MaxLifetime time.Duration
+}
+
+var _ webhook.CustomValidator = &RegistryTokenCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type RegistryToken.
+func (v *RegistryTokenCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	token, ok := obj.(*productv1.RegistryToken)
+	if !ok {
+		return nil, fmt.Errorf("expected a RegistryToken object but got %T", obj)
+	}
+	registrytokenlog.Info("Validation for RegistryToken upon create", "name", token.GetName())
+
+	user, err := requestingUser(ctx, v.Client)
+	if err != nil {
+		return nil, err
+	} else if user != nil {
+		if err := validateTokenUser(token, user); err != nil {
+			return nil, err
+		}
+
+		if user.Status.Phase != "Validated" {
+			return nil, fmt.Errorf("user %s must be validated to create RegistryTokens", user.Spec.Email)
+		}
+
+		if err := v.validateActiveLicence(ctx, token.Namespace); err != nil {
+			return nil, err
+		}
+
+		if err := v.validatePlan(ctx, token.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, v.validateLifetime(ctx, token)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type RegistryToken.
+func (v *RegistryTokenCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	token, ok := newObj.(*productv1.RegistryToken)
+	if !ok {
+		return nil, fmt.Errorf("expected a RegistryToken object for the newObj but got %T", newObj)
+	}
+	tokenOld, ok := oldObj.(*productv1.RegistryToken)
+	if !ok {
+		return nil, fmt.Errorf("expected a RegistryToken object for the oldObj but got %T", oldObj)
+	}
+	registrytokenlog.Info("Validation for RegistryToken upon update", "name", token.GetName())
+
+	user, err := requestingUser(ctx, v.Client)
+	if err != nil {
+		return nil, err
+	} else if user != nil {
+		if !equality.Semantic.DeepEqual(token.Spec.User, tokenOld.Spec.User) {
+			return nil, fmt.Errorf("user of RegistryToken %s cannot be changed", token.Name)
+		}
+
+		if token.Namespace != user.Namespace || tokenOld.Spec.User.Email != user.Spec.Email {
+			return nil, fmt.Errorf("RegistryToken %s does not belong to the requesting user %s", token.Name, user.Spec.Email)
+		}
+	}
+
+	if token.Spec.ExpireTimestamp.Equal(&tokenOld.Spec.ExpireTimestamp) {
+		return nil, nil
+	}
+
+	return nil, v.validateLifetime(ctx, token)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type RegistryToken.
+func (v *RegistryTokenCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateLifetime rejects tokens expiring in the past or later than the maximum lifetime of the tenant.
+func (v *RegistryTokenCustomValidator) validateLifetime(ctx context.Context, token *productv1.RegistryToken) error {
+	lifetime := time.Until(token.Spec.ExpireTimestamp.Time)
+	if lifetime <= 0 {
+		return fmt.Errorf("expireTimestamp of RegistryToken %s must be in the future", token.Name)
+	}
+
+	namespace := corev1.Namespace{}
+	if err := v.Get(ctx, types.NamespacedName{Name: token.Namespace}, &namespace); err != nil {
+		return fmt.Errorf("failed to get tenant namespace: %w", err)
+	}
+	maxLifetime, err := tenancy.RegistryTokenMaxLifetime(&namespace, v.MaxLifetime)
+	if err != nil {
+		return err
+	}
+
+	if maxLifetime > 0 && lifetime > maxLifetime {
+		return fmt.Errorf("lifetime of RegistryToken %s exceeds the maximum of %s", token.Name, maxLifetime)
+	}
+
+	return nil
+}
+
+// validateActiveLicence rejects tokens in tenants without a non-expired, non-suspended Licence.
+func (v *RegistryTokenCustomValidator) validateActiveLicence(ctx context.Context, namespace string) error {
+	licences := productv1.LicenceList{}
+	if err := v.List(ctx, &licences, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list licences: %w", err)
+	}
+
+	for _, licence := range licences.Items {
+		if licence.Status.Suspended {
+			continue
+		}
+
+		if licence.Spec.ExpireTimestamp.IsZero() || licence.Spec.ExpireTimestamp.Time.After(time.Now()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("an active licence is required to create RegistryTokens in namespace %s", namespace)
+}
+
+// validatePlan rejects tokens beyond the number of RegistryTokens the plan of the tenant allows.
+func (v *RegistryTokenCustomValidator) validatePlan(ctx context.Context, namespace string) error {
+	tenant, err := tenancy.TenantOf(ctx, v.Client, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	limit := plan.LimitsOf(tenant.Spec.Plan).RegistryTokens
+	if limit == 0 {
+		return nil
+	}
+
+	tokens := productv1.RegistryTokenList{}
+	if err := v.List(ctx, &tokens, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list registry tokens: %w", err)
+	}
+
+	active := 0
+	for _, token := range tokens.Items {
+		if token.Status.Phase != "Expired" {
+			active++
+		}
+	}
+	if active >= limit {
+		return fmt.Errorf("the %s plan allows %d RegistryTokens in namespace %s, upgrade the plan for more", plan.Of(tenant.Spec.Plan), limit, namespace)
+	}
+
+	return nil
+}
+
+// validateTokenUser rejects tokens issued in the name of another user or outside the namespace of the user.
+func validateTokenUser(token *productv1.RegistryToken, user *productv1.User) error {
+	if token.Namespace != user.Namespace {
+		return fmt.Errorf("RegistryToken %s must be created in namespace %s", token.Name, user.Namespace)
+	}
+
+	if !equality.Semantic.DeepEqual(token.Spec.User, user.Spec) {
+		return fmt.Errorf("user of RegistryToken %s does not match the requesting user %s", token.Name, user.Spec.Email)
+	}
+
+	return nil
+}
+
+// requestingUser returns the User the ServiceAccount of the admission request belongs to.
+// Requests of other identities, like administrators and controllers, return nil.
+func requestingUser(ctx context.Context, c client.Client) (*productv1.User, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admission request: %w", err)
+	}
+
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(req.UserInfo.Username, serviceAccountUsernamePrefix), ":")
+	if !ok || !strings.HasPrefix(req.UserInfo.Username, serviceAccountUsernamePrefix) {
+		return nil, nil
+	}
+
+	users := productv1.UserList{}
+	if err := c.List(ctx, &users, client.InNamespace(namespace), client.MatchingFields{"metadata.uid": name}); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	} else if len(users.Items) == 0 {
+		return nil, nil
+	}
+
+	return &users.Items[0], nil
+}
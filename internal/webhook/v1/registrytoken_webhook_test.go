@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	// TODO (user): Add any additional imports if needed
+)
+
+var _ = Describe("RegistryToken Webhook", func() {
+	var (
+		obj       *productv1.RegistryToken
+		oldObj    *productv1.RegistryToken
+		validator RegistryTokenCustomValidator
+		defaulter RegistryTokenCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		obj = &productv1.RegistryToken{}
+		oldObj = &productv1.RegistryToken{}
+		validator = RegistryTokenCustomValidator{}
+		Expect(validator).NotTo(BeNil(), "Expected validator to be initialized")
+		defaulter = RegistryTokenCustomDefaulter{}
+		Expect(defaulter).NotTo(BeNil(), "Expected defaulter to be initialized")
+		Expect(oldObj).NotTo(BeNil(), "Expected oldObj to be initialized")
+		Expect(obj).NotTo(BeNil(), "Expected obj to be initialized")
+		// TODO (user): Add any setup logic common to all tests
+	})
+
+	AfterEach(func() {
+		// TODO (user): Add any teardown logic common to all tests
+	})
+
+	Context("When creating RegistryToken under Defaulting Webhook", func() {
+		// TODO (user): Add logic for defaulting webhooks
+		// Example:
+		// It("Should fill the user from the requesting ServiceAccount", func() {
+		//     By("simulating a create request of the ServiceAccount of a user")
+		//     ctx := admission.NewContextWithRequest(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		//         Operation: admissionv1.Create,
+		//         UserInfo:  authenticationv1.UserInfo{Username: "system:serviceaccount:" + user.Namespace + ":" + string(user.UID)},
+		//     }})
+		//     Expect(defaulter.Default(ctx, obj)).To(Succeed())
+		//     Expect(obj.Spec.User).To(Equal(user.Spec))
+		// })
+	})
+
+	Context("When creating or updating RegistryToken under Validating Webhook", func() {
+		// TODO (user): Add logic for validating webhooks
+		// Example:
+		// It("Should deny creation if the lifetime exceeds the maximum", func() {
+		//     By("simulating a token expiring after the maximum lifetime")
+		//     validator.MaxLifetime = time.Hour
+		//     obj.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(2 * time.Hour))
+		//     Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		// })
+		//
+		// It("Should admit update if the expiration is unchanged", func() {
+		//     By("simulating an update without expiration change")
+		//     oldObj.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		//     obj.Spec.ExpireTimestamp = oldObj.Spec.ExpireTimestamp
+		//     Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
+		// })
+
+		licence := func(name string, expireIn time.Duration, suspended bool) *productv1.Licence {
+			licence := &productv1.Licence{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-acme", Name: name}}
+			if expireIn != 0 {
+				licence.Spec.ExpireTimestamp = metav1.NewTime(time.Now().Add(expireIn))
+			}
+			licence.Status.Suspended = suspended
+			return licence
+		}
+
+		DescribeTable("Should require an active licence",
+			func(admitted bool, licences ...*productv1.Licence) {
+				builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+				for _, licence := range licences {
+					builder = builder.WithObjects(licence)
+				}
+				validator.Client = builder.Build()
+
+				err := validator.validateActiveLicence(ctx, "tenant-acme")
+				if admitted {
+					Expect(err).NotTo(HaveOccurred())
+				} else {
+					Expect(err).To(HaveOccurred())
+				}
+			},
+			Entry("without licences", false),
+			Entry("with a perpetual licence", true, licence("perpetual", 0, false)),
+			Entry("with an expired licence", false, licence("expired", -time.Hour, false)),
+			Entry("with a suspended licence", false, licence("suspended", time.Hour, true)),
+			Entry("with a suspended and an active licence", true,
+				licence("suspended", time.Hour, true), licence("active", time.Hour, false)),
+		)
+	})
+
+})
@@ -19,24 +19,32 @@ package v1
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/address"
+	"github.com/HariKube/example-webshop-service/internal/vat"
 )
 
 // log is for logging in this package.
 var tenantlog = logf.Log.WithName("tenant-resource")
 
 // SetupTenantWebhookWithManager registers the webhook for Tenant in the manager.
-func SetupTenantWebhookWithManager(mgr ctrl.Manager) error {
+// The VAT numbers of tenants are confirmed by the verifier once they pass the offline checks.
+func SetupTenantWebhookWithManager(mgr ctrl.Manager, verifier vat.Verifier) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&productv1.Tenant{}).
-		WithValidator(&TenantCustomValidator{}).
+		WithValidator(&TenantCustomValidator{
+			Client: mgr.GetClient(),
+			VAT:    verifier,
+		}).
 		WithDefaulter(&TenantCustomDefaulter{}).
 		Complete()
 }
@@ -75,7 +83,7 @@ func (d *TenantCustomDefaulter) Default(ctx context.Context, obj runtime.Object)
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 // NOTE: The 'path' attribute must follow a specific pattern and should not be modified directly here.
 // Modifying the path for an invalid path can cause API server errors; failing to locate the webhook.
-// +kubebuilder:webhook:path=/validate-product-webshop-harikube-info-v1-tenant,mutating=false,failurePolicy=fail,sideEffects=None,groups=product.webshop.harikube.info,resources=tenants,verbs=create;update,versions=v1,name=vtenant-v1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-product-webshop-harikube-info-v1-tenant,mutating=false,failurePolicy=fail,sideEffects=None,groups=product.webshop.harikube.info,resources=tenants,verbs=create;update;delete,versions=v1,name=vtenant-v1.kb.io,admissionReviewVersions=v1
 
 // TenantCustomValidator struct is responsible for validating the Tenant resource
 // when it is created, updated, or deleted.
@@ -83,6 +91,8 @@ func (d *TenantCustomDefaulter) Default(ctx context.Context, obj runtime.Object)
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type TenantCustomValidator struct {
+	client.Client
+	VAT vat.Verifier
 }
 
 var _ webhook.CustomValidator = &TenantCustomValidator{}
@@ -95,20 +105,46 @@ func (v *TenantCustomValidator) ValidateCreate(ctx context.Context, obj runtime.
 	}
 	tenantlog.Info("Validation for Tenant upon create", "name", tenant.GetName())
 
-	// TODO(user): fill in your validation logic upon object create.
+	if err := validateTenantSpec(ctx, &tenant.Spec, v.VAT); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Tenant.
-func (v *TenantCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+func (v *TenantCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
 	tenant, ok := newObj.(*productv1.Tenant)
 	if !ok {
 		return nil, fmt.Errorf("expected a Tenant object for the newObj but got %T", newObj)
 	}
+	tenantOld, ok := oldObj.(*productv1.Tenant)
+	if !ok {
+		return nil, fmt.Errorf("expected a Tenant object for the oldObj but got %T", oldObj)
+	}
 	tenantlog.Info("Validation for Tenant upon update", "name", tenant.GetName())
 
-	// TODO(user): fill in your validation logic upon object update.
+	// Tenants registered before the validation are left alone until their address changes.
+	if tenant.Spec.Country != tenantOld.Spec.Country ||
+		tenant.Spec.PostalCode != tenantOld.Spec.PostalCode ||
+		tenant.Spec.TaxNumber != tenantOld.Spec.TaxNumber {
+		if err := validateTenantSpec(ctx, &tenant.Spec, v.VAT); err != nil {
+			return nil, err
+		}
+	}
+
+	// Owners may edit their company details, but the plan, the guardrails and the suspension are ours to choose.
+	if tenant.Spec.Plan != tenantOld.Spec.Plan ||
+		tenant.Spec.Profile != tenantOld.Spec.Profile ||
+		tenant.Spec.Suspended != tenantOld.Spec.Suspended ||
+		tenant.Spec.SuspensionReason != tenantOld.Spec.SuspensionReason {
+		user, err := requestingUser(ctx, v.Client)
+		if err != nil {
+			return nil, err
+		} else if user != nil {
+			return nil, fmt.Errorf("plan, profile and suspension of Tenant %s cannot be changed by its users, upgrade the plan by an Order instead", tenant.Name)
+		}
+	}
 
 	return nil, nil
 }
@@ -121,7 +157,44 @@ func (v *TenantCustomValidator) ValidateDelete(ctx context.Context, obj runtime.
 	}
 	tenantlog.Info("Validation for Tenant upon deletion", "name", tenant.GetName())
 
-	// TODO(user): fill in your validation logic upon object deletion.
+	// Tenants are deleted by requesting the deletion, the Tenant controller deletes them once the grace period is over.
+	if !tenant.Spec.DeletionRequested || tenant.Status.DeletionScheduledTimestamp.IsZero() {
+		return nil, fmt.Errorf("deletion of Tenant %s has to be requested by setting spec.deletionRequested", tenant.Name)
+	} else if time.Now().Before(tenant.Status.DeletionScheduledTimestamp.Time) {
+		return nil, fmt.Errorf("deletion of Tenant %s can be cancelled until %s", tenant.Name,
+			tenant.Status.DeletionScheduledTimestamp.UTC().Format(time.RFC3339))
+	}
 
 	return nil, nil
 }
+
+// validateTenantSpec checks the country against ISO 3166-1, the postal code against the format of the country
+// and, for countries issuing EU VAT numbers, the format, the check digits and the verification of the tax number.
+func validateTenantSpec(ctx context.Context, spec *productv1.TenantSpec, verifier vat.Verifier) error {
+	country, err := address.Country(spec.Country)
+	if err != nil {
+		return err
+	}
+
+	if err := address.ValidatePostalCode(country, spec.PostalCode); err != nil {
+		return err
+	}
+
+	prefix := vat.Prefix(country)
+	if spec.TaxNumber == "" || prefix == "" {
+		return nil
+	}
+
+	number := vat.Normalize(spec.TaxNumber)
+	if err := vat.Validate(prefix, number); err != nil {
+		return err
+	}
+
+	if verifier != nil {
+		if err := verifier.Verify(ctx, number); err != nil {
+			return fmt.Errorf("VAT number %q could not be verified: %w", spec.TaxNumber, err)
+		}
+	}
+
+	return nil
+}
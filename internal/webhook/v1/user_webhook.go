@@ -108,8 +108,8 @@ func (v *UserCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Ob
 		return nil, apierrors.NewAlreadyExists(gr, fmt.Sprintf("email:%s", user.Spec.Email))
 	}
 
-	if len(user.Annotations) == 0 || user.Annotations["product.webshop.harikube.info/password"] == "" {
-		return nil, fmt.Errorf("missing required annotation 'product.webshop.harikube.info/password' for User %s", user.Name)
+	if err := validatePasswordAnnotation(user); err != nil {
+		return nil, err
 	}
 
 	return nil, nil
@@ -137,8 +137,18 @@ func (v *UserCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj
 		}
 	}
 
-	if hash, ok := user.Annotations["product.webshop.harikube.info/password"]; ok && hash == "" {
-		return nil, fmt.Errorf("annotation 'product.webshop.harikube.info/password' for User %s cannot be empty", user.Name)
+	if err := validatePasswordAnnotation(user); err != nil {
+		return nil, err
+	}
+
+	// Users can update their own profile, but roles are handed out by the other owners of the tenant.
+	if user.Spec.Role != userOld.Spec.Role {
+		requester, err := requestingUser(ctx, v.Client)
+		if err != nil {
+			return nil, err
+		} else if requester != nil && (requester.Spec.Role != "Owner" || requester.UID == user.UID) {
+			return nil, fmt.Errorf("role of User %s can only be changed by another owner of the tenant", user.Name)
+		}
 	}
 
 	return nil, nil
@@ -156,3 +166,12 @@ func (v *UserCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Ob
 
 	return nil, nil
 }
+
+// validatePasswordAnnotation rejects the legacy password annotation, hashes are handed over in a Secret and never live on the User.
+func validatePasswordAnnotation(user *productv1.User) error {
+	if _, ok := user.Annotations["product.webshop.harikube.info/password"]; ok {
+		return fmt.Errorf("annotation 'product.webshop.harikube.info/password' is not allowed on User %s", user.Name)
+	}
+
+	return nil
+}
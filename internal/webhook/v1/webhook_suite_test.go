@@ -40,6 +40,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	productv1 "github.com/HariKube/example-webshop-service/api/v1"
+	"github.com/HariKube/example-webshop-service/internal/vat"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -118,10 +119,16 @@ var _ = BeforeSuite(func() {
 	err = SetupUserWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupTenantWebhookWithManager(mgr)
+	err = SetupTenantWebhookWithManager(mgr, &vat.OfflineVerifier{})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupRegistrationRequestWebhookWithManager(mgr)
+	err = SetupRegistryTokenWebhookWithManager(mgr, 0)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupRegistrationRequestWebhookWithManager(mgr, &vat.OfflineVerifier{})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupInvitationWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:webhook